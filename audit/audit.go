@@ -0,0 +1,407 @@
+// Package audit checks that a run of transfers conserved the supply of
+// every token: it tracks the wallets involved and the net flow of every
+// successful transfer, then compares the expected balances with the ones
+// of the gateway.
+package audit
+
+import (
+	"context"
+	"errors"
+	"fabricclient/fabric"
+	"fabricclient/logger"
+	"fmt"
+	"math/big"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultParallel is the number of queries in flight.
+	DefaultParallel = 16
+	// ResolveTimeout bounds the wait for the commit of a transfer.
+	ResolveTimeout = 30 * time.Second
+)
+
+// Checker records the expected balances of the tracked wallets. It is
+// safe for concurrent use.
+type Checker struct {
+	mu sync.Mutex
+	// expected maps tokenID and address to the expected balance.
+	expected map[string]map[string]*big.Int
+	// supply maps tokenID to the issued total, when the issue was seen.
+	supply map[string]*big.Int
+	// pending are the accepted transfers not known to be committed yet.
+	pending   []*transfer
+	transfers int
+	unknown   int
+}
+
+func NewChecker() *Checker {
+	return &Checker{
+		expected: map[string]map[string]*big.Int{},
+		supply:   map[string]*big.Int{},
+	}
+}
+
+func parse(amount string) (*big.Int, error) {
+	n, ok := new(big.Int).SetString(amount, 10)
+	if !ok {
+		return nil, errors.New("audit: invalid amount " + amount)
+	}
+	return n, nil
+}
+
+func (c *Checker) balance(tokenID, address string) *big.Int {
+	wallets, ok := c.expected[tokenID]
+	if !ok {
+		wallets = map[string]*big.Int{}
+		c.expected[tokenID] = wallets
+	}
+
+	n, ok := wallets[address]
+	if !ok {
+		n = new(big.Int)
+		wallets[address] = n
+	}
+	return n
+}
+
+// Track adds wallets holding tokenID at the balance queried by Snapshot.
+func (c *Checker) Track(tokenID string, addresses ...string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for _, a := range addresses {
+		c.balance(tokenID, a)
+	}
+}
+
+// Issued records the issue of total tokenID to address. The balances of
+// the tracked wallets must then add up to total.
+func (c *Checker) Issued(tokenID, address, total string) error {
+	n, err := parse(total)
+	if err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.balance(tokenID, address).Set(n)
+	c.supply[tokenID] = n
+
+	return nil
+}
+
+type transfer struct {
+	tokenID, from, to string
+	amount            *big.Int
+	txID              string
+}
+
+// apply moves the amount of t, with c.mu held.
+func (c *Checker) apply(t *transfer) {
+	from, to := c.balance(t.tokenID, t.from), c.balance(t.tokenID, t.to)
+	from.Sub(from, t.amount)
+	to.Add(to, t.amount)
+	c.transfers++
+}
+
+// Transfer records the outcome of a transfer, tracking both wallets. An
+// accepted transfer counts once Verify sees txID committed as valid. A
+// transfer rejected by the gateway moved nothing, any other error leaves
+// its outcome unknown and it is counted in the report.
+func (c *Checker) Transfer(tokenID, from, to, amount, txID string, err error) error {
+	n, perr := parse(amount)
+	if perr != nil {
+		return perr
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.balance(tokenID, from)
+	c.balance(tokenID, to)
+
+	if err != nil {
+		if !errors.Is(err, fabric.ErrRejected) {
+			c.unknown++
+		}
+		return nil
+	}
+
+	c.pending = append(c.pending, &transfer{tokenID, from, to, n, txID})
+
+	return nil
+}
+
+// resolve waits for the commit of the pending transfers, with at most
+// parallel waits in flight.
+func (c *Checker) resolve(ctx context.Context, f *fabric.FabricClient, parallel int) {
+	if parallel <= 0 {
+		parallel = DefaultParallel
+	}
+
+	c.mu.Lock()
+	pending := c.pending
+	c.pending = nil
+	c.mu.Unlock()
+
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, parallel)
+
+	for _, t := range pending {
+		sem <- struct{}{}
+		wg.Add(1)
+
+		go func(t *transfer) {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			wctx, cancel := context.WithTimeout(ctx, ResolveTimeout)
+			_, err := f.WaitForTx(wctx, t.txID)
+			cancel()
+
+			c.mu.Lock()
+			defer c.mu.Unlock()
+
+			switch {
+			case err == nil:
+				c.apply(t)
+			case errors.Is(err, fabric.ErrTxInvalid):
+			default:
+				c.unknown++
+			}
+		}(t)
+	}
+
+	wg.Wait()
+}
+
+// addresses returns the tracked addresses.
+func (c *Checker) addresses() []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	set := map[string]bool{}
+	for _, wallets := range c.expected {
+		for a := range wallets {
+			set[a] = true
+		}
+	}
+
+	addrs := []string{}
+	for a := range set {
+		addrs = append(addrs, a)
+	}
+	sort.Strings(addrs)
+
+	return addrs
+}
+
+// queryAll queries the balance of every address with at most parallel
+// queries in flight.
+func queryAll(ctx context.Context, f *fabric.FabricClient, addrs []string, parallel int) (map[string]*fabric.Balance, error) {
+	if parallel <= 0 {
+		parallel = DefaultParallel
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var (
+		mu       sync.Mutex
+		wg       sync.WaitGroup
+		firstErr error
+	)
+	balances := map[string]*fabric.Balance{}
+	sem := make(chan struct{}, parallel)
+
+	for _, a := range addrs {
+		sem <- struct{}{}
+		wg.Add(1)
+
+		go func(a string) {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			b, err := f.QueryBalanceContext(ctx, a)
+
+			mu.Lock()
+			defer mu.Unlock()
+
+			if err != nil {
+				if firstErr == nil {
+					firstErr = err
+					cancel()
+				}
+				return
+			}
+			balances[a] = b
+		}(a)
+	}
+
+	wg.Wait()
+
+	if firstErr != nil {
+		logger.Error(firstErr)
+		return nil, firstErr
+	}
+
+	return balances, nil
+}
+
+// Snapshot sets the expected balance of the tracked wallets, except the
+// issuers, to their current balance.
+func (c *Checker) Snapshot(ctx context.Context, f *fabric.FabricClient, parallel int) error {
+	balances, err := queryAll(ctx, f, c.addresses(), parallel)
+	if err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for tokenID, wallets := range c.expected {
+		if c.supply[tokenID] != nil {
+			continue
+		}
+
+		for a, n := range wallets {
+			got, err := parse(balances[a].Get(tokenID))
+			if err != nil {
+				return err
+			}
+			n.Set(got)
+		}
+	}
+
+	return nil
+}
+
+// Mismatch is a wallet whose balance differs from the expected one.
+type Mismatch struct {
+	TokenID  string
+	Address  string
+	Expected string
+	Actual   string
+}
+
+// Supply is the sum of the balances of the tracked wallets of a token.
+type Supply struct {
+	TokenID  string
+	Expected string
+	Actual   string
+}
+
+// Report is the outcome of Verify.
+type Report struct {
+	Wallets   int
+	Transfers int
+	// Unknown counts the transfers whose outcome is unknown, they may
+	// explain per wallet mismatches but not a supply mismatch.
+	Unknown    int
+	Supplies   []Supply
+	Mismatches []Mismatch
+}
+
+// OK reports whether every balance and supply matched.
+func (r *Report) OK() bool {
+	if len(r.Mismatches) > 0 {
+		return false
+	}
+	for _, s := range r.Supplies {
+		if s.Expected != s.Actual {
+			return false
+		}
+	}
+	return true
+}
+
+func (r *Report) String() string {
+	b := &strings.Builder{}
+
+	fmt.Fprintf(b, "wallets %d, transfers %d, unknown outcome %d\n", r.Wallets, r.Transfers, r.Unknown)
+	for _, s := range r.Supplies {
+		status := "ok"
+		if s.Expected != s.Actual {
+			status = "MISMATCH"
+		}
+		fmt.Fprintf(b, "supply %s expected %s actual %s %s\n", s.TokenID, s.Expected, s.Actual, status)
+	}
+	for _, m := range r.Mismatches {
+		fmt.Fprintf(b, "wallet %s token %s expected %s actual %s MISMATCH\n", m.Address, m.TokenID, m.Expected, m.Actual)
+	}
+
+	if r.OK() {
+		b.WriteString("audit passed\n")
+	} else {
+		b.WriteString("audit FAILED\n")
+	}
+
+	return b.String()
+}
+
+// Verify waits for the pending transfers, then queries the balance of
+// every tracked wallet with at most parallel queries in flight and
+// compares them with the expected ones.
+func (c *Checker) Verify(ctx context.Context, f *fabric.FabricClient, parallel int) (*Report, error) {
+	c.resolve(ctx, f, parallel)
+
+	addrs := c.addresses()
+
+	balances, err := queryAll(ctx, f, addrs, parallel)
+	if err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	r := &Report{
+		Wallets:   len(addrs),
+		Transfers: c.transfers,
+		Unknown:   c.unknown,
+	}
+
+	tokenIDs := []string{}
+	for tokenID := range c.expected {
+		tokenIDs = append(tokenIDs, tokenID)
+	}
+	sort.Strings(tokenIDs)
+
+	for _, tokenID := range tokenIDs {
+		wallets := c.expected[tokenID]
+
+		expected, actual := new(big.Int), new(big.Int)
+		for _, a := range addrs {
+			want, ok := wallets[a]
+			if !ok {
+				continue
+			}
+
+			got, err := parse(balances[a].Get(tokenID))
+			if err != nil {
+				return nil, err
+			}
+
+			expected.Add(expected, want)
+			actual.Add(actual, got)
+
+			if want.Cmp(got) != 0 {
+				r.Mismatches = append(r.Mismatches, Mismatch{tokenID, a, want.String(), got.String()})
+			}
+		}
+
+		// the issued total, if known, is what the tracked wallets hold
+		if total := c.supply[tokenID]; total != nil {
+			expected = total
+		}
+
+		r.Supplies = append(r.Supplies, Supply{tokenID, expected.String(), actual.String()})
+	}
+
+	return r, nil
+}
@@ -0,0 +1,197 @@
+package audit
+
+import (
+	"context"
+	"fabricclient/fabric"
+	"fabricclient/fabric/fabrictest"
+	"fabricclient/fabric/fabrictest/testclient"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+// send sends amount from w to to and records it in c.
+func send(t *testing.T, c *Checker, f *fabric.FabricClient, tokenID string, w fabric.Wallet, to, amount string) {
+	t.Helper()
+
+	txID, err := f.TransferContext(context.Background(), tokenID, w.Address, w.PrivKey, to, amount)
+	if err := c.Transfer(tokenID, w.Address, to, amount, txID, err); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func verify(t *testing.T, c *Checker, f *fabric.FabricClient) *Report {
+	t.Helper()
+
+	r, err := c.Verify(context.Background(), f, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return r
+}
+
+func TestIssuedSupply(t *testing.T) {
+	s := fabrictest.NewServer()
+	defer s.Close()
+	f := testclient.New(t, s)
+
+	a, b, c := testclient.NewWallet(), testclient.NewWallet(), testclient.NewWallet()
+	tokenID, err := f.IssueToken(a.Address, a.PrivKey, "OCE", "100")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	checker := NewChecker()
+	checker.Issued(tokenID, a.Address, "100")
+
+	send(t, checker, f, tokenID, a, b.Address, "30")
+	send(t, checker, f, tokenID, b, c.Address, "10")
+	// rejected, moves nothing
+	send(t, checker, f, tokenID, c, a.Address, "11")
+
+	r := verify(t, checker, f)
+	if !r.OK() || r.Wallets != 3 || r.Transfers != 2 || r.Unknown != 0 {
+		t.Errorf("report:\n%s", r)
+	}
+	if len(r.Supplies) != 1 || r.Supplies[0].Actual != "100" {
+		t.Errorf("supplies = %+v", r.Supplies)
+	}
+	if !strings.HasSuffix(r.String(), "audit passed\n") {
+		t.Errorf("report:\n%s", r)
+	}
+}
+
+func TestSnapshot(t *testing.T) {
+	s := fabrictest.NewServer()
+	defer s.Close()
+	f := testclient.New(t, s)
+
+	a, b := testclient.NewWallet(), testclient.NewWallet()
+	tokenID, _ := f.IssueToken(a.Address, a.PrivKey, "OCE", "100")
+
+	// the wallets held funds before the run
+	checker := NewChecker()
+	checker.Track(tokenID, a.Address, b.Address)
+	if err := checker.Snapshot(context.Background(), f, 0); err != nil {
+		t.Fatal(err)
+	}
+
+	send(t, checker, f, tokenID, a, b.Address, "5")
+	send(t, checker, f, tokenID, b, a.Address, "2")
+
+	if r := verify(t, checker, f); !r.OK() || r.Supplies[0].Expected != "100" {
+		t.Errorf("report:\n%s", r)
+	}
+}
+
+func TestMismatch(t *testing.T) {
+	s := fabrictest.NewServer()
+	defer s.Close()
+	f := testclient.New(t, s)
+
+	a, b, out := testclient.NewWallet(), testclient.NewWallet(), testclient.NewWallet()
+	tokenID, _ := f.IssueToken(a.Address, a.PrivKey, "OCE", "100")
+
+	checker := NewChecker()
+	checker.Issued(tokenID, a.Address, "100")
+	checker.Track(tokenID, b.Address)
+
+	send(t, checker, f, tokenID, a, b.Address, "20")
+	// a transfer the checker never saw, out of the tracked wallets
+	if _, err := f.Transfer(tokenID, b.Address, b.PrivKey, out.Address, "7"); err != nil {
+		t.Fatal(err)
+	}
+
+	r := verify(t, checker, f)
+	if r.OK() {
+		t.Fatalf("report:\n%s", r)
+	}
+	if len(r.Mismatches) != 1 || r.Mismatches[0] != (Mismatch{tokenID, b.Address, "20", "13"}) {
+		t.Errorf("mismatches = %+v", r.Mismatches)
+	}
+	if s := r.Supplies[0]; s.Expected != "100" || s.Actual != "93" {
+		t.Errorf("supply = %+v", s)
+	}
+	if !strings.Contains(r.String(), "supply "+tokenID+" expected 100 actual 93 MISMATCH") ||
+		!strings.HasSuffix(r.String(), "audit FAILED\n") {
+		t.Errorf("report:\n%s", r)
+	}
+}
+
+func TestUnknownOutcome(t *testing.T) {
+	s := fabrictest.NewServer()
+	defer s.Close()
+	f := testclient.New(t, s)
+
+	a, b := testclient.NewWallet(), testclient.NewWallet()
+	tokenID, _ := f.IssueToken(a.Address, a.PrivKey, "OCE", "100")
+
+	checker := NewChecker()
+	checker.Issued(tokenID, a.Address, "100")
+
+	// applied, but the response is lost
+	s.Inject(fabrictest.Transfer, fabrictest.Fault{StatusCode: http.StatusBadGateway, AfterApply: true, Times: 1})
+	send(t, checker, f, tokenID, a, b.Address, "10")
+
+	r := verify(t, checker, f)
+	if r.Unknown != 1 || r.Transfers != 0 {
+		t.Errorf("report:\n%s", r)
+	}
+	// the wallets differ but the supply is conserved
+	if r.OK() || len(r.Mismatches) != 2 || r.Supplies[0].Actual != "100" {
+		t.Errorf("report:\n%s", r)
+	}
+}
+
+func TestPendingNotCommitted(t *testing.T) {
+	s := fabrictest.NewServer()
+	defer s.Close()
+	f := testclient.New(t, s)
+
+	a, b := testclient.NewWallet(), testclient.NewWallet()
+	tokenID, _ := f.IssueToken(a.Address, a.PrivKey, "OCE", "100")
+
+	checker := NewChecker()
+	checker.Issued(tokenID, a.Address, "100")
+	checker.Transfer(tokenID, a.Address, b.Address, "10", "missing", nil)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	// the wait for the unknown tx times out, the query still runs on a
+	// fresh context
+	checker.resolve(ctx, f, 1)
+	r := verify(t, checker, f)
+	if r.Unknown != 1 || r.Transfers != 0 || !r.OK() {
+		t.Errorf("report:\n%s", r)
+	}
+}
+
+func TestQueryError(t *testing.T) {
+	s := fabrictest.NewServer()
+	defer s.Close()
+	f := testclient.New(t, s)
+
+	checker := NewChecker()
+	for i := 0; i < 10; i++ {
+		checker.Track("t", testclient.NewWallet().Address)
+	}
+
+	s.Inject(fabrictest.QueryBalance, fabrictest.Fault{StatusCode: http.StatusServiceUnavailable})
+
+	if _, err := checker.Verify(context.Background(), f, 3); err == nil {
+		t.Error("Verify succeeded")
+	}
+}
+
+func TestInvalidAmount(t *testing.T) {
+	checker := NewChecker()
+
+	if err := checker.Issued("t", "a", "ten"); err == nil {
+		t.Error("Issued succeeded")
+	}
+	if err := checker.Transfer("t", "a", "b", "-", "tx", nil); err == nil {
+		t.Error("Transfer succeeded")
+	}
+}
@@ -0,0 +1,620 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"fabricclient/fabric"
+	"fabricclient/keystore"
+	"fabricclient/util"
+	"github.com/peterh/liner"
+)
+
+const (
+	// callTimeout bounds a token or chaincode call.
+	callTimeout = time.Minute
+	// keyEnv holds the wif key used when -key is not set.
+	keyEnv = "FABRICCLIENT_KEY"
+	// passphraseEnv holds the keystore passphrase, asked on the terminal
+	// if not set.
+	passphraseEnv = "FABRICCLIENT_PASSPHRASE"
+)
+
+// openKeystore opens the keystore of the -keystore flag, checking the
+// keys imported on net.
+func openKeystore(net *util.Network) (*keystore.Store, error) {
+	ks, err := keystore.Open(*keystoreDir)
+	if err != nil {
+		return nil, err
+	}
+
+	ks.Network = net
+	return ks, nil
+}
+
+// readPassphrase returns $FABRICCLIENT_PASSPHRASE or asks for a
+// passphrase, without echo on a terminal.
+func readPassphrase(prompt string) (string, error) {
+	return readSecret(passphraseEnv, prompt)
+}
+
+// readSecret returns $env or asks for it, without echo on a terminal.
+func readSecret(env, prompt string) (string, error) {
+	if p, ok := os.LookupEnv(env); ok {
+		return p, nil
+	}
+
+	line := liner.NewLiner()
+	defer line.Close()
+
+	p, err := line.PasswordPrompt(prompt)
+	if err == liner.ErrNotTerminalOutput {
+		// not a terminal, read a line of stdin
+		fmt.Fprint(os.Stderr, prompt)
+		p, err = bufio.NewReader(os.Stdin).ReadString('\n')
+		if err == io.EOF && p != "" {
+			err = nil
+		}
+		p = strings.TrimRight(p, "\r\n")
+	}
+	return p, err
+}
+
+// signer is the wallet of the commands signing requests, a -wallet of the
+// keystore or a -key.
+type signer struct {
+	key    *string
+	wallet *string
+}
+
+func signerFlags(fs *flag.FlagSet) *signer {
+	return &signer{
+		key:    fs.String("key", "", "wif private key, $"+keyEnv+" if empty"),
+		wallet: fs.String("wallet", "", "wallet of the keystore, instead of -key"),
+	}
+}
+
+func (s *signer) resolve(f *fabric.FabricClient) (fabric.Wallet, error) {
+	if *s.wallet == "" {
+		return walletOf(f, *s.key)
+	}
+
+	ks, err := openKeystore(f.Network())
+	if err != nil {
+		return fabric.Wallet{}, err
+	}
+
+	passphrase, err := readPassphrase("passphrase of " + *s.wallet + ": ")
+	if err != nil {
+		return fabric.Wallet{}, err
+	}
+
+	return ks.Export(*s.wallet, passphrase)
+}
+
+// walletOf returns the wallet of the wif key on the network of f, read
+// from $FABRICCLIENT_KEY if empty.
+func walletOf(f *fabric.FabricClient, key string) (fabric.Wallet, error) {
+	if key == "" {
+		key = os.Getenv(keyEnv)
+	}
+	if key == "" {
+		return fabric.Wallet{}, errors.New("no key, set -wallet, -key or $" + keyEnv)
+	}
+
+	w, err := f.WalletOf(key)
+	if err != nil {
+		return fabric.Wallet{}, err
+	}
+
+	return *w, nil
+}
+
+type walletView struct {
+	Address string `json:"address"`
+	PubKey  string `json:"pubKey"`
+	PrivKey string `json:"privKey,omitempty"`
+}
+
+func (v *walletView) table() *table {
+	t := fields("address", v.Address, "pubKey", v.PubKey)
+	if v.PrivKey != "" {
+		t.add("privKey", v.PrivKey)
+	}
+	return t
+}
+
+func walletNew(f *fabric.FabricClient, args []string) error {
+	fs := flag.NewFlagSet("wallet new", flag.ContinueOnError)
+	name := fs.String("name", "", "store the wallet in the keystore under this name")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	v := &walletView{}
+	var err error
+	v.PrivKey, v.PubKey, v.Address, err = f.Network().NewKey()
+	if err != nil {
+		return err
+	}
+
+	if *name != "" {
+		err = importWallet(f, *name, fabric.Wallet{Address: v.Address, PrivKey: v.PrivKey})
+		if err != nil {
+			return err
+		}
+		v.PrivKey = ""
+	}
+
+	return show(v, v.table())
+}
+
+// importWallet stores w in the keystore, asking for its passphrase.
+func importWallet(f *fabric.FabricClient, name string, w fabric.Wallet) error {
+	ks, err := openKeystore(f.Network())
+	if err != nil {
+		return err
+	}
+
+	passphrase, err := readPassphrase("new passphrase of " + name + ": ")
+	if err != nil {
+		return err
+	}
+	if passphrase == "" {
+		return errors.New("empty passphrase")
+	}
+
+	return ks.Import(name, w, passphrase)
+}
+
+func walletShow(f *fabric.FabricClient, args []string) error {
+	fs := flag.NewFlagSet("wallet show", flag.ContinueOnError)
+	sg := signerFlags(fs)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	w, err := sg.resolve(f)
+	if err != nil {
+		return err
+	}
+
+	pubKey, err := f.Network().PubKey(w.PrivKey)
+	if err != nil {
+		return err
+	}
+
+	v := &walletView{Address: w.Address, PubKey: pubKey}
+	return show(v, v.table())
+}
+
+func walletList(f *fabric.FabricClient, args []string) error {
+	ks, err := openKeystore(f.Network())
+	if err != nil {
+		return err
+	}
+
+	entries, err := ks.List()
+	if err != nil {
+		return err
+	}
+
+	t := &table{header: []string{"NAME", "ADDRESS"}}
+	for _, e := range entries {
+		t.add(e.Name, e.Address)
+	}
+	return show(entries, t)
+}
+
+func walletImport(f *fabric.FabricClient, args []string) error {
+	fs := flag.NewFlagSet("wallet import", flag.ContinueOnError)
+	name := fs.String("name", "", "wallet name")
+	key := fs.String("key", "", "wif private key, $"+keyEnv+" if empty")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if err := required(fs, "name"); err != nil {
+		return err
+	}
+
+	w, err := walletOf(f, *key)
+	if err != nil {
+		return err
+	}
+
+	err = importWallet(f, *name, w)
+	if err != nil {
+		return err
+	}
+
+	return show(&keystore.Entry{Name: *name, Address: w.Address}, fields("name", *name, "address", w.Address))
+}
+
+func walletExport(f *fabric.FabricClient, args []string) error {
+	fs := flag.NewFlagSet("wallet export", flag.ContinueOnError)
+	name := fs.String("name", "", "wallet name")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if err := required(fs, "name"); err != nil {
+		return err
+	}
+
+	w, err := (&signer{wallet: name}).resolve(f)
+	if err != nil {
+		return err
+	}
+
+	pubKey, err := f.Network().PubKey(w.PrivKey)
+	if err != nil {
+		return err
+	}
+
+	v := &walletView{Address: w.Address, PubKey: pubKey, PrivKey: w.PrivKey}
+	return show(v, v.table())
+}
+
+func walletDelete(f *fabric.FabricClient, args []string) error {
+	fs := flag.NewFlagSet("wallet delete", flag.ContinueOnError)
+	name := fs.String("name", "", "wallet name")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if err := required(fs, "name"); err != nil {
+		return err
+	}
+
+	ks, err := openKeystore(f.Network())
+	if err != nil {
+		return err
+	}
+
+	return ks.Delete(*name)
+}
+
+func tokenIssue(f *fabric.FabricClient, args []string) error {
+	fs := flag.NewFlagSet("token issue", flag.ContinueOnError)
+	sg := signerFlags(fs)
+	name := fs.String("name", "", "token name")
+	total := fs.String("total", "", "total supply")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if err := required(fs, "name", "total"); err != nil {
+		return err
+	}
+
+	w, err := sg.resolve(f)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), callTimeout)
+	defer cancel()
+
+	tokenID, err := f.IssueTokenContext(ctx, w.Address, w.PrivKey, *name, *total)
+	if err != nil {
+		return err
+	}
+
+	v := &fabric.TokenInfo{TokenID: tokenID, TokenName: *name, Address: w.Address, TotalNumber: *total}
+	return show(v, tokenTable(v))
+}
+
+func tokenTable(info *fabric.TokenInfo) *table {
+	return fields("tokenID", info.TokenID, "tokenName", info.TokenName, "address", info.Address, "totalNumber", info.TotalNumber)
+}
+
+func tokenInfo(f *fabric.FabricClient, args []string) error {
+	if len(args) != 1 {
+		return errors.New("token info: want one tokenID")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), callTimeout)
+	defer cancel()
+
+	info, err := f.QueryTokenContext(ctx, args[0])
+	if err != nil {
+		return err
+	}
+
+	return show(info, tokenTable(info))
+}
+
+// txView is the outcome of a transfer or a wait.
+type txView struct {
+	TxID           string `json:"txID"`
+	Status         string `json:"status"`
+	ValidationCode string `json:"validationCode,omitempty"`
+}
+
+func resultView(res *fabric.TxResult) *txView {
+	v := &txView{TxID: res.TxID, Status: res.Status.String()}
+	if res.Tx != nil {
+		v.ValidationCode = res.Tx.ValidationCode
+	}
+	return v
+}
+
+func (v *txView) table() *table {
+	t := fields("txID", v.TxID, "status", v.Status)
+	if v.ValidationCode != "" {
+		t.add("validationCode", v.ValidationCode)
+	}
+	return t
+}
+
+func transfer(f *fabric.FabricClient, args []string) error {
+	fs := flag.NewFlagSet("transfer", flag.ContinueOnError)
+	sg := signerFlags(fs)
+	tokenID := fs.String("token", "", "token ID")
+	to := fs.String("to", "", "receiving address")
+	amount := fs.String("amount", "", "amount transferred")
+	wait := fs.Bool("wait", false, "wait for the commit")
+	timeout := fs.Duration("timeout", callTimeout, "timeout, including the wait")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if err := required(fs, "token", "to", "amount"); err != nil {
+		return err
+	}
+
+	w, err := sg.resolve(f)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), *timeout)
+	defer cancel()
+
+	txID, err := f.TransferContext(ctx, *tokenID, w.Address, w.PrivKey, *to, *amount)
+	if err != nil {
+		return err
+	}
+
+	v := &txView{TxID: txID, Status: fabric.TxPending.String()}
+	if *wait {
+		var res *fabric.TxResult
+		res, err = f.WaitForTx(ctx, txID)
+		v = resultView(res)
+	}
+
+	if perr := show(v, v.table()); perr != nil {
+		return perr
+	}
+	return err
+}
+
+type balanceRow struct {
+	Address string `json:"address"`
+	TokenID string `json:"tokenID"`
+	Amount  string `json:"amount"`
+}
+
+func balance(f *fabric.FabricClient, args []string) error {
+	fs := flag.NewFlagSet("balance", flag.ContinueOnError)
+	tokenID := fs.String("token", "", "only this token")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() == 0 {
+		return errors.New("balance: no address")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), callTimeout)
+	defer cancel()
+
+	rows := []balanceRow{}
+	for _, addr := range fs.Args() {
+		b, err := f.QueryBalanceContext(ctx, addr)
+		if err != nil {
+			return err
+		}
+
+		if *tokenID != "" {
+			rows = append(rows, balanceRow{addr, *tokenID, b.Get(*tokenID)})
+			continue
+		}
+
+		ids := []string{}
+		for id := range b.Tokens {
+			ids = append(ids, id)
+		}
+		sort.Strings(ids)
+
+		for _, id := range ids {
+			rows = append(rows, balanceRow{addr, id, b.Tokens[id]})
+		}
+	}
+
+	t := &table{header: []string{"ADDRESS", "TOKEN", "AMOUNT"}}
+	for _, r := range rows {
+		t.add(r.Address, r.TokenID, r.Amount)
+	}
+	return show(rows, t)
+}
+
+// recordView is a transaction of the ocean gateway.
+type recordView struct {
+	TxID           string                 `json:"txID"`
+	Status         string                 `json:"status"`
+	ValidationCode string                 `json:"validationCode,omitempty"`
+	PubKey         string                 `json:"pubKey"`
+	Signature      string                 `json:"signature"`
+	Issue          *fabric.TokenOrigin    `json:"issue,omitempty"`
+	Transfer       *fabric.TransferOrigin `json:"transfer,omitempty"`
+}
+
+func recordTable(v *recordView) *table {
+	t := fields("txID", v.TxID, "status", v.Status)
+	if v.ValidationCode != "" {
+		t.add("validationCode", v.ValidationCode)
+	}
+	t.add("pubKey", v.PubKey)
+
+	if is := v.Issue; is != nil {
+		t.add("issue", fmt.Sprintf("%s %s to %s", is.TotalNumber, is.TokenName, is.Address))
+	}
+	if tr := v.Transfer; tr != nil {
+		t.add("transfer", fmt.Sprintf("%s %s from %s to %s", tr.Number, tr.TokenID, tr.FromAddress, tr.ToAddress))
+	}
+	return t
+}
+
+func ledgerTable(tx *fabric.Transaction) *table {
+	t := fields(
+		"txID", tx.TxID,
+		"channel", tx.Channel,
+		"block", strconv.FormatUint(tx.BlockNumber, 10),
+		"type", tx.Type,
+		"timestamp", tx.Timestamp.Format(time.RFC3339),
+		"creator", tx.CreatorMSP,
+		"status", tx.ValidationStatus,
+	)
+	if tx.Chaincode != "" {
+		t.add("chaincode", tx.Chaincode+" "+tx.ChaincodeVersion)
+		t.add("call", tx.Fcn+"("+strings.Join(tx.Args, ", ")+")")
+	}
+	if len(tx.EndorserMSPs) > 0 {
+		t.add("endorsers", strings.Join(tx.EndorserMSPs, ", "))
+	}
+	for _, rw := range tx.RWSets {
+		for _, w := range rw.Writes {
+			t.add("write", rw.Namespace+" "+w.Key+" = "+w.Value)
+		}
+	}
+	if ev := tx.Event; ev != nil {
+		t.add("event", ev.Name)
+	}
+	return t
+}
+
+func txShow(f *fabric.FabricClient, args []string) error {
+	fs := flag.NewFlagSet("tx show", flag.ContinueOnError)
+	channel := fs.String("channel", "", "read the transaction from the ledger of this channel")
+	peer := fs.String("peer", "", "peer queried with -channel, the first of [rest] Peers if empty")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		return errors.New("tx show: want one txID")
+	}
+	txID := fs.Arg(0)
+
+	ctx, cancel := context.WithTimeout(context.Background(), callTimeout)
+	defer cancel()
+
+	if *channel != "" {
+		ptx, err := f.Chaincode().TransactionByID(ctx, *channel, txID, *peer)
+		if err != nil {
+			return err
+		}
+		tx := ptx.Tx
+		tx.Raw = nil
+
+		return show(tx, ledgerTable(tx))
+	}
+
+	rec, err := f.QueryTxContext(ctx, txID)
+	if err != nil {
+		return err
+	}
+
+	v := &recordView{
+		TxID:           rec.TxID,
+		Status:         rec.Status().String(),
+		ValidationCode: rec.ValidationCode,
+		PubKey:         rec.PubKey,
+		Signature:      rec.Signature,
+		Issue:          rec.Issue,
+		Transfer:       rec.Transfer,
+	}
+	return show(v, recordTable(v))
+}
+
+func txWait(f *fabric.FabricClient, args []string) error {
+	fs := flag.NewFlagSet("tx wait", flag.ContinueOnError)
+	timeout := fs.Duration("timeout", callTimeout, "wait timeout")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		return errors.New("tx wait: want one txID")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), *timeout)
+	defer cancel()
+
+	res, err := f.WaitForTx(ctx, fs.Arg(0))
+	v := resultView(res)
+
+	if perr := show(v, v.table()); perr != nil {
+		return perr
+	}
+	return err
+}
+
+func chaincodeInvoke(f *fabric.FabricClient, args []string) error {
+	fs := flag.NewFlagSet("chaincode invoke", flag.ContinueOnError)
+	channel := fs.String("channel", "", "channel name")
+	name := fs.String("name", "", "chaincode name")
+	fcn := fs.String("fcn", "", "chaincode function")
+	peers := fs.String("peers", "", "comma separated peers, [rest] Peers if empty")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if err := required(fs, "channel", "name", "fcn"); err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), callTimeout)
+	defer cancel()
+
+	txID, err := f.Chaincode().Invoke(ctx, *channel, *name, *fcn, fs.Args(), peerList(*peers))
+	if err != nil {
+		return err
+	}
+
+	v := &txView{TxID: txID, Status: fabric.TxPending.String()}
+	return show(v, v.table())
+}
+
+func chaincodeQuery(f *fabric.FabricClient, args []string) error {
+	fs := flag.NewFlagSet("chaincode query", flag.ContinueOnError)
+	channel := fs.String("channel", "", "channel name")
+	name := fs.String("name", "", "chaincode name")
+	fcn := fs.String("fcn", "", "chaincode function")
+	peer := fs.String("peer", "", "peer queried, the first of [rest] Peers if empty")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if err := required(fs, "channel", "name", "fcn"); err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), callTimeout)
+	defer cancel()
+
+	payload, err := f.Chaincode().Query(ctx, *channel, *name, *fcn, fs.Args(), peerList(*peer))
+	if err != nil {
+		return err
+	}
+
+	// a json payload is kept as is in the json output
+	var v interface{} = string(payload)
+	if json.Valid(payload) {
+		v = json.RawMessage(payload)
+	}
+
+	return show(v, fields("payload", string(payload)))
+}
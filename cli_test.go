@@ -0,0 +1,308 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fabricclient/fabric"
+	"fabricclient/fabric/fabrictest"
+	"fabricclient/fabric/fabrictest/testclient"
+	"fabricclient/hdwallet"
+	"fabricclient/keystore"
+	"fabricclient/load"
+	"fabricclient/util"
+	"gopkg.in/ini.v1"
+	"strings"
+	"testing"
+)
+
+func newClient(t *testing.T) (*fabric.FabricClient, *fabrictest.Server) {
+	t.Helper()
+
+	s := fabrictest.NewServer()
+	return testclient.New(t, s), s
+}
+
+// noServer returns a client of the commands that never reach a gateway.
+func noServer(t *testing.T, opts ...fabric.Option) *fabric.FabricClient {
+	t.Helper()
+
+	f, err := fabric.NewFabricClient("127.0.0.1:1", opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return f
+}
+
+// run runs a command line with the output format and returns its output.
+func run(t *testing.T, f *fabric.FabricClient, format string, args ...string) (string, error) {
+	t.Helper()
+
+	buf := &bytes.Buffer{}
+	saved := stdout
+	stdout, outputFormat = buf, format
+	defer func() { stdout, outputFormat = saved, formatTable }()
+
+	err := runCommand(f, args)
+	return buf.String(), err
+}
+
+// runJson runs a command line with -o json and decodes its output.
+func runJson(t *testing.T, f *fabric.FabricClient, out interface{}, args ...string) {
+	t.Helper()
+
+	s, err := run(t, f, formatJson, args...)
+	if err != nil {
+		t.Fatalf("%v: %v", args, err)
+	}
+	if err := json.Unmarshal([]byte(s), out); err != nil {
+		t.Fatalf("%v: %v\n%s", args, err, s)
+	}
+}
+
+func TestWalletNew(t *testing.T) {
+	v := walletView{}
+	runJson(t, noServer(t), &v, "wallet", "new")
+
+	pubKey, err := util.GetPubKeyByPrivKey(v.PrivKey)
+	if err != nil || pubKey != v.PubKey || util.GetAddress(pubKey) != v.Address {
+		t.Errorf("wallet = %+v", v)
+	}
+
+	shown := walletView{}
+	runJson(t, noServer(t), &shown, "wallet", "show", "-key", v.PrivKey)
+	if shown.Address != v.Address || shown.PrivKey != "" {
+		t.Errorf("wallet show = %+v", shown)
+	}
+}
+
+func TestWalletNetwork(t *testing.T) {
+	f := noServer(t, fabric.WithNetwork(util.TestNet))
+
+	v := walletView{}
+	runJson(t, f, &v, "wallet", "new")
+	if addr, err := util.TestNet.Address(v.PubKey); err != nil || addr != v.Address {
+		t.Errorf("testnet wallet = %+v", v)
+	}
+
+	saved := *keystoreDir
+	*keystoreDir = t.TempDir()
+	defer func() { *keystoreDir = saved }()
+	t.Setenv(passphraseEnv, "secret")
+
+	runJson(t, f, &keystore.Entry{}, "wallet", "import", "-name", "alice", "-key", v.PrivKey)
+	if _, err := run(t, noServer(t), formatTable, "wallet", "import", "-name", "bob", "-key", v.PrivKey); err == nil {
+		t.Error("import of a testnet key on mainnet succeeded")
+	}
+}
+
+func TestTokenCommands(t *testing.T) {
+	f, s := newClient(t)
+	defer s.Close()
+
+	priv, _, addr := util.GetNewAddress()
+	_, _, to := util.GetNewAddress()
+	t.Setenv(keyEnv, priv)
+
+	info := fabric.TokenInfo{}
+	runJson(t, f, &info, "token", "issue", "-name", "OCE", "-total", "100")
+	if info.TokenID == "" || info.Address != addr {
+		t.Fatalf("issued %+v", info)
+	}
+
+	got := fabric.TokenInfo{}
+	runJson(t, f, &got, "token", "info", info.TokenID)
+	if got.TotalNumber != "100" || got.TokenName != "OCE" {
+		t.Errorf("token info = %+v", got)
+	}
+
+	tx := txView{}
+	runJson(t, f, &tx, "transfer", "-token", info.TokenID, "-to", to, "-amount", "30", "-wait")
+	if tx.TxID == "" || tx.Status != "committed" {
+		t.Errorf("transfer = %+v", tx)
+	}
+
+	rows := []balanceRow{}
+	runJson(t, f, &rows, "balance", "-token", info.TokenID, addr, to)
+	if len(rows) != 2 || rows[0].Amount != "70" || rows[1].Amount != "30" {
+		t.Errorf("balance = %+v", rows)
+	}
+
+	out, err := run(t, f, formatTable, "balance", to)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(out), "\n")
+	if len(lines) != 2 || strings.Fields(lines[0])[0] != "ADDRESS" ||
+		strings.Join(strings.Fields(lines[1]), " ") != to+" "+info.TokenID+" 30" {
+		t.Errorf("balance table:\n%s", out)
+	}
+
+	rec := recordView{}
+	runJson(t, f, &rec, "tx", "show", tx.TxID)
+	if rec.Status != "committed" || rec.Transfer == nil || rec.Transfer.ToAddress != to {
+		t.Errorf("tx show = %+v", rec)
+	}
+
+	out, err = run(t, f, formatTable, "tx", "wait", tx.TxID)
+	if err != nil || !strings.Contains(out, "committed") {
+		t.Errorf("tx wait: %v\n%s", err, out)
+	}
+}
+
+func TestCommandErrors(t *testing.T) {
+	f, s := newClient(t)
+	defer s.Close()
+	t.Setenv(keyEnv, "")
+
+	for _, args := range [][]string{
+		{"wallet"},
+		{"wallet", "delete"},
+		{"nothing"},
+		{"transfer", "-token", "t", "-to", "a", "-amount", "1"},
+		{"transfer", "-token", "t"},
+		{"balance"},
+		{"tx", "wait", "-timeout", "10ms", "missing"},
+	} {
+		if _, err := run(t, f, formatTable, args...); err == nil {
+			t.Errorf("%v succeeded", args)
+		}
+	}
+}
+
+func TestWalletKeystore(t *testing.T) {
+	f, s := newClient(t)
+	defer s.Close()
+
+	saved := *keystoreDir
+	*keystoreDir = t.TempDir()
+	defer func() { *keystoreDir = saved }()
+	t.Setenv(passphraseEnv, "secret")
+
+	created := walletView{}
+	runJson(t, noServer(t), &created, "wallet", "new", "-name", "alice")
+	if created.PrivKey != "" {
+		t.Errorf("stored wallet printed its key: %+v", created)
+	}
+
+	priv, _, addr := util.GetNewAddress()
+	runJson(t, noServer(t), &keystore.Entry{}, "wallet", "import", "-name", "bob", "-key", priv)
+
+	list := []keystore.Entry{}
+	runJson(t, noServer(t), &list, "wallet", "list")
+	if len(list) != 2 || list[0] != (keystore.Entry{Name: "alice", Address: created.Address}) || list[1] != (keystore.Entry{Name: "bob", Address: addr}) {
+		t.Errorf("wallet list = %+v", list)
+	}
+
+	exported := walletView{}
+	runJson(t, noServer(t), &exported, "wallet", "export", "-name", "bob")
+	if exported.PrivKey != priv {
+		t.Errorf("wallet export = %+v", exported)
+	}
+
+	// a stored wallet signs
+	info := fabric.TokenInfo{}
+	runJson(t, f, &info, "token", "issue", "-wallet", "alice", "-name", "OCE", "-total", "5")
+	if info.Address != created.Address {
+		t.Errorf("issued %+v", info)
+	}
+
+	t.Setenv(passphraseEnv, "guess")
+	if _, err := run(t, f, formatTable, "wallet", "export", "-name", "bob"); err != keystore.ErrPassphrase {
+		t.Errorf("export with a wrong passphrase: %v", err)
+	}
+
+	if _, err := run(t, noServer(t), formatTable, "wallet", "delete", "-name", "bob"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := run(t, noServer(t), formatTable, "wallet", "delete", "-name", "bob"); err != keystore.ErrNotFound {
+		t.Errorf("second delete: %v", err)
+	}
+}
+
+func TestHD(t *testing.T) {
+	f, s := newClient(t)
+	defer s.Close()
+
+	saved := *keystoreDir
+	*keystoreDir = t.TempDir()
+	defer func() { *keystoreDir = saved }()
+	t.Setenv(passphraseEnv, "secret")
+
+	created := map[string]string{}
+	runJson(t, noServer(t), &created, "hd", "new")
+	t.Setenv(mnemonicEnv, created["mnemonic"])
+
+	derived := []derivedView{}
+	runJson(t, noServer(t), &derived, "hd", "derive", "-n", "3", "-keys")
+	if len(derived) != 3 || derived[2].Path != "m/44'/0'/0'/0/2" || derived[0].PrivKey == "" {
+		t.Fatalf("hd derive = %+v", derived)
+	}
+
+	again := []derivedView{}
+	runJson(t, noServer(t), &again, "hd", "derive", "-from", "1", "-n", "2")
+	if len(again) != 2 || again[0].Address != derived[1].Address || again[0].PrivKey != "" {
+		t.Errorf("hd derive -from 1 = %+v", again)
+	}
+
+	imported := derivedView{}
+	runJson(t, noServer(t), &imported, "hd", "import", "-name", "hd1", "-index", "1")
+	exported := walletView{}
+	runJson(t, noServer(t), &exported, "wallet", "export", "-name", "hd1")
+	if imported.Address != derived[1].Address || exported.PrivKey != derived[1].PrivKey {
+		t.Errorf("hd import = %+v, exported %+v", imported, exported)
+	}
+
+	// funds left in derived wallets come back
+	info := fabric.TokenInfo{}
+	runJson(t, f, &info, "token", "issue", "-key", derived[1].PrivKey, "-name", "OCE", "-total", "50")
+	runJson(t, f, &txView{}, "transfer", "-key", derived[1].PrivKey, "-token", info.TokenID, "-to", derived[2].Address, "-amount", "20", "-wait")
+
+	swept := []load.Swept{}
+	runJson(t, f, &swept, "hd", "sweep", "-to", derived[0].Address, "-n", "3")
+	if len(swept) != 2 || s.Balance(derived[0].Address, info.TokenID) != "50" {
+		t.Errorf("hd sweep = %+v", swept)
+	}
+
+	if _, err := run(t, noServer(t), formatTable, "hd", "derive", "-path", "44'/0'"); err == nil {
+		t.Error("derive with an invalid path succeeded")
+	}
+	t.Setenv(mnemonicEnv, "not a mnemonic")
+	if _, err := run(t, noServer(t), formatTable, "hd", "derive"); err != hdwallet.ErrMnemonic {
+		t.Errorf("derive with an invalid mnemonic: %v", err)
+	}
+}
+
+func TestNetworkOf(t *testing.T) {
+	for conf, want := range map[string]string{
+		"":                          "mainnet/p2pkh",
+		"[network]\nName = testnet": "testnet3/p2pkh",
+		"[network]\nName = regtest\nAddressType = pubkey":                       "regtest/pubkey",
+		"[network]\nName = custom\nPubKeyHashAddrID = 0x41\nPrivateKeyID = 193": "custom/p2pkh",
+		"[network]\nName = custom\nPubKeyHashAddrID = 300\nPrivateKeyID = 1":    "",
+		"[network]\nName = custom":                                              "",
+		"[network]\nName = simnet":                                              "",
+		"[network]\nAddressType = p2sh":                                         "",
+	} {
+		cfg, err := ini.Load([]byte(conf))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		n, err := networkOf(cfg)
+		if want == "" {
+			if err == nil {
+				t.Errorf("%q: network %s", conf, n)
+			}
+			continue
+		}
+		if err != nil || n.String() != want {
+			t.Errorf("%q: network %v, %v", conf, n, err)
+		}
+	}
+
+	cfg, _ := ini.Load([]byte("[network]\nName = custom\nPubKeyHashAddrID = 0x41\nPrivateKeyID = 193"))
+	n, _ := networkOf(cfg)
+	if n.Params.PubKeyHashAddrID != 0x41 || n.Params.PrivateKeyID != 193 {
+		t.Errorf("custom params %x %x", n.Params.PubKeyHashAddrID, n.Params.PrivateKeyID)
+	}
+}
@@ -0,0 +1,419 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"sort"
+	"strings"
+	"time"
+
+	"fabricclient/audit"
+	"fabricclient/fabric"
+	"fabricclient/load"
+	"fabricclient/scenario"
+	"fabricclient/selftest"
+	"fabricclient/shell"
+)
+
+// adminTimeout bounds a lifecycle command, instantiate builds the
+// chaincode image and may take minutes.
+const adminTimeout = 5 * time.Minute
+
+type command struct {
+	usage string
+	run   func(f *fabric.FabricClient, args []string) error
+}
+
+// commands are the "<group> <name>" subcommands of the client.
+// A command named "" runs as "<group>" alone.
+var commands = map[string]map[string]command{
+	"wallet": {
+		"new":    {"[-name alice]", walletNew},
+		"show":   {"[-wallet name | -key wif]", walletShow},
+		"list":   {"", walletList},
+		"import": {"-name alice [-key wif]", walletImport},
+		"export": {"-name alice", walletExport},
+		"delete": {"-name alice", walletDelete},
+	},
+	"token": {
+		"issue": {"[-wallet name | -key wif] -name OCE -total 10000", tokenIssue},
+		"info":  {"tokenID", tokenInfo},
+	},
+	"transfer": {
+		"": {"[-wallet name | -key wif] -token id -to address -amount 10 [-wait] [-timeout 1m]", transfer},
+	},
+	"balance": {
+		"": {"[-token id] address...", balance},
+	},
+	"tx": {
+		"show": {"[-channel mychannel [-peer peer0.org1.example.com]] txID", txShow},
+		"wait": {"[-timeout 1m] txID", txWait},
+	},
+	"hd": {
+		"new":    {"[-bits 128]", hdNew},
+		"derive": {"[-path m/44'/0'/0'/0] [-from 0] [-n 1] [-keys]", hdDerive},
+		"import": {"-name alice [-path m/44'/0'/0'/0] [-index 0]", hdImport},
+		"sweep":  {"-to address [-token id] [-path m/44'/0'/0'/0] [-from 0] [-n 100]", hdSweep},
+	},
+	"shell": {
+		"": {"", shellRun},
+	},
+	"channel": {
+		"create": {"-name mychannel -config ../artifacts/channel/mychannel.tx", channelCreate},
+		"join":   {"-name mychannel [-peers peer0.org1.example.com,...]", channelJoin},
+		"anchor": {"-name mychannel -config ../artifacts/channel/Org1MSPanchors.tx", channelAnchor},
+	},
+	"chaincode": {
+		"install":     {"-name mycc -path github.com/example_cc/go -version v0 [-type golang] [-peers ...]", chaincodeInstall},
+		"instantiate": {"-channel mychannel -name mycc -version v0 [-type golang] [-fcn init] [-peers ...] [args...]", chaincodeInstantiate},
+		"upgrade":     {"-channel mychannel -name mycc -version v1 [-type golang] [-fcn init] [-peers ...] [args...]", chaincodeUpgrade},
+		"invoke":      {"-channel mychannel -name mycc -fcn move [-peers ...] [args...]", chaincodeInvoke},
+		"query":       {"-channel mychannel -name mycc -fcn query [-peer ...] [args...]", chaincodeQuery},
+	},
+	"scenario": {
+		"run": {"conf/scenario/transfer.yaml [file...]", scenarioRun},
+	},
+	"load": {
+		"run": {"[-workload transfer|query|issue] [-c 50] [-tps 0] [-duration 30s] [-n 0] [-warmup 0] [-token id -wallet name | -key wif] [-hd [-path m/44'/0'/0'/0]] [-audit] [-parallel 16]", loadRun},
+	},
+}
+
+var errUsage = errors.New("usage")
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "usage: fabricclient [global flags] [<group> [<command>] [flags]]")
+	fmt.Fprintln(os.Stderr, "runs the self test without a command")
+	fmt.Fprintln(os.Stderr, "global flags:")
+	flag.PrintDefaults()
+	fmt.Fprintln(os.Stderr, "commands:")
+
+	groups := []string{}
+	for g := range commands {
+		groups = append(groups, g)
+	}
+	sort.Strings(groups)
+
+	for _, g := range groups {
+		names := []string{}
+		for n := range commands[g] {
+			names = append(names, n)
+		}
+		sort.Strings(names)
+
+		for _, n := range names {
+			line := strings.Join(strings.Fields(g+" "+n+" "+commands[g][n].usage), " ")
+			fmt.Fprintln(os.Stderr, "  "+line)
+		}
+	}
+}
+
+// runCommand runs the subcommand named by args.
+func runCommand(f *fabric.FabricClient, args []string) error {
+	if len(args) == 0 {
+		usage()
+		return errUsage
+	}
+
+	group := commands[args[0]]
+
+	cmd, ok := group[""]
+	args = args[1:]
+	if !ok && len(args) > 0 {
+		cmd, ok = group[args[0]]
+		args = args[1:]
+	}
+	if !ok {
+		usage()
+		return errUsage
+	}
+
+	err := cmd.run(f, args)
+	if err == flag.ErrHelp {
+		return nil
+	}
+	return err
+}
+
+func peerList(s string) []string {
+	if s == "" {
+		return nil
+	}
+	return strings.Split(s, ",")
+}
+
+// required reports the first empty flag of names.
+func required(fs *flag.FlagSet, names ...string) error {
+	for _, n := range names {
+		if fs.Lookup(n).Value.String() == "" {
+			return fmt.Errorf("%s: -%s is required", fs.Name(), n)
+		}
+	}
+	return nil
+}
+
+func channelCreate(f *fabric.FabricClient, args []string) error {
+	fs := flag.NewFlagSet("channel create", flag.ContinueOnError)
+	name := fs.String("name", "", "channel name")
+	config := fs.String("config", "", "channel transaction path on the rest server")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if err := required(fs, "name", "config"); err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), adminTimeout)
+	defer cancel()
+
+	return f.Chaincode().CreateChannel(ctx, *name, *config)
+}
+
+func channelJoin(f *fabric.FabricClient, args []string) error {
+	fs := flag.NewFlagSet("channel join", flag.ContinueOnError)
+	name := fs.String("name", "", "channel name")
+	peers := fs.String("peers", "", "comma separated peers, [rest] Peers if empty")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if err := required(fs, "name"); err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), adminTimeout)
+	defer cancel()
+
+	return f.Chaincode().JoinChannel(ctx, *name, peerList(*peers))
+}
+
+func channelAnchor(f *fabric.FabricClient, args []string) error {
+	fs := flag.NewFlagSet("channel anchor", flag.ContinueOnError)
+	name := fs.String("name", "", "channel name")
+	config := fs.String("config", "", "anchor peer update path on the rest server")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if err := required(fs, "name", "config"); err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), adminTimeout)
+	defer cancel()
+
+	return f.Chaincode().UpdateAnchorPeers(ctx, *name, *config)
+}
+
+// specFlags declares the chaincode flags shared by install, instantiate
+// and upgrade.
+func specFlags(fs *flag.FlagSet, spec *fabric.ChaincodeSpec) {
+	fs.StringVar(&spec.Name, "name", "", "chaincode name")
+	fs.StringVar(&spec.Version, "version", "", "chaincode version")
+	fs.StringVar(&spec.Type, "type", fabric.DefaultChaincodeType, "golang, node or java")
+}
+
+func chaincodeInstall(f *fabric.FabricClient, args []string) error {
+	fs := flag.NewFlagSet("chaincode install", flag.ContinueOnError)
+	spec := fabric.ChaincodeSpec{}
+	specFlags(fs, &spec)
+	fs.StringVar(&spec.Path, "path", "", "chaincode path on the rest server")
+	peers := fs.String("peers", "", "comma separated peers, [rest] Peers if empty")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if err := required(fs, "name", "path", "version"); err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), adminTimeout)
+	defer cancel()
+
+	return f.Chaincode().InstallChaincode(ctx, spec, peerList(*peers))
+}
+
+func chaincodeInstantiate(f *fabric.FabricClient, args []string) error {
+	return instantiate(f, "chaincode instantiate", args, (*fabric.ChaincodeClient).InstantiateChaincode)
+}
+
+// chaincodeUpgrade needs a rest server with an upgrade route, see
+// fabric.ChaincodeClient.UpgradeChaincode.
+func chaincodeUpgrade(f *fabric.FabricClient, args []string) error {
+	return instantiate(f, "chaincode upgrade", args, (*fabric.ChaincodeClient).UpgradeChaincode)
+}
+
+func instantiate(f *fabric.FabricClient, name string, args []string,
+	call func(*fabric.ChaincodeClient, context.Context, string, fabric.ChaincodeSpec, string, []string, []string) error) error {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	spec := fabric.ChaincodeSpec{}
+	specFlags(fs, &spec)
+	channel := fs.String("channel", "", "channel name")
+	fcn := fs.String("fcn", "", "init function, Init if empty")
+	peers := fs.String("peers", "", "comma separated peers, [rest] Peers if empty")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if err := required(fs, "channel", "name", "version"); err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), adminTimeout)
+	defer cancel()
+
+	return call(f.Chaincode(), ctx, *channel, spec, *fcn, fs.Args(), peerList(*peers))
+}
+
+func loadRun(f *fabric.FabricClient, args []string) error {
+	fs := flag.NewFlagSet("load run", flag.ContinueOnError)
+	cfg := load.Config{}
+	workload := fs.String("workload", string(load.Transfer), "transfer, query or issue")
+	fs.IntVar(&cfg.Concurrency, "c", 50, "requests in flight")
+	fs.Float64Var(&cfg.TPS, "tps", 0, "target requests per second, 0 for a closed loop")
+	fs.DurationVar(&cfg.Duration, "duration", 30*time.Second, "measured duration")
+	fs.IntVar(&cfg.Count, "n", 0, "measured requests, 0 for no limit")
+	fs.DurationVar(&cfg.Warmup, "warmup", 0, "unmeasured load before the run")
+	fs.DurationVar(&cfg.Timeout, "timeout", load.DefaultTimeout, "request timeout")
+	fs.IntVar(&cfg.Wallets, "wallets", 0, "wallets used, -c if 0")
+	fs.StringVar(&cfg.Amount, "amount", "1", "transfer amount")
+	fs.StringVar(&cfg.Fund, "fund", "10", "units funded per wallet")
+	fs.BoolVar(&cfg.WaitCommit, "commit", false, "wait for the commit of transfers")
+	fs.StringVar(&cfg.TokenID, "token", "", "token transferred, the self test token if empty")
+	funder := signerFlags(fs)
+	hd := fs.Bool("hd", false, "derive the wallets from $"+mnemonicEnv+" at -path so that hd sweep recovers their funds")
+	sd := seedFlags(fs)
+	check := fs.Bool("audit", false, "check the balances of the ring after a transfer load")
+	parallel := fs.Int("parallel", audit.DefaultParallel, "balance queries in flight for -audit")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	cfg.Workload = load.Workload(*workload)
+
+	if cfg.Workload == load.Transfer && (cfg.TokenID == "" || *funder.key == "" && *funder.wallet == "") {
+		tp, err := selftestParam(f)
+		if err != nil {
+			return fmt.Errorf("load run: -token and -wallet or -key are required without the self test tokens: %v", err)
+		}
+		cfg.TokenID, cfg.Funder = tp.TokenID1, tp.Token1Wallet
+	} else if cfg.Workload == load.Transfer {
+		w, err := funder.resolve(f)
+		if err != nil {
+			return err
+		}
+		cfg.Funder = w
+	}
+
+	if *hd {
+		var err error
+		cfg.HD, cfg.HDPath, err = sd.open(f)
+		if err != nil {
+			return err
+		}
+	}
+
+	if *check && cfg.Workload == load.Transfer {
+		cfg.Audit = audit.NewChecker()
+	}
+
+	g, err := load.New(f, cfg)
+	if err != nil {
+		return err
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	report, err := g.Run(ctx)
+	if err != nil {
+		return err
+	}
+
+	fmt.Print(report)
+
+	if cfg.Audit == nil {
+		return nil
+	}
+
+	// the balances are checked even after an interrupt
+	ar, err := cfg.Audit.Verify(context.Background(), f, *parallel)
+	if err != nil {
+		return err
+	}
+
+	fmt.Print(ar)
+	if !ar.OK() {
+		return errors.New("load run: audit failed")
+	}
+
+	return nil
+}
+
+// selftestParam loads the self test tokens and wallets.
+func selftestParam(f *fabric.FabricClient) (*selftest.TestParam, error) {
+	ks, err := openKeystore(f.Network())
+	if err != nil {
+		return nil, err
+	}
+
+	passphrase, err := readPassphrase("keystore passphrase: ")
+	if err != nil {
+		return nil, err
+	}
+
+	return selftest.LoadParam(selftest.DefaultParamFile, ks, passphrase)
+}
+
+func shellRun(f *fabric.FabricClient, args []string) error {
+	fs := flag.NewFlagSet("shell", flag.ContinueOnError)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	ks, err := openKeystore(f.Network())
+	if err != nil {
+		return err
+	}
+
+	// the shell asks on its own terminal unless the passphrase is set
+	var passphrase func(string) (string, error)
+	if _, ok := os.LookupEnv(passphraseEnv); ok {
+		passphrase = readPassphrase
+	}
+
+	sh := shell.New(f, stdout)
+	err = sh.SetKeystore(ks, passphrase)
+	if err != nil {
+		return err
+	}
+
+	return sh.Run(context.Background())
+}
+
+func scenarioRun(f *fabric.FabricClient, args []string) error {
+	if len(args) == 0 {
+		return errors.New("scenario run: no scenario file")
+	}
+
+	failed := 0
+	r := scenario.NewRunner(f)
+
+	for _, path := range args {
+		sc, err := scenario.Load(path)
+		if err != nil {
+			return err
+		}
+
+		report := r.Run(context.Background(), sc)
+		fmt.Print(report)
+
+		if !report.Passed() {
+			failed++
+		}
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("%d of %d scenarios failed", failed, len(args))
+	}
+
+	return nil
+}
@@ -0,0 +1,193 @@
+package fabric
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+)
+
+const (
+	DefaultChaincodeType = "golang"
+)
+
+// ChaincodeSpec identifies a chaincode package for install, instantiate
+// and upgrade.
+type ChaincodeSpec struct {
+	Name    string
+	Path    string
+	Version string
+	// Type is golang, node or java, golang if empty.
+	Type string
+}
+
+func (s ChaincodeSpec) ccType() string {
+	if s.Type == "" {
+		return DefaultChaincodeType
+	}
+	return s.Type
+}
+
+// admin posts an administration request and returns the message of the
+// response.
+func (c *ChaincodeClient) admin(ctx context.Context, endpoint string, req interface{}) (string, error) {
+	data, err := json.Marshal(req)
+	if err != nil {
+		c.log.Error(err)
+		return "", err
+	}
+
+	body, err := c.do(ctx, "POST", endpoint, data)
+	if err != nil {
+		return "", err
+	}
+
+	msg, err := decodeAdmin(body)
+	if err != nil {
+		apiErr := &APIError{Kind: rejectKind(err.Error()), Endpoint: endpoint, Message: err.Error(), Body: body}
+		c.log.Error(apiErr)
+		return "", apiErr
+	}
+
+	c.log.Info(msg)
+
+	return msg, nil
+}
+
+// decodeAdmin returns the message of an administration response, a
+// {"success":true,"message":...} object or a plain text sentence. The rest
+// server reports some failures as a 200 with a "Failed to ..." sentence.
+func decodeAdmin(body []byte) (string, error) {
+	s := strings.TrimSpace(string(body))
+
+	res := struct {
+		Success *bool  `json:"success"`
+		Message string `json:"message"`
+	}{}
+
+	var str string
+	if json.Unmarshal(body, &str) == nil {
+		s = strings.TrimSpace(str)
+	} else if json.Unmarshal(body, &res) == nil && res.Success != nil {
+		return res.Message, nil
+	}
+
+	lower := strings.ToLower(s)
+	if s == "" || strings.Contains(lower, "fail") || strings.Contains(lower, "error") {
+		if s == "" {
+			s = "empty response"
+		}
+		return "", errors.New(s)
+	}
+
+	return s, nil
+}
+
+// CreateChannel creates channel from the channel transaction at
+// configPath, a path on the rest server.
+func (c *ChaincodeClient) CreateChannel(ctx context.Context, channel, configPath string) error {
+	type ChannelReq struct {
+		ChannelName       string `json:"channelName"`
+		ChannelConfigPath string `json:"channelConfigPath"`
+	}
+
+	_, err := c.admin(ctx, "/channels", &ChannelReq{
+		ChannelName:       channel,
+		ChannelConfigPath: configPath,
+	})
+	return err
+}
+
+// JoinChannel joins peers, the client's peers if empty, to channel.
+func (c *ChaincodeClient) JoinChannel(ctx context.Context, channel string, peers []string) error {
+	if len(peers) == 0 {
+		peers = c.peers
+	}
+
+	type JoinReq struct {
+		Peers []string `json:"peers"`
+	}
+
+	_, err := c.admin(ctx, channelPath(channel)+"/peers", &JoinReq{Peers: peers})
+	return err
+}
+
+// UpdateAnchorPeers applies the anchor peer update at configPath, a path
+// on the rest server, to channel.
+func (c *ChaincodeClient) UpdateAnchorPeers(ctx context.Context, channel, configPath string) error {
+	type AnchorReq struct {
+		ConfigUpdatePath string `json:"configUpdatePath"`
+	}
+
+	_, err := c.admin(ctx, channelPath(channel)+"/anchorpeers", &AnchorReq{ConfigUpdatePath: configPath})
+	return err
+}
+
+// InstallChaincode installs spec on peers, the client's peers if empty.
+func (c *ChaincodeClient) InstallChaincode(ctx context.Context, spec ChaincodeSpec, peers []string) error {
+	if len(peers) == 0 {
+		peers = c.peers
+	}
+
+	type InstallReq struct {
+		Peers            []string `json:"peers"`
+		ChaincodeName    string   `json:"chaincodeName"`
+		ChaincodePath    string   `json:"chaincodePath"`
+		ChaincodeVersion string   `json:"chaincodeVersion"`
+		ChaincodeType    string   `json:"chaincodeType"`
+	}
+
+	_, err := c.admin(ctx, "/chaincodes", &InstallReq{
+		Peers:            peers,
+		ChaincodeName:    spec.Name,
+		ChaincodePath:    spec.Path,
+		ChaincodeVersion: spec.Version,
+		ChaincodeType:    spec.ccType(),
+	})
+	return err
+}
+
+type instantiateReq struct {
+	Peers            []string `json:"peers"`
+	ChaincodeName    string   `json:"chaincodeName"`
+	ChaincodeVersion string   `json:"chaincodeVersion"`
+	ChaincodeType    string   `json:"chaincodeType"`
+	Fcn              string   `json:"fcn,omitempty"`
+	Args             []string `json:"args"`
+}
+
+func (c *ChaincodeClient) instantiate(ctx context.Context, endpoint string, spec ChaincodeSpec, fcn string, args []string, peers []string) error {
+	if len(peers) == 0 {
+		peers = c.peers
+	}
+	if args == nil {
+		args = []string{}
+	}
+
+	_, err := c.admin(ctx, endpoint, &instantiateReq{
+		Peers:            peers,
+		ChaincodeName:    spec.Name,
+		ChaincodeVersion: spec.Version,
+		ChaincodeType:    spec.ccType(),
+		Fcn:              fcn,
+		Args:             args,
+	})
+	return err
+}
+
+// InstantiateChaincode instantiates the installed spec on channel, calling
+// fcn, Init if empty, with args. peers endorse the instantiation, the
+// client's peers if empty.
+func (c *ChaincodeClient) InstantiateChaincode(ctx context.Context, channel string, spec ChaincodeSpec, fcn string, args []string, peers []string) error {
+	return c.instantiate(ctx, channelPath(channel)+"/chaincodes", spec, fcn, args, peers)
+}
+
+// UpgradeChaincode upgrades the chaincode of channel to the installed
+// spec, calling fcn with args like InstantiateChaincode. The fabric rest
+// sample has no upgrade route: this posts the instantiate request to
+// /channels/{channel}/chaincodes/{name}/upgrade, for a rest server adding
+// that route over the sdk's sendUpgradeProposal. The sample itself
+// answers it with an ErrHTTPStatus 404.
+func (c *ChaincodeClient) UpgradeChaincode(ctx context.Context, channel string, spec ChaincodeSpec, fcn string, args []string, peers []string) error {
+	return c.instantiate(ctx, chaincodePath(channel, spec.Name)+"/upgrade", spec, fcn, args, peers)
+}
@@ -0,0 +1,130 @@
+package fabric
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+)
+
+func TestLifecycle(t *testing.T) {
+	s := newRestServer(replyWith(`{"success":true,"message":"done"}`))
+	defer s.Close()
+
+	c := newTestChaincode(t, s)
+	ctx := context.Background()
+	spec := ChaincodeSpec{Name: "mycc", Path: "github.com/example_cc/go", Version: "v0"}
+
+	for _, step := range []struct {
+		call func() error
+		path string
+		body map[string]interface{}
+	}{
+		{
+			func() error { return c.CreateChannel(ctx, "mychannel", "../artifacts/channel/mychannel.tx") },
+			"/channels",
+			map[string]interface{}{"channelName": "mychannel", "channelConfigPath": "../artifacts/channel/mychannel.tx"},
+		},
+		{
+			func() error { return c.JoinChannel(ctx, "mychannel", []string{"peer1"}) },
+			"/channels/mychannel/peers",
+			map[string]interface{}{},
+		},
+		{
+			func() error { return c.UpdateAnchorPeers(ctx, "mychannel", "../artifacts/channel/Org1MSPanchors.tx") },
+			"/channels/mychannel/anchorpeers",
+			map[string]interface{}{"configUpdatePath": "../artifacts/channel/Org1MSPanchors.tx"},
+		},
+		{
+			func() error { return c.InstallChaincode(ctx, spec, nil) },
+			"/chaincodes",
+			map[string]interface{}{"chaincodeName": "mycc", "chaincodePath": "github.com/example_cc/go", "chaincodeVersion": "v0", "chaincodeType": "golang"},
+		},
+		{
+			func() error { return c.InstantiateChaincode(ctx, "mychannel", spec, "init", []string{"a", "100"}, nil) },
+			"/channels/mychannel/chaincodes",
+			map[string]interface{}{"chaincodeName": "mycc", "chaincodeVersion": "v0", "fcn": "init"},
+		},
+		{
+			func() error {
+				spec.Version = "v1"
+				return c.UpgradeChaincode(ctx, "mychannel", spec, "", nil, nil)
+			},
+			"/channels/mychannel/chaincodes/mycc/upgrade",
+			map[string]interface{}{"chaincodeName": "mycc", "chaincodeVersion": "v1"},
+		},
+	} {
+		if err := step.call(); err != nil {
+			t.Fatalf("%s: %v", step.path, err)
+		}
+
+		r := s.last()
+		if r.Method != "POST" || r.Path != step.path {
+			t.Errorf("sent %s %s, want POST %s", r.Method, r.Path, step.path)
+		}
+		if r.Auth == "" {
+			t.Errorf("%s: no Authorization header", step.path)
+		}
+		for k, v := range step.body {
+			if r.Body[k] != v {
+				t.Errorf("%s: %s = %v, want %v", step.path, k, r.Body[k], v)
+			}
+		}
+	}
+
+	// the client's peers by default
+	c.InstallChaincode(ctx, spec, nil)
+	if peers, _ := s.last().Body["peers"].([]interface{}); len(peers) != len(DefaultPeers) {
+		t.Errorf("InstallChaincode() peers = %v", s.last().Body["peers"])
+	}
+
+	// args are always sent, the sample fails on a missing array
+	c.UpgradeChaincode(ctx, "mychannel", spec, "", nil, nil)
+	if args, ok := s.last().Body["args"].([]interface{}); !ok || len(args) != 0 {
+		t.Errorf("UpgradeChaincode() args = %v", s.last().Body["args"])
+	}
+}
+
+func TestLifecycleFailed(t *testing.T) {
+	for _, reply := range []string{
+		`{"success":false,"message":"Failed to create the channel 'mychannel'"}`,
+		"Failed to install due to:Error: chaincode exists",
+		`"Failed to send Proposal or receive valid response"`,
+		"",
+	} {
+		s := newRestServer(replyWith(reply))
+		c := newTestChaincode(t, s)
+
+		err := c.CreateChannel(context.Background(), "mychannel", "a.tx")
+		if !errors.Is(err, ErrRejected) {
+			t.Errorf("reply %q: err = %v, want ErrRejected", reply, err)
+		}
+
+		s.Close()
+	}
+
+	s := newRestServer(func(w http.ResponseWriter, r *recorded) {
+		w.WriteHeader(http.StatusInternalServerError)
+	})
+	defer s.Close()
+
+	err := newTestChaincode(t, s).JoinChannel(context.Background(), "mychannel", nil)
+	if !errors.Is(err, ErrHTTPStatus) {
+		t.Errorf("JoinChannel() err = %v, want ErrHTTPStatus", err)
+	}
+}
+
+func TestDecodeAdmin(t *testing.T) {
+	for body, want := range map[string]string{
+		`{"success":true,"message":"Channel 'mychannel' created Successfully"}`: "Channel 'mychannel' created Successfully",
+		"Successfully installed chaincode\n":                                    "Successfully installed chaincode",
+		`"Successfully joined peers"`:                                           "Successfully joined peers",
+		"Failed to instantiate":                                                 "",
+		"Error: timeout":                                                        "",
+	} {
+		got, err := decodeAdmin([]byte(body))
+		if got != want || (want == "") != (err != nil) {
+			t.Errorf("decodeAdmin(%q) = %q, %v, want %q", body, got, err, want)
+		}
+	}
+}
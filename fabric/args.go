@@ -0,0 +1,57 @@
+package fabric
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"regexp"
+	"strings"
+)
+
+// EncodeArgs encodes chaincode query args as the json array expected in
+// the args parameter. The rest server turns every ' into " before parsing
+// it, so single quotes inside args are escaped as \u0027.
+func EncodeArgs(args []string) (string, error) {
+	if args == nil {
+		args = []string{}
+	}
+
+	data, err := json.Marshal(args)
+	if err != nil {
+		return "", err
+	}
+
+	return strings.Replace(string(data), "'", `\u0027`, -1), nil
+}
+
+// BinaryArg encodes binary data as a base64 arg. The chaincode has to
+// decode it.
+func BinaryArg(b []byte) string {
+	return base64.StdEncoding.EncodeToString(b)
+}
+
+// DecodeBinary decodes a base64 payload or arg.
+func DecodeBinary(s string) ([]byte, error) {
+	return base64.StdEncoding.DecodeString(strings.TrimSpace(s))
+}
+
+// samplePayload matches the sentence balance-transfer wraps payloads in.
+var samplePayload = regexp.MustCompile(`(?s)^.* now has (.*) after the move$`)
+
+// DecodeQueryPayload returns the chaincode payload of a query response.
+// The rest server answers either the bare payload, plain text or json, a
+// json string, or the "<arg> now has <payload> after the move" sentence
+// of the balance-transfer sample.
+func DecodeQueryPayload(body []byte) []byte {
+	s := strings.TrimSpace(string(body))
+
+	var str string
+	if strings.HasPrefix(s, `"`) && json.Unmarshal([]byte(s), &str) == nil {
+		s = str
+	}
+
+	if m := samplePayload.FindStringSubmatch(s); m != nil {
+		s = m[1]
+	}
+
+	return []byte(s)
+}
@@ -0,0 +1,87 @@
+package fabric
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestEncodeArgs(t *testing.T) {
+	for _, args := range [][]string{
+		nil,
+		{"a"},
+		{`say "hi"`, "with space", "ünïcödé", "a&fcn=move&b=1"},
+		{"it's", "'quoted'"},
+	} {
+		s, err := EncodeArgs(args)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if strings.Contains(s, "'") {
+			t.Errorf("EncodeArgs(%q) = %s, contains a single quote", args, s)
+		}
+
+		// what the rest server does before parsing
+		var got []string
+		if err := json.Unmarshal([]byte(strings.Replace(s, "'", `"`, -1)), &got); err != nil {
+			t.Fatalf("EncodeArgs(%q) = %s: %v", args, s, err)
+		}
+		if len(got) != len(args) {
+			t.Fatalf("EncodeArgs(%q) decoded to %q", args, got)
+		}
+		for i := range got {
+			if got[i] != args[i] {
+				t.Errorf("EncodeArgs(%q) decoded to %q", args, got)
+			}
+		}
+	}
+}
+
+func TestQueryArgs(t *testing.T) {
+	s := newRestServer(replyWith("1"))
+	defer s.Close()
+
+	c := newTestChaincode(t, s)
+
+	args := []string{"a b", "it's", "x&fcn=move", BinaryArg([]byte{0, 0xff, '\''})}
+	if _, err := c.Query(context.Background(), "ch", "cc", "get", args, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	q := s.last().Query
+	if q.Get("fcn") != "get" || len(q["fcn"]) != 1 {
+		t.Errorf("Query() sent %v", q)
+	}
+
+	var got []string
+	if err := json.Unmarshal([]byte(q.Get("args")), &got); err != nil || len(got) != len(args) {
+		t.Fatalf("Query() args = %s, %v", q.Get("args"), err)
+	}
+	for i := range got {
+		if got[i] != args[i] {
+			t.Errorf("Query() args = %q, want %q", got, args)
+		}
+	}
+
+	b, err := DecodeBinary(got[3])
+	if err != nil || string(b) != "\x00\xff'" {
+		t.Errorf("DecodeBinary() = %q, %v", b, err)
+	}
+}
+
+func TestDecodeQueryPayload(t *testing.T) {
+	for body, want := range map[string]string{
+		"90\n":                                   "90",
+		`"90"`:                                   "90",
+		`{"a":1}`:                                `{"a":1}`,
+		`"{\"a\":1}"`:                            `{"a":1}`,
+		"a now has 90 after the move":            "90",
+		`b c now has {"x":"y z"} after the move`: `{"x":"y z"}`,
+		"":                                       "",
+	} {
+		if got := string(DecodeQueryPayload([]byte(body))); got != want {
+			t.Errorf("DecodeQueryPayload(%q) = %q, want %q", body, got, want)
+		}
+	}
+}
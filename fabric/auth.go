@@ -0,0 +1,201 @@
+package fabric
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Credentials identify a user of the fabric rest server.
+type Credentials struct {
+	Username string
+	OrgName  string
+}
+
+// refreshBefore is how long before its expiry a token is renewed.
+const refreshBefore = time.Minute
+
+// ErrNoCredentials is returned by chaincode calls of a client built
+// without WithCredentials.
+var ErrNoCredentials = errors.New("no credentials for the fabric rest server")
+
+// login is a refresh in flight, shared by every caller waiting for it.
+type login struct {
+	done  chan struct{}
+	token string
+	err   error
+}
+
+// tokenSource logs in against the /users endpoint of the rest server and
+// keeps the returned jwt until shortly before it expires.
+type tokenSource struct {
+	cli     *http.Client
+	urlHead string
+	cred    Credentials
+	now     func() time.Time
+
+	mu       sync.Mutex
+	token    string
+	exp      time.Time
+	inflight *login
+}
+
+func newTokenSource(cli *http.Client, urlHead string, cred Credentials) *tokenSource {
+	return &tokenSource{
+		cli:     cli,
+		urlHead: urlHead,
+		cred:    cred,
+		now:     time.Now,
+	}
+}
+
+// Token returns a valid jwt, logging in again if the current one is about
+// to expire. Concurrent callers share a single login.
+func (ts *tokenSource) Token(ctx context.Context) (string, error) {
+	ts.mu.Lock()
+
+	if ts.token != "" && ts.now().Add(refreshBefore).Before(ts.exp) {
+		token := ts.token
+		ts.mu.Unlock()
+		return token, nil
+	}
+
+	l := ts.inflight
+	if l == nil {
+		l = &login{done: make(chan struct{})}
+		ts.inflight = l
+
+		go func() {
+			// not bound to ctx, other callers may be waiting for it
+			lctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+			defer cancel()
+
+			token, exp, err := ts.login(lctx)
+
+			ts.mu.Lock()
+			if err == nil {
+				ts.token, ts.exp = token, exp
+			}
+			ts.inflight = nil
+			ts.mu.Unlock()
+
+			l.token, l.err = token, err
+			close(l.done)
+		}()
+	}
+
+	ts.mu.Unlock()
+
+	select {
+	case <-l.done:
+		return l.token, l.err
+	case <-ctx.Done():
+		return "", ctx.Err()
+	}
+}
+
+// Invalidate drops the current token, e.g. after a 401.
+func (ts *tokenSource) Invalidate() {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+
+	ts.token = ""
+}
+
+// loginEndpoint is the login path of the rest server.
+const loginEndpoint = "/users"
+
+// login posts the credentials to loginEndpoint. Every failure of the call
+// is returned as an *APIError, like those of the other calls.
+func (ts *tokenSource) login(ctx context.Context) (string, time.Time, error) {
+	form := url.Values{}
+	form.Set("username", ts.cred.Username)
+	form.Set("orgName", ts.cred.OrgName)
+
+	req, err := http.NewRequestWithContext(ctx, "POST", ts.urlHead+loginEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", time.Time{}, err
+	}
+
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := ts.cli.Do(req)
+	if err != nil {
+		return "", time.Time{}, &APIError{Kind: ErrTransport, Endpoint: loginEndpoint, Err: err}
+	}
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return "", time.Time{}, &APIError{Kind: ErrTransport, Endpoint: loginEndpoint, StatusCode: resp.StatusCode, Err: err}
+	}
+
+	type Response struct {
+		Success bool   `json:"success"`
+		Message string `json:"message"`
+		Token   string `json:"token"`
+	}
+
+	res := Response{}
+	decodeErr := json.Unmarshal(body, &res)
+
+	var exp time.Time
+	var apiErr *APIError
+
+	switch {
+	case resp.StatusCode < 200 || resp.StatusCode > 299:
+		apiErr = &APIError{Kind: ErrHTTPStatus, Message: res.Message}
+	case decodeErr != nil:
+		apiErr = &APIError{Kind: ErrBadResponse, Err: decodeErr}
+	case !res.Success:
+		apiErr = &APIError{Kind: rejectKind(res.Message), Message: res.Message}
+	default:
+		exp, err = jwtExpiry(res.Token)
+		if err != nil {
+			apiErr = &APIError{Kind: ErrBadResponse, Message: res.Message, Err: err}
+		}
+	}
+
+	if apiErr != nil {
+		apiErr.Endpoint = loginEndpoint
+		apiErr.StatusCode = resp.StatusCode
+		apiErr.Body = body
+		return "", time.Time{}, apiErr
+	}
+
+	return res.Token, exp, nil
+}
+
+// jwtExpiry returns the exp claim of a jwt, without checking its signature.
+func jwtExpiry(token string) (time.Time, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return time.Time{}, errors.New("malformed jwt")
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(parts[1], "="))
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	claims := struct {
+		Exp int64 `json:"exp"`
+	}{}
+	err = json.Unmarshal(payload, &claims)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	if claims.Exp == 0 {
+		return time.Time{}, errors.New("jwt has no exp claim")
+	}
+
+	return time.Unix(claims.Exp, 0), nil
+}
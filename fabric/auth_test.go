@@ -0,0 +1,178 @@
+package fabric
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func testJWT(exp time.Time) string {
+	enc := base64.RawURLEncoding
+	claims, _ := json.Marshal(map[string]interface{}{
+		"exp":      exp.Unix(),
+		"username": "Jim",
+		"orgName":  "Org1",
+	})
+
+	return enc.EncodeToString([]byte(`{"alg":"HS256","typ":"JWT"}`)) + "." + enc.EncodeToString(claims) + ".c2lnbmF0dXJl"
+}
+
+func writeLogin(w http.ResponseWriter, exp time.Time) {
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"secret":  "secret",
+		"message": "Jim enrolled Successfully",
+		"token":   testJWT(exp),
+	})
+}
+
+type loginServer struct {
+	*httptest.Server
+	logins int32
+	ttl    time.Duration
+	delay  time.Duration
+}
+
+func newLoginServer(ttl time.Duration) *loginServer {
+	s := &loginServer{ttl: ttl}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.FormValue("username") != "Jim" || r.FormValue("orgName") != "Org1" {
+			w.Write([]byte(`{"success":false,"message":"unknown user"}`))
+			return
+		}
+
+		atomic.AddInt32(&s.logins, 1)
+		time.Sleep(s.delay)
+		writeLogin(w, time.Now().Add(s.ttl))
+	}))
+	return s
+}
+
+func (s *loginServer) count() int {
+	return int(atomic.LoadInt32(&s.logins))
+}
+
+func TestJwtExpiry(t *testing.T) {
+	exp := time.Unix(1539649959, 0)
+	got, err := jwtExpiry(testJWT(exp))
+	if err != nil || !got.Equal(exp) {
+		t.Errorf("jwtExpiry() = %v, %v, want %v", got, err, exp)
+	}
+
+	for _, token := range []string{"", "a.b", "a.!!!.c", "a." + base64.RawURLEncoding.EncodeToString([]byte(`{}`)) + ".c"} {
+		if _, err := jwtExpiry(token); err == nil {
+			t.Errorf("jwtExpiry(%q) succeeded", token)
+		}
+	}
+}
+
+func TestTokenRefresh(t *testing.T) {
+	s := newLoginServer(time.Hour)
+	defer s.Close()
+
+	ts := newTokenSource(http.DefaultClient, s.URL, Credentials{"Jim", "Org1"})
+	now := time.Now()
+	ts.now = func() time.Time { return now }
+
+	first, err := ts.Token(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// still valid, no new login
+	now = now.Add(30 * time.Minute)
+	if tok, _ := ts.Token(context.Background()); tok != first || s.count() != 1 {
+		t.Errorf("token refreshed while valid, %d logins", s.count())
+	}
+
+	// within refreshBefore of the expiry
+	now = now.Add(30*time.Minute - refreshBefore/2)
+	if _, err := ts.Token(context.Background()); err != nil || s.count() != 2 {
+		t.Errorf("token not refreshed before expiry, %d logins, err %v", s.count(), err)
+	}
+
+	ts.Invalidate()
+	if _, err := ts.Token(context.Background()); err != nil || s.count() != 3 {
+		t.Errorf("token not refreshed after Invalidate, %d logins, err %v", s.count(), err)
+	}
+}
+
+func TestTokenSingleRefresh(t *testing.T) {
+	s := newLoginServer(time.Hour)
+	s.delay = 50 * time.Millisecond
+	defer s.Close()
+
+	ts := newTokenSource(http.DefaultClient, s.URL, Credentials{"Jim", "Org1"})
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := ts.Token(context.Background()); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if s.count() != 1 {
+		t.Errorf("%d logins, want 1", s.count())
+	}
+}
+
+func TestTokenLoginFailed(t *testing.T) {
+	s := newLoginServer(time.Hour)
+	defer s.Close()
+
+	ts := newTokenSource(http.DefaultClient, s.URL, Credentials{"Bob", "Org1"})
+	if _, err := ts.Token(context.Background()); !errors.Is(err, ErrRejected) || IsRetryable(err) {
+		t.Errorf("Token() with unknown user err = %v, want ErrRejected", err)
+	}
+}
+
+func TestTokenLoginStatus(t *testing.T) {
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+		w.Write([]byte("<html>502 Bad Gateway</html>"))
+	}))
+	defer s.Close()
+
+	ts := newTokenSource(http.DefaultClient, s.URL, Credentials{"Jim", "Org1"})
+	_, err := ts.Token(context.Background())
+
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) || apiErr.Kind != ErrHTTPStatus || apiErr.StatusCode != http.StatusBadGateway || apiErr.Endpoint != "/users" || !IsRetryable(err) {
+		t.Errorf("Token() behind a 502 err = %v, want a retryable ErrHTTPStatus", err)
+	}
+
+	// a success without a usable token
+	s.Config.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"success":true,"token":"x"}`))
+	})
+	if _, err := ts.Token(context.Background()); !errors.Is(err, ErrBadResponse) {
+		t.Errorf("Token() with a malformed jwt err = %v, want ErrBadResponse", err)
+	}
+}
+
+func TestTokenContextCanceled(t *testing.T) {
+	s := newLoginServer(time.Hour)
+	s.delay = time.Second
+	defer s.Close()
+
+	ts := newTokenSource(http.DefaultClient, s.URL, Credentials{"Jim", "Org1"})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	if _, err := ts.Token(ctx); err != context.DeadlineExceeded {
+		t.Errorf("Token() err = %v, want deadline exceeded", err)
+	}
+}
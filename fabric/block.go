@@ -0,0 +1,403 @@
+package fabric
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Transaction is the flat model of a transaction envelope of a block.
+type Transaction struct {
+	TxID    string
+	Channel string
+	// BlockNumber is the block of the transaction, set by DecodeBlock.
+	BlockNumber uint64
+	// Type is the header type, ENDORSER_TRANSACTION for chaincode calls and
+	// CONFIG for channel configuration.
+	Type      string
+	Timestamp time.Time
+
+	CreatorMSP string
+	// CreatorCert is the pem certificate of the submitter.
+	CreatorCert string
+
+	// Chaincode, Fcn and Args are the chaincode call of an endorser
+	// transaction. Binary args are kept as raw bytes.
+	Chaincode        string
+	ChaincodeVersion string
+	Fcn              string
+	Args             []string
+
+	// RWSets are the reads and writes of each namespace, usually the
+	// chaincode and lscc.
+	RWSets []NsRWSet
+	// Event is the chaincode event set by the transaction, if any.
+	Event *ChaincodeEvent
+	// EndorserMSPs are the MSP IDs of the endorsements.
+	EndorserMSPs []string
+
+	ValidationCode int
+	// ValidationStatus is the name of ValidationCode, VALID for a valid
+	// transaction.
+	ValidationStatus string
+
+	// Raw is the json of the envelope or processed transaction.
+	Raw json.RawMessage
+}
+
+// Valid reports whether the transaction was committed as valid.
+func (tx *Transaction) Valid() bool {
+	return tx.ValidationCode == 0
+}
+
+// NsRWSet is the read/write set of a transaction in one namespace.
+type NsRWSet struct {
+	Namespace string
+	Reads     []KVRead
+	Writes    []KVWrite
+}
+
+// KVRead is a key read by a transaction at the version of the committed
+// value. Exists is false if the key had no value.
+type KVRead struct {
+	Key      string
+	Exists   bool
+	BlockNum uint64
+	TxNum    uint64
+}
+
+// KVWrite is a key written or deleted by a transaction.
+type KVWrite struct {
+	Key      string
+	Value    string
+	IsDelete bool
+}
+
+// ChaincodeEvent is an event set by a chaincode with SetEvent.
+type ChaincodeEvent struct {
+	Chaincode   string
+	TxID        string
+	Name        string
+	Payload     []byte
+	BlockNumber uint64
+}
+
+var headerTypes = map[uint64]string{
+	0: "MESSAGE",
+	1: "CONFIG",
+	2: "CONFIG_UPDATE",
+	3: "ENDORSER_TRANSACTION",
+	4: "ORDERER_TRANSACTION",
+	5: "DELIVER_SEEK_INFO",
+	6: "CHAINCODE_PACKAGE",
+}
+
+// The json of blocks and envelopes written by the node sdk BlockDecoder.
+type rawIdentity struct {
+	Mspid   string   `json:"Mspid"`
+	IdBytes jsonText `json:"IdBytes"`
+}
+
+type rawChaincodeID struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+}
+
+type rawEnvelope struct {
+	Payload struct {
+		Header struct {
+			ChannelHeader struct {
+				Type       jsonUint `json:"type"`
+				TypeString string   `json:"typeString"`
+				Timestamp  jsonTime `json:"timestamp"`
+				ChannelID  string   `json:"channel_id"`
+				TxID       string   `json:"tx_id"`
+			} `json:"channel_header"`
+			SignatureHeader struct {
+				Creator rawIdentity `json:"creator"`
+			} `json:"signature_header"`
+		} `json:"header"`
+		Data struct {
+			Actions []rawAction `json:"actions"`
+		} `json:"data"`
+	} `json:"payload"`
+}
+
+type rawAction struct {
+	Payload struct {
+		ChaincodeProposalPayload struct {
+			Input struct {
+				ChaincodeSpec struct {
+					ChaincodeID rawChaincodeID `json:"chaincode_id"`
+					Input       struct {
+						Args []jsonText `json:"args"`
+					} `json:"input"`
+				} `json:"chaincode_spec"`
+			} `json:"input"`
+		} `json:"chaincode_proposal_payload"`
+		Action struct {
+			ProposalResponsePayload struct {
+				Extension struct {
+					Results struct {
+						NsRWSet []struct {
+							Namespace string `json:"namespace"`
+							RWSet     struct {
+								Reads []struct {
+									Key     string `json:"key"`
+									Version *struct {
+										BlockNum jsonUint `json:"block_num"`
+										TxNum    jsonUint `json:"tx_num"`
+									} `json:"version"`
+								} `json:"reads"`
+								Writes []struct {
+									Key      string   `json:"key"`
+									IsDelete bool     `json:"is_delete"`
+									Value    jsonText `json:"value"`
+								} `json:"writes"`
+							} `json:"rwset"`
+						} `json:"ns_rwset"`
+					} `json:"results"`
+					Events *struct {
+						ChaincodeID string   `json:"chaincode_id"`
+						TxID        string   `json:"tx_id"`
+						EventName   string   `json:"event_name"`
+						Payload     jsonText `json:"payload"`
+					} `json:"events"`
+					ChaincodeID rawChaincodeID `json:"chaincode_id"`
+				} `json:"extension"`
+			} `json:"proposal_response_payload"`
+			Endorsements []struct {
+				Endorser rawIdentity `json:"endorser"`
+			} `json:"endorsements"`
+		} `json:"action"`
+	} `json:"payload"`
+}
+
+// DecodeBlock decodes the json of a block returned by the rest server,
+// with the transactions of its envelopes.
+func DecodeBlock(data []byte) (*Block, error) {
+	res := struct {
+		Header *struct {
+			Number       jsonUint `json:"number"`
+			PreviousHash hexBytes `json:"previous_hash"`
+			DataHash     hexBytes `json:"data_hash"`
+		} `json:"header"`
+		Data struct {
+			Data []json.RawMessage `json:"data"`
+		} `json:"data"`
+		Metadata struct {
+			Metadata []json.RawMessage `json:"metadata"`
+		} `json:"metadata"`
+	}{}
+
+	err := json.Unmarshal(data, &res)
+	if err != nil {
+		return nil, err
+	}
+
+	if res.Header == nil {
+		return nil, errors.New("no block header")
+	}
+
+	b := &Block{
+		Number:       uint64(res.Header.Number),
+		PreviousHash: hex.EncodeToString(res.Header.PreviousHash),
+		DataHash:     hex.EncodeToString(res.Header.DataHash),
+		TxCount:      len(res.Data.Data),
+		Raw:          data,
+	}
+
+	// the third metadata entry is the validation code of every envelope
+	var filter []int
+	if len(res.Metadata.Metadata) > 2 {
+		json.Unmarshal(res.Metadata.Metadata[2], &filter)
+	}
+
+	for i, env := range res.Data.Data {
+		tx, err := decodeEnvelope(env)
+		if err != nil {
+			return nil, errors.New("tx " + strconv.Itoa(i) + ": " + err.Error())
+		}
+
+		if i < len(filter) {
+			tx.ValidationCode = filter[i]
+		} else {
+			tx.ValidationCode = 254
+		}
+		tx.ValidationStatus = ValidationCodeName(tx.ValidationCode)
+
+		tx.BlockNumber = b.Number
+		if tx.Event != nil {
+			tx.Event.BlockNumber = b.Number
+		}
+
+		b.Txs = append(b.Txs, tx)
+	}
+
+	return b, nil
+}
+
+// DecodeTransaction decodes the json of a processed transaction returned
+// by the rest server, an envelope with its validation code.
+func DecodeTransaction(data []byte) (*Transaction, error) {
+	res := struct {
+		ValidationCode *jsonUint       `json:"validationCode"`
+		Envelope       json.RawMessage `json:"transactionEnvelope"`
+	}{}
+
+	err := json.Unmarshal(data, &res)
+	if err != nil {
+		return nil, err
+	}
+
+	if res.ValidationCode == nil || res.Envelope == nil {
+		return nil, errors.New("not a processed transaction")
+	}
+
+	tx, err := decodeEnvelope(res.Envelope)
+	if err != nil {
+		return nil, err
+	}
+
+	tx.ValidationCode = int(*res.ValidationCode)
+	tx.ValidationStatus = ValidationCodeName(tx.ValidationCode)
+	tx.Raw = data
+
+	return tx, nil
+}
+
+func decodeEnvelope(data []byte) (*Transaction, error) {
+	env := rawEnvelope{}
+	err := json.Unmarshal(data, &env)
+	if err != nil {
+		return nil, err
+	}
+
+	ch := env.Payload.Header.ChannelHeader
+	creator := env.Payload.Header.SignatureHeader.Creator
+
+	tx := &Transaction{
+		TxID:        ch.TxID,
+		Channel:     ch.ChannelID,
+		Type:        ch.TypeString,
+		Timestamp:   time.Time(ch.Timestamp),
+		CreatorMSP:  creator.Mspid,
+		CreatorCert: string(creator.IdBytes),
+		Raw:         data,
+	}
+	if tx.Type == "" {
+		tx.Type = headerTypes[uint64(ch.Type)]
+	}
+
+	// endorser transactions carry a single action
+	for _, a := range env.Payload.Data.Actions {
+		spec := a.Payload.ChaincodeProposalPayload.Input.ChaincodeSpec
+		ext := a.Payload.Action.ProposalResponsePayload.Extension
+
+		tx.Chaincode = spec.ChaincodeID.Name
+		tx.ChaincodeVersion = ext.ChaincodeID.Version
+		if tx.Chaincode == "" {
+			tx.Chaincode = ext.ChaincodeID.Name
+		}
+
+		// the first arg is the function
+		for i, arg := range spec.Input.Args {
+			if i == 0 {
+				tx.Fcn = string(arg)
+			} else {
+				tx.Args = append(tx.Args, string(arg))
+			}
+		}
+
+		for _, ns := range ext.Results.NsRWSet {
+			set := NsRWSet{Namespace: ns.Namespace}
+			for _, r := range ns.RWSet.Reads {
+				read := KVRead{Key: r.Key}
+				if r.Version != nil {
+					read.Exists = true
+					read.BlockNum = uint64(r.Version.BlockNum)
+					read.TxNum = uint64(r.Version.TxNum)
+				}
+				set.Reads = append(set.Reads, read)
+			}
+			for _, w := range ns.RWSet.Writes {
+				set.Writes = append(set.Writes, KVWrite{Key: w.Key, Value: string(w.Value), IsDelete: w.IsDelete})
+			}
+			tx.RWSets = append(tx.RWSets, set)
+		}
+
+		if ev := ext.Events; ev != nil && ev.EventName != "" {
+			tx.Event = &ChaincodeEvent{
+				Chaincode: ev.ChaincodeID,
+				TxID:      ev.TxID,
+				Name:      ev.EventName,
+				Payload:   []byte(ev.Payload),
+			}
+		}
+
+		for _, e := range a.Payload.Action.Endorsements {
+			tx.EndorserMSPs = append(tx.EndorserMSPs, e.Endorser.Mspid)
+		}
+	}
+
+	return tx, nil
+}
+
+// jsonText decodes a string, taken as is, or a node Buffer.
+type jsonText []byte
+
+func (t *jsonText) UnmarshalJSON(data []byte) error {
+	var s string
+	if json.Unmarshal(data, &s) == nil {
+		*t = []byte(s)
+		return nil
+	}
+
+	var b jsonBytes
+	err := b.UnmarshalJSON(data)
+	*t = jsonText(b)
+	return err
+}
+
+// timeLayouts are the layouts of the timestamp strings, rfc3339 and the
+// toString() of a javascript Date without its zone name.
+var timeLayouts = []string{time.RFC3339Nano, "Mon Jan 02 2006 15:04:05 GMT-0700"}
+
+// jsonTime decodes a timestamp string or a protobuf Timestamp
+// {"seconds":..,"nanos":..}. A string of no known layout is left as the
+// zero time rather than failing the whole block.
+type jsonTime time.Time
+
+func (t *jsonTime) UnmarshalJSON(data []byte) error {
+	if string(data) == "null" {
+		return nil
+	}
+
+	var s string
+	if json.Unmarshal(data, &s) == nil {
+		if i := strings.Index(s, " ("); i >= 0 {
+			s = s[:i]
+		}
+		for _, layout := range timeLayouts {
+			if v, err := time.Parse(layout, s); err == nil {
+				*t = jsonTime(v)
+				return nil
+			}
+		}
+		return nil
+	}
+
+	var ts struct {
+		Seconds jsonUint `json:"seconds"`
+		Nanos   int64    `json:"nanos"`
+	}
+	err := json.Unmarshal(data, &ts)
+	if err != nil {
+		return err
+	}
+	*t = jsonTime(time.Unix(int64(ts.Seconds), ts.Nanos).UTC())
+	return nil
+}
@@ -0,0 +1,220 @@
+package fabric
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+)
+
+// sdkEnvelope is an endorser transaction as written by the node sdk
+// BlockDecoder, args as Buffers.
+const sdkEnvelope = `{
+	"signature": {"type": "Buffer", "data": [48, 69]},
+	"payload": {
+		"header": {
+			"channel_header": {
+				"type": 3,
+				"version": 1,
+				"timestamp": "2018-10-16T08:12:39.123Z",
+				"channel_id": "mychannel",
+				"tx_id": "4f2a",
+				"epoch": "0",
+				"typeString": "ENDORSER_TRANSACTION"
+			},
+			"signature_header": {
+				"creator": {"Mspid": "Org1MSP", "IdBytes": "-----BEGIN CERTIFICATE-----\nMIIC\n-----END CERTIFICATE-----\n"},
+				"nonce": {"type": "Buffer", "data": [1]}
+			}
+		},
+		"data": {
+			"actions": [{
+				"header": {"creator": {"Mspid": "Org1MSP", "IdBytes": "pem"}},
+				"payload": {
+					"chaincode_proposal_payload": {
+						"input": {
+							"chaincode_spec": {
+								"type": 1,
+								"typeString": "GOLANG",
+								"chaincode_id": {"path": "", "name": "mycc", "version": ""},
+								"input": {"args": [
+									{"type": "Buffer", "data": [109, 111, 118, 101]},
+									{"type": "Buffer", "data": [97]},
+									{"type": "Buffer", "data": [98]},
+									{"type": "Buffer", "data": [49, 48]}
+								]}
+							}
+						}
+					},
+					"action": {
+						"proposal_response_payload": {
+							"proposal_hash": "9f86",
+							"extension": {
+								"results": {
+									"data_model": 0,
+									"ns_rwset": [
+										{"namespace": "lscc", "rwset": {"reads": [{"key": "mycc", "version": {"block_num": "3", "tx_num": "0"}}], "writes": []}},
+										{"namespace": "mycc", "rwset": {
+											"reads": [{"key": "a", "version": {"block_num": "4", "tx_num": "0"}}, {"key": "c", "version": null}],
+											"writes": [{"key": "a", "is_delete": false, "value": "90"}, {"key": "b", "is_delete": false, "value": "210"}, {"key": "c", "is_delete": true, "value": ""}]
+										}}
+									]
+								},
+								"events": {"chaincode_id": "mycc", "tx_id": "4f2a", "event_name": "moved", "payload": {"type": "Buffer", "data": [123, 125]}},
+								"response": {"status": 200, "message": "", "payload": ""},
+								"chaincode_id": {"path": "", "name": "mycc", "version": "v0"}
+							}
+						},
+						"endorsements": [
+							{"endorser": {"Mspid": "Org1MSP", "IdBytes": "pem"}, "signature": {"type": "Buffer", "data": [1]}},
+							{"endorser": {"Mspid": "Org2MSP", "IdBytes": "pem"}, "signature": {"type": "Buffer", "data": [2]}}
+						]
+					}
+				}
+			}]
+		}
+	}
+}`
+
+const configEnvelope = `{
+	"payload": {
+		"header": {
+			"channel_header": {"type": 1, "timestamp": {"seconds": "1539677559", "nanos": 0}, "channel_id": "mychannel", "tx_id": ""},
+			"signature_header": {"creator": {"Mspid": "OrdererMSP", "IdBytes": "pem"}}
+		},
+		"data": {"config": {"sequence": "3"}}
+	}
+}`
+
+func checkEnvelope(t *testing.T, tx *Transaction) {
+	t.Helper()
+
+	if tx.TxID != "4f2a" || tx.Channel != "mychannel" || tx.Type != "ENDORSER_TRANSACTION" {
+		t.Errorf("header = %s %s %s", tx.TxID, tx.Channel, tx.Type)
+	}
+	if want := time.Date(2018, 10, 16, 8, 12, 39, 123e6, time.UTC); !tx.Timestamp.Equal(want) {
+		t.Errorf("Timestamp = %v, want %v", tx.Timestamp, want)
+	}
+	if tx.CreatorMSP != "Org1MSP" || tx.CreatorCert[:27] != "-----BEGIN CERTIFICATE-----" {
+		t.Errorf("creator = %s %q", tx.CreatorMSP, tx.CreatorCert)
+	}
+	if tx.Chaincode != "mycc" || tx.ChaincodeVersion != "v0" || tx.Fcn != "move" {
+		t.Errorf("chaincode = %s %s %s", tx.Chaincode, tx.ChaincodeVersion, tx.Fcn)
+	}
+	if len(tx.Args) != 3 || tx.Args[0] != "a" || tx.Args[1] != "b" || tx.Args[2] != "10" {
+		t.Errorf("Args = %q", tx.Args)
+	}
+
+	if len(tx.RWSets) != 2 || tx.RWSets[1].Namespace != "mycc" {
+		t.Fatalf("RWSets = %+v", tx.RWSets)
+	}
+	set := tx.RWSets[1]
+	if len(set.Reads) != 2 || set.Reads[0] != (KVRead{"a", true, 4, 0}) || set.Reads[1] != (KVRead{Key: "c"}) {
+		t.Errorf("Reads = %+v", set.Reads)
+	}
+	if len(set.Writes) != 3 || set.Writes[0] != (KVWrite{"a", "90", false}) || !set.Writes[2].IsDelete {
+		t.Errorf("Writes = %+v", set.Writes)
+	}
+
+	if tx.Event == nil || tx.Event.Name != "moved" || string(tx.Event.Payload) != "{}" {
+		t.Errorf("Event = %+v", tx.Event)
+	}
+	if len(tx.EndorserMSPs) != 2 || tx.EndorserMSPs[1] != "Org2MSP" {
+		t.Errorf("EndorserMSPs = %v", tx.EndorserMSPs)
+	}
+}
+
+func TestDecodeBlock(t *testing.T) {
+	data := `{
+		"header": {"number": "5", "previous_hash": "aa01", "data_hash": "bb02"},
+		"data": {"data": [` + sdkEnvelope + `, ` + configEnvelope + `]},
+		"metadata": {"metadata": [[], {"value": {}}, [0, 11], []]}
+	}`
+
+	b, err := DecodeBlock([]byte(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b.Number != 5 || b.TxCount != 2 || len(b.Txs) != 2 {
+		t.Fatalf("DecodeBlock() = %+v", b)
+	}
+
+	checkEnvelope(t, b.Txs[0])
+	if !b.Txs[0].Valid() || b.Txs[0].ValidationStatus != "VALID" {
+		t.Errorf("tx 0 status = %s", b.Txs[0].ValidationStatus)
+	}
+
+	cfg := b.Txs[1]
+	if cfg.Type != "CONFIG" || cfg.ValidationStatus != "MVCC_READ_CONFLICT" || cfg.Chaincode != "" || cfg.CreatorMSP != "OrdererMSP" {
+		t.Errorf("config tx = %+v", cfg)
+	}
+	if !cfg.Timestamp.Equal(time.Unix(1539677559, 0)) {
+		t.Errorf("config tx Timestamp = %v", cfg.Timestamp)
+	}
+}
+
+func TestDecodeBlockTimestamps(t *testing.T) {
+	// the rest sample writes the Date of the BlockDecoder with toString()
+	envelope := strings.Replace(sdkEnvelope, `"2018-10-16T08:12:39.123Z"`, `"Tue Oct 16 2018 16:12:39 GMT+0800 (China Standard Time)"`, 1)
+	b, err := DecodeBlock([]byte(`{"header": {"number": 1}, "data": {"data": [` + envelope + `]}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := time.Date(2018, 10, 16, 8, 12, 39, 0, time.UTC); !b.Txs[0].Timestamp.Equal(want) {
+		t.Errorf("Timestamp = %v, want %v", b.Txs[0].Timestamp, want)
+	}
+
+	// an unknown layout does not fail the block
+	envelope = strings.Replace(sdkEnvelope, `"2018-10-16T08:12:39.123Z"`, `"16/10/2018 08:12"`, 1)
+	b, err = DecodeBlock([]byte(`{"header": {"number": 1}, "data": {"data": [` + envelope + `]}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !b.Txs[0].Timestamp.IsZero() || b.Txs[0].TxID != "4f2a" {
+		t.Errorf("tx = %+v", b.Txs[0])
+	}
+}
+
+func TestDecodeBlockNoFilter(t *testing.T) {
+	b, err := DecodeBlock([]byte(`{"header": {"number": 1}, "data": {"data": [` + configEnvelope + `]}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b.Txs[0].ValidationStatus != "NOT_VALIDATED" {
+		t.Errorf("ValidationStatus = %s", b.Txs[0].ValidationStatus)
+	}
+
+	for _, data := range []string{`{"data": {}}`, `[]`, `{"header": {"number": 1}, "data": {"data": [1]}}`} {
+		if _, err := DecodeBlock([]byte(data)); err == nil {
+			t.Errorf("DecodeBlock(%s) succeeded", data)
+		}
+	}
+}
+
+func TestTransactionByIDDecoded(t *testing.T) {
+	s := newRestServer(replyWith(`{"validationCode": 0, "transactionEnvelope": ` + sdkEnvelope + `}`))
+	defer s.Close()
+
+	tx, err := newTestChaincode(t, s).TransactionByID(context.Background(), "mychannel", "4f2a", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	checkEnvelope(t, tx.Tx)
+	if !tx.Valid() {
+		t.Errorf("ValidationStatus = %s", tx.ValidationStatus)
+	}
+}
+
+func TestDecodeStringArgs(t *testing.T) {
+	// some rest servers turn the Buffers into strings
+	tx, err := DecodeTransaction([]byte(`{"validationCode": 10, "transactionEnvelope": {"payload": {"data": {"actions": [{"payload": {
+		"chaincode_proposal_payload": {"input": {"chaincode_spec": {"chaincode_id": {"name": "mycc"}, "input": {"args": ["query", "a"]}}}}
+	}}]}}}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tx.Fcn != "query" || len(tx.Args) != 1 || tx.Args[0] != "a" || tx.Valid() {
+		t.Errorf("DecodeTransaction() = %+v", tx)
+	}
+}
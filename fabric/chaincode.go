@@ -0,0 +1,261 @@
+package fabric
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+const (
+	DefaultRestURL = "http://localhost:4000"
+)
+
+// DefaultPeers are the endorsing peers of the fabric-samples network.
+var DefaultPeers = []string{"peer0.org1.example.com", "peer0.org2.example.com"}
+
+// ChaincodeClient calls any chaincode of any channel through the routes of
+// the fabric rest server.
+type ChaincodeClient struct {
+	urlHead string
+	cli     *http.Client
+	log     Logger
+	auth    *tokenSource
+	peers   []string
+}
+
+// NewChaincodeClient builds a client of the fabric rest server at restURL
+// logging in as cred. Only the http, tls, timeout, logger and peers
+// options of NewFabricClient apply.
+func NewChaincodeClient(restURL string, cred Credentials, opts ...Option) (*ChaincodeClient, error) {
+	cfg := configure(opts)
+
+	cli, err := cfg.httpClient()
+	if err != nil {
+		return nil, err
+	}
+
+	return newChaincodeClient(cli, restURL, cfg.log, cfg.peers, &cred), nil
+}
+
+// newChaincodeClient builds the client of the rest server at restURL, which
+// cannot log in without cred.
+func newChaincodeClient(cli *http.Client, restURL string, log Logger, peers []string, cred *Credentials) *ChaincodeClient {
+	c := &ChaincodeClient{
+		urlHead: strings.TrimRight(restURL, "/"),
+		cli:     cli,
+		log:     log,
+		peers:   peers,
+	}
+	if cred != nil {
+		c.auth = newTokenSource(cli, c.urlHead, *cred)
+	}
+
+	return c
+}
+
+// Chaincode returns the chaincode client sharing the transport,
+// credentials and logger of f.
+func (f *FabricClient) Chaincode() *ChaincodeClient {
+	return f.chaincode
+}
+
+// do sends a request to the rest server with a bearer token and returns
+// the response body. A 401 drops the token so the next call logs in again.
+func (c *ChaincodeClient) do(ctx context.Context, method, endpoint string, data []byte) ([]byte, error) {
+	if c.auth == nil {
+		return nil, ErrNoCredentials
+	}
+
+	token, err := c.auth.Token(ctx)
+	if err != nil {
+		c.log.Error(err)
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, c.urlHead+endpoint, bytes.NewReader(data))
+	if err != nil {
+		c.log.Error(err)
+		return nil, err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	resp, err := c.cli.Do(req)
+	if err != nil {
+		c.log.Error(err)
+		return nil, &APIError{Kind: ErrTransport, Endpoint: endpoint, Err: err}
+	}
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		c.log.Error(err)
+		return nil, &APIError{Kind: ErrTransport, Endpoint: endpoint, StatusCode: resp.StatusCode, Err: err}
+	}
+
+	c.log.Debug(string(body))
+
+	if resp.StatusCode == http.StatusUnauthorized {
+		c.auth.Invalidate()
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		apiErr := &APIError{
+			Kind:       ErrHTTPStatus,
+			Endpoint:   endpoint,
+			StatusCode: resp.StatusCode,
+			Message:    strings.TrimSpace(string(body)),
+			Body:       body,
+		}
+		c.log.Error(apiErr)
+		return nil, apiErr
+	}
+
+	// errors come back as {"success":false,"message":...}
+	res := struct {
+		Success *bool  `json:"success"`
+		Message string `json:"message"`
+	}{}
+	if json.Unmarshal(body, &res) == nil && res.Success != nil && !*res.Success {
+		apiErr := &APIError{
+			Kind:       rejectKind(res.Message),
+			Endpoint:   endpoint,
+			StatusCode: resp.StatusCode,
+			Message:    res.Message,
+			Body:       body,
+		}
+		c.log.Error(apiErr)
+		return nil, apiErr
+	}
+
+	return body, nil
+}
+
+func chaincodePath(channel, chaincode string) string {
+	return "/channels/" + url.PathEscape(channel) + "/chaincodes/" + url.PathEscape(chaincode)
+}
+
+// Invoke submits a transaction calling fcn of chaincode on channel,
+// endorsed by peers, the client's peers if empty. It returns the
+// transaction ID.
+func (c *ChaincodeClient) Invoke(ctx context.Context, channel, chaincode, fcn string, args []string, peers []string) (string, error) {
+	if len(peers) == 0 {
+		peers = c.peers
+	}
+
+	type FabricReq struct {
+		Peers []string `json:"peers"`
+		Fcn   string   `json:"fcn"`
+		Args  []string `json:"args"`
+	}
+
+	data, err := json.Marshal(&FabricReq{
+		Peers: peers,
+		Fcn:   fcn,
+		Args:  args,
+	})
+	if err != nil {
+		c.log.Error(err)
+		return "", err
+	}
+
+	endpoint := chaincodePath(channel, chaincode)
+
+	body, err := c.do(ctx, "POST", endpoint, data)
+	if err != nil {
+		return "", err
+	}
+
+	txID, err := decodeInvoke(body)
+	if err != nil {
+		apiErr := &APIError{Kind: rejectKind(err.Error()), Endpoint: endpoint, Message: err.Error(), Body: body}
+		c.log.Error(apiErr)
+		return "", apiErr
+	}
+
+	c.log.Info("Successfully Invoke", chaincode, fcn, "txID =", txID)
+
+	return txID, nil
+}
+
+// decodeInvoke extracts the transaction ID of an invoke response, either a
+// plain text ID or a json object with a tx_id or txid field.
+func decodeInvoke(body []byte) (string, error) {
+	s := strings.TrimSpace(string(body))
+
+	var str string
+	if json.Unmarshal(body, &str) == nil {
+		s = strings.TrimSpace(str)
+	} else {
+		res := struct {
+			TxID    string `json:"tx_id"`
+			TxId    string `json:"txid"`
+			Message string `json:"message"`
+		}{}
+
+		if json.Unmarshal(body, &res) == nil {
+			if res.TxID != "" {
+				return res.TxID, nil
+			}
+			if res.TxId != "" {
+				return res.TxId, nil
+			}
+			s = res.Message
+		}
+	}
+
+	// the rest server answers failures as a sentence
+	if s == "" || strings.ContainsAny(s, " \t\n") {
+		if s == "" {
+			s = "invoke returned no transaction ID"
+		}
+		return "", errors.New(s)
+	}
+
+	return s, nil
+}
+
+// Query evaluates fcn of chaincode on channel against the first of peers,
+// the client's first peer if empty, and returns the response payload.
+func (c *ChaincodeClient) Query(ctx context.Context, channel, chaincode, fcn string, args []string, peers []string) ([]byte, error) {
+	if len(peers) == 0 {
+		peers = c.peers
+	}
+
+	argsJson, err := EncodeArgs(args)
+	if err != nil {
+		c.log.Error(err)
+		return nil, err
+	}
+
+	q := url.Values{}
+	if len(peers) > 0 {
+		q.Set("peer", peers[0])
+	}
+	q.Set("fcn", fcn)
+	q.Set("args", argsJson)
+
+	return c.do(ctx, "GET", chaincodePath(channel, chaincode)+"?"+q.Encode(), nil)
+}
+
+// QueryInto is like Query but decodes a json payload into out.
+func (c *ChaincodeClient) QueryInto(ctx context.Context, channel, chaincode, fcn string, args []string, peers []string, out interface{}) error {
+	body, err := c.Query(ctx, channel, chaincode, fcn, args, peers)
+	if err != nil {
+		return err
+	}
+
+	err = json.Unmarshal(body, out)
+	if err != nil {
+		c.log.Error(err)
+		return &APIError{Kind: ErrBadResponse, Endpoint: chaincodePath(channel, chaincode), Body: body, Err: err}
+	}
+
+	return nil
+}
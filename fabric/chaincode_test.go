@@ -0,0 +1,220 @@
+package fabric
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fabricclient/fabric/fabrictest"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+)
+
+// restServer is a stand-in for the fabric rest server. It answers /users
+// and records every other request before passing it to handler.
+type restServer struct {
+	*httptest.Server
+
+	mu    sync.Mutex
+	reqs  []*recorded
+	reply func(w http.ResponseWriter, r *recorded)
+}
+
+type recorded struct {
+	Method string
+	Path   string
+	Query  url.Values
+	Auth   string
+	Body   map[string]interface{}
+}
+
+func newRestServer(reply func(w http.ResponseWriter, r *recorded)) *restServer {
+	s := &restServer{reply: reply}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/users" {
+			writeLogin(w, time.Now().Add(time.Hour))
+			return
+		}
+
+		rec := &recorded{
+			Method: r.Method,
+			Path:   r.URL.EscapedPath(),
+			Query:  r.URL.Query(),
+			Auth:   r.Header.Get("Authorization"),
+		}
+		if r.Method == "POST" {
+			json.NewDecoder(r.Body).Decode(&rec.Body)
+		}
+
+		s.mu.Lock()
+		s.reqs = append(s.reqs, rec)
+		s.mu.Unlock()
+
+		s.reply(w, rec)
+	}))
+	return s
+}
+
+func (s *restServer) last() *recorded {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if len(s.reqs) == 0 {
+		return nil
+	}
+	return s.reqs[len(s.reqs)-1]
+}
+
+func replyWith(body string) func(w http.ResponseWriter, r *recorded) {
+	return func(w http.ResponseWriter, r *recorded) {
+		w.Write([]byte(body))
+	}
+}
+
+func newTestChaincode(t *testing.T, s *restServer, opts ...Option) *ChaincodeClient {
+	t.Helper()
+
+	opts = append([]Option{WithLogger(fabrictest.NopLogger{})}, opts...)
+	c, err := NewChaincodeClient(s.URL, Credentials{"Jim", "Org1"}, opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+func TestInvoke(t *testing.T) {
+	for _, reply := range []string{
+		"1f9b7c",
+		`"1f9b7c"`,
+		`{"success":true,"tx_id":"1f9b7c"}`,
+		`{"txid":"1f9b7c"}`,
+	} {
+		s := newRestServer(replyWith(reply))
+		c := newTestChaincode(t, s)
+
+		txID, err := c.Invoke(context.Background(), "ch1", "token", "transfer", []string{"a", "b", "1"}, []string{"peer1"})
+		if err != nil {
+			t.Fatalf("reply %s: %v", reply, err)
+		}
+		if txID != "1f9b7c" {
+			t.Errorf("reply %s: txID = %s", reply, txID)
+		}
+
+		r := s.last()
+		if r.Path != "/channels/ch1/chaincodes/token" || r.Body["fcn"] != "transfer" {
+			t.Errorf("Invoke() sent %s %v", r.Path, r.Body)
+		}
+		if peers, _ := r.Body["peers"].([]interface{}); len(peers) != 1 || peers[0] != "peer1" {
+			t.Errorf("Invoke() peers = %v", r.Body["peers"])
+		}
+
+		s.Close()
+	}
+}
+
+func TestInvokeDefaultPeers(t *testing.T) {
+	s := newRestServer(replyWith("tx"))
+	defer s.Close()
+
+	c := newTestChaincode(t, s, WithPeers("peer0.org3.example.com"))
+	if _, err := c.Invoke(context.Background(), "ch", "cc", "f", nil, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	if peers, _ := s.last().Body["peers"].([]interface{}); len(peers) != 1 || peers[0] != "peer0.org3.example.com" {
+		t.Errorf("Invoke() peers = %v", s.last().Body["peers"])
+	}
+}
+
+func TestInvokeFailed(t *testing.T) {
+	for _, reply := range []string{
+		"Failed to invoke chaincode. cause:Error: chaincode error (status: 500)",
+		`{"success":false,"message":"Failed to order the transaction"}`,
+		"",
+	} {
+		s := newRestServer(replyWith(reply))
+		c := newTestChaincode(t, s)
+
+		_, err := c.Invoke(context.Background(), "ch", "cc", "f", nil, nil)
+		if !errors.Is(err, ErrRejected) {
+			t.Errorf("reply %q: err = %v, want ErrRejected", reply, err)
+		}
+
+		s.Close()
+	}
+}
+
+func TestInvokeHTTPStatus(t *testing.T) {
+	s := newRestServer(func(w http.ResponseWriter, r *recorded) {
+		w.WriteHeader(http.StatusUnauthorized)
+		w.Write([]byte("token expired"))
+	})
+	defer s.Close()
+
+	c := newTestChaincode(t, s)
+
+	_, err := c.Invoke(context.Background(), "ch", "cc", "f", nil, nil)
+
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != 401 || apiErr.Message != "token expired" {
+		t.Errorf("Invoke() err = %v", err)
+	}
+
+	// the token was dropped after the 401
+	c.auth.mu.Lock()
+	token := c.auth.token
+	c.auth.mu.Unlock()
+	if token != "" {
+		t.Error("token kept after a 401")
+	}
+}
+
+func TestQueryInto(t *testing.T) {
+	s := newRestServer(replyWith(`{"owner":"a","amount":10}`))
+	defer s.Close()
+
+	c := newTestChaincode(t, s)
+
+	out := struct {
+		Owner  string `json:"owner"`
+		Amount int    `json:"amount"`
+	}{}
+	err := c.QueryInto(context.Background(), "ch1", "token", "get", []string{"a"}, nil, &out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out.Owner != "a" || out.Amount != 10 {
+		t.Errorf("QueryInto() = %+v", out)
+	}
+
+	r := s.last()
+	if r.Method != "GET" || r.Path != "/channels/ch1/chaincodes/token" {
+		t.Errorf("Query() sent %s %s", r.Method, r.Path)
+	}
+	if r.Query.Get("peer") != DefaultPeers[0] || r.Query.Get("fcn") != "get" || r.Query.Get("args") != `["a"]` {
+		t.Errorf("Query() sent %v", r.Query)
+	}
+
+	err = c.QueryInto(context.Background(), "ch1", "token", "get", nil, nil, &[]int{})
+	if !errors.Is(err, ErrBadResponse) {
+		t.Errorf("QueryInto() err = %v, want ErrBadResponse", err)
+	}
+}
+
+func TestDecodeInvoke(t *testing.T) {
+	for body, want := range map[string]string{
+		"abc\n":               "abc",
+		`" abc "`:             "abc",
+		`{"tx_id":"abc"}`:     "abc",
+		`{"message":"no tx"}`: "",
+		"Failed to invoke":    "",
+	} {
+		got, err := decodeInvoke([]byte(body))
+		if got != want || (want == "") != (err != nil) {
+			t.Errorf("decodeInvoke(%q) = %q, %v, want %q", body, got, err, want)
+		}
+	}
+}
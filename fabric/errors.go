@@ -0,0 +1,135 @@
+package fabric
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// Kinds of APIError, test them with errors.Is.
+var (
+	// ErrTransport means the request failed before a response was read.
+	ErrTransport = errors.New("transport error")
+	// ErrHTTPStatus means the server answered with a non 2xx status.
+	ErrHTTPStatus = errors.New("unexpected http status")
+	// ErrBadResponse means the response body could not be decoded.
+	ErrBadResponse = errors.New("undecodable response")
+	// ErrRejected means the server answered status:false.
+	ErrRejected = errors.New("rejected by server")
+
+	// ErrInsufficientBalance, ErrNotFound and ErrDuplicate refine
+	// ErrRejected from the server message.
+	ErrInsufficientBalance = errors.New("insufficient balance")
+	ErrNotFound            = errors.New("not found")
+	ErrDuplicate           = errors.New("duplicate request")
+)
+
+// APIError describes a failed call to the ocean api.
+type APIError struct {
+	Kind       error
+	Endpoint   string
+	StatusCode int
+	// Message is the message field returned by the server, if any.
+	Message string
+	// Body is the raw response body, if any.
+	Body []byte
+	// Err is the underlying cause, e.g. a *url.Error or a json error.
+	Err error
+}
+
+func (e *APIError) Error() string {
+	s := e.Endpoint + ": " + e.Kind.Error()
+
+	if e.StatusCode != 0 {
+		s += fmt.Sprintf(" (http %d)", e.StatusCode)
+	}
+	if e.Message != "" {
+		s += ": " + e.Message
+	}
+	if e.Err != nil {
+		s += ": " + e.Err.Error()
+	}
+
+	return s
+}
+
+func (e *APIError) Is(target error) bool {
+	if target == e.Kind {
+		return true
+	}
+
+	if target != ErrRejected {
+		return false
+	}
+
+	return e.Kind == ErrInsufficientBalance || e.Kind == ErrNotFound || e.Kind == ErrDuplicate
+}
+
+func (e *APIError) Unwrap() error {
+	return e.Err
+}
+
+// duplicateNonce is the message of the gateway rejecting a transfer whose
+// nonce it already applied.
+const duplicateNonce = "duplicate nonce"
+
+// isDuplicateNonce reports whether the gateway rejected a transfer as the
+// resend of one it applied.
+func isDuplicateNonce(err *APIError) bool {
+	return err.Kind == ErrDuplicate && strings.EqualFold(strings.TrimSpace(err.Message), duplicateNonce)
+}
+
+// rejectMessages are the messages of the gateway and of the fabric rest
+// server refined by rejectKind, lower case.
+var rejectMessages = []struct {
+	msg  string
+	kind error
+}{
+	{"insufficient balance", ErrInsufficientBalance},
+	{"token not found", ErrNotFound},
+	{"tx not found", ErrNotFound},
+	// a block or transaction missing from the ledger
+	{"entry not found in index", ErrNotFound},
+	{duplicateNonce, ErrDuplicate},
+}
+
+// rejectKind maps a status:false message to the most specific kind,
+// ErrRejected for a message not known to rejectMessages.
+func rejectKind(msg string) error {
+	m := strings.ToLower(msg)
+
+	for _, r := range rejectMessages {
+		if strings.Contains(m, r.msg) {
+			return r.kind
+		}
+	}
+
+	return ErrRejected
+}
+
+// IsRetryable reports whether err is a transient failure, i.e. a transport
+// error or a 429/5xx status. The caller's own cancellation is never
+// retryable. Retrying a write is only safe if the server cannot have
+// applied it twice.
+func IsRetryable(err error) bool {
+	if err == nil || errors.Is(err, context.Canceled) {
+		return false
+	}
+
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) {
+		return false
+	}
+
+	switch apiErr.Kind {
+	case ErrTransport:
+		return true
+	case ErrHTTPStatus:
+		return apiErr.StatusCode == http.StatusTooManyRequests ||
+			(apiErr.StatusCode >= 500 && apiErr.StatusCode != http.StatusNotImplemented)
+	}
+
+	return false
+}
@@ -0,0 +1,225 @@
+package fabric
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// Newest starts a listener at the current chain height.
+const Newest = ^uint64(0)
+
+// DefaultPollInterval is the wait between two chain height polls.
+const DefaultPollInterval = time.Second
+
+// ListenOptions configures a block listener. Only the non nil channels
+// are fed, and every send blocks until the consumer receives it.
+type ListenOptions struct {
+	Channel string
+	// Peer is the peer queried, the client's first peer if empty.
+	Peer string
+
+	// FromBlock is the first block delivered, Newest for the next block
+	// committed after the listener starts. Pass the Next of a previous
+	// subscription to resume it.
+	FromBlock uint64
+
+	// PollInterval is the wait between two chain height polls,
+	// DefaultPollInterval if 0.
+	PollInterval time.Duration
+	// Backoff is the wait between two attempts after a query error,
+	// DefaultWaitBackoff if zero.
+	Backoff Backoff
+
+	// Blocks receives every block.
+	Blocks chan<- *Block
+	// Txs receives every transaction of every block, valid or not.
+	Txs chan<- *Transaction
+	// ChaincodeEvents receives the events of valid transactions matching
+	// Chaincode and EventName, all of them if empty.
+	ChaincodeEvents chan<- *ChaincodeEvent
+	Chaincode       string
+	EventName       string
+}
+
+// Subscription follows the blocks of a channel until its context is done.
+//
+// Delivery is at least once: a block is delivered in order, first on
+// Blocks then its transactions then its chaincode events, and Next moves
+// past it once all were received. A block cut off by cancellation, or
+// consumed but not yet processed, is delivered again by a subscription
+// resumed from Next.
+type Subscription struct {
+	c    *ChaincodeClient
+	opts ListenOptions
+
+	mu   sync.Mutex
+	next uint64
+	err  error
+	done chan struct{}
+}
+
+// Listen starts following the blocks of opts.Channel. The rest server has
+// no event stream, so the listener polls the chain height and fetches the
+// new blocks.
+func (c *ChaincodeClient) Listen(ctx context.Context, opts ListenOptions) *Subscription {
+	if opts.PollInterval <= 0 {
+		opts.PollInterval = DefaultPollInterval
+	}
+	if opts.Backoff.Initial <= 0 {
+		opts.Backoff = DefaultWaitBackoff
+	}
+
+	s := &Subscription{
+		c:    c,
+		opts: opts,
+		next: opts.FromBlock,
+		done: make(chan struct{}),
+	}
+
+	go s.run(ctx)
+
+	return s
+}
+
+// Next returns the number of the first block not fully delivered yet.
+func (s *Subscription) Next() uint64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.next
+}
+
+// Done is closed when the subscription stops.
+func (s *Subscription) Done() <-chan struct{} {
+	return s.done
+}
+
+// Err returns why the subscription stopped, nil while it runs.
+func (s *Subscription) Err() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.err
+}
+
+func (s *Subscription) stop(err error) {
+	s.mu.Lock()
+	s.err = err
+	s.mu.Unlock()
+
+	close(s.done)
+}
+
+func (s *Subscription) run(ctx context.Context) {
+	fails := 0
+
+	// wait sleeps after a poll, longer after consecutive errors
+	wait := func(err error) bool {
+		d := s.opts.PollInterval
+		if err != nil {
+			d = s.opts.Backoff.delay(fails)
+			fails++
+		} else {
+			fails = 0
+		}
+
+		t := time.NewTimer(d)
+		defer t.Stop()
+
+		select {
+		case <-ctx.Done():
+			return false
+		case <-t.C:
+			return true
+		}
+	}
+
+	for {
+		err := s.poll(ctx)
+		if ctx.Err() != nil {
+			s.stop(ctx.Err())
+			return
+		}
+		if errors.Is(err, ErrNoCredentials) {
+			s.stop(err)
+			return
+		}
+
+		if !wait(err) {
+			s.stop(ctx.Err())
+			return
+		}
+	}
+}
+
+// poll delivers the blocks committed since the last poll.
+func (s *Subscription) poll(ctx context.Context) error {
+	info, err := s.c.ChainInfo(ctx, s.opts.Channel, s.opts.Peer)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	if s.next == Newest {
+		s.next = info.Height
+	}
+	next := s.next
+	s.mu.Unlock()
+
+	for ; next < info.Height; next++ {
+		b, err := s.c.BlockByNumber(ctx, s.opts.Channel, next, s.opts.Peer)
+		if err != nil {
+			return err
+		}
+
+		if !s.deliver(ctx, b) {
+			return ctx.Err()
+		}
+
+		s.mu.Lock()
+		s.next = next + 1
+		s.mu.Unlock()
+	}
+
+	return nil
+}
+
+func (s *Subscription) deliver(ctx context.Context, b *Block) bool {
+	if s.opts.Blocks != nil {
+		select {
+		case s.opts.Blocks <- b:
+		case <-ctx.Done():
+			return false
+		}
+	}
+
+	for _, tx := range b.Txs {
+		if s.opts.Txs != nil {
+			select {
+			case s.opts.Txs <- tx:
+			case <-ctx.Done():
+				return false
+			}
+		}
+	}
+
+	for _, tx := range b.Txs {
+		ev := tx.Event
+		if s.opts.ChaincodeEvents == nil || ev == nil || !tx.Valid() {
+			continue
+		}
+		if (s.opts.Chaincode != "" && ev.Chaincode != s.opts.Chaincode) || (s.opts.EventName != "" && ev.Name != s.opts.EventName) {
+			continue
+		}
+
+		select {
+		case s.opts.ChaincodeEvents <- ev:
+		case <-ctx.Done():
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,271 @@
+package fabric
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+)
+
+// ledger is a stand-in chain served by the query routes, each block with
+// one transaction setting a chaincode event.
+type ledger struct {
+	mu     sync.Mutex
+	blocks []string
+	// fails counts the failures left of a block fetch.
+	fails map[uint64]int
+}
+
+func (l *ledger) add(chaincode, event string, code int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	n := len(l.blocks)
+	l.blocks = append(l.blocks, fmt.Sprintf(`{
+		"header": {"number": "%d", "previous_hash": "", "data_hash": ""},
+		"data": {"data": [{"payload": {
+			"header": {"channel_header": {"tx_id": "tx%d", "type": 3}},
+			"data": {"actions": [{"payload": {"action": {"proposal_response_payload": {"extension": {
+				"events": {"chaincode_id": "%s", "tx_id": "tx%d", "event_name": "%s", "payload": "p%d"}
+			}}}}}]}
+		}}]},
+		"metadata": {"metadata": [[], [], [%d]]}
+	}`, n, n, chaincode, n, event, n, code))
+}
+
+func (l *ledger) reply(w http.ResponseWriter, r *recorded) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if r.Path == "/channels/mychannel" {
+		fmt.Fprintf(w, `{"height": %d}`, len(l.blocks))
+		return
+	}
+
+	var n uint64
+	if _, err := fmt.Sscanf(r.Path, "/channels/mychannel/blocks/%d", &n); err != nil || n >= uint64(len(l.blocks)) {
+		w.Write([]byte(`"Error: Entry not found in index"`))
+		return
+	}
+
+	if l.fails[n] > 0 {
+		l.fails[n]--
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	w.Write([]byte(l.blocks[n]))
+}
+
+func newLedger(t *testing.T, blocks int) (*ledger, *ChaincodeClient, func()) {
+	l := &ledger{fails: map[uint64]int{}}
+	for i := 0; i < blocks; i++ {
+		l.add("mycc", "moved", 0)
+	}
+
+	s := newRestServer(l.reply)
+	return l, newTestChaincode(t, s), s.Close
+}
+
+var testListen = ListenOptions{
+	Channel:      "mychannel",
+	PollInterval: 10 * time.Millisecond,
+	Backoff:      Backoff{Initial: 5 * time.Millisecond, Max: 20 * time.Millisecond, Multiplier: 2},
+}
+
+func recvBlock(t *testing.T, blocks <-chan *Block) *Block {
+	t.Helper()
+
+	select {
+	case b := <-blocks:
+		return b
+	case <-time.After(2 * time.Second):
+		t.Fatal("no block received")
+	}
+	return nil
+}
+
+func TestListen(t *testing.T) {
+	l, c, done := newLedger(t, 3)
+	defer done()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	blocks := make(chan *Block)
+	txs := make(chan *Transaction, 10)
+
+	opts := testListen
+	opts.Blocks = blocks
+	opts.Txs = txs
+	s := c.Listen(ctx, opts)
+
+	for i := uint64(0); i < 3; i++ {
+		if b := recvBlock(t, blocks); b.Number != i {
+			t.Fatalf("block %d, want %d", b.Number, i)
+		}
+	}
+
+	l.add("mycc", "moved", 11)
+	if b := recvBlock(t, blocks); b.Number != 3 {
+		t.Fatalf("block %d, want 3", b.Number)
+	}
+
+	for i := 0; i < 4; i++ {
+		tx := <-txs
+		if tx.TxID != fmt.Sprintf("tx%d", i) || tx.BlockNumber != uint64(i) {
+			t.Errorf("tx %s in block %d, want tx%d", tx.TxID, tx.BlockNumber, i)
+		}
+	}
+
+	cancel()
+	<-s.Done()
+	if s.Err() != context.Canceled {
+		t.Errorf("Err() = %v, want canceled", s.Err())
+	}
+	if s.Next() != 4 {
+		t.Errorf("Next() = %d, want 4", s.Next())
+	}
+}
+
+func TestListenChaincodeEvents(t *testing.T) {
+	l, c, done := newLedger(t, 0)
+	defer done()
+
+	l.add("mycc", "moved", 0)
+	l.add("other", "moved", 0)
+	l.add("mycc", "burned", 0)
+	l.add("mycc", "moved", 11) // invalid, no event
+	l.add("mycc", "moved", 0)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	events := make(chan *ChaincodeEvent)
+
+	opts := testListen
+	opts.ChaincodeEvents = events
+	opts.Chaincode = "mycc"
+	opts.EventName = "moved"
+	c.Listen(ctx, opts)
+
+	for _, want := range []uint64{0, 4} {
+		select {
+		case ev := <-events:
+			if ev.BlockNumber != want || ev.TxID != fmt.Sprintf("tx%d", want) || string(ev.Payload) != fmt.Sprintf("p%d", want) {
+				t.Errorf("event %+v, want block %d", ev, want)
+			}
+		case <-time.After(2 * time.Second):
+			t.Fatal("no event received")
+		}
+	}
+
+	select {
+	case ev := <-events:
+		t.Errorf("unexpected event %+v", ev)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func TestListenResume(t *testing.T) {
+	l, c, done := newLedger(t, 5)
+	defer done()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	blocks := make(chan *Block)
+	opts := testListen
+	opts.Blocks = blocks
+	opts.FromBlock = 3
+	c.Listen(ctx, opts)
+
+	if b := recvBlock(t, blocks); b.Number != 3 {
+		t.Errorf("resumed at block %d, want 3", b.Number)
+	}
+
+	newest := make(chan *Block)
+	opts.Blocks = newest
+	opts.FromBlock = Newest
+	s := c.Listen(ctx, opts)
+
+	// wait for the first poll before adding the block
+	deadline := time.Now().Add(2 * time.Second)
+	for s.Next() == Newest && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	l.add("mycc", "moved", 0)
+
+	if b := recvBlock(t, newest); b.Number != 5 {
+		t.Errorf("Newest started at block %d, want 5", b.Number)
+	}
+}
+
+func TestListenRetry(t *testing.T) {
+	l, c, done := newLedger(t, 3)
+	defer done()
+	l.fails[1] = 3
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	blocks := make(chan *Block)
+	opts := testListen
+	opts.Blocks = blocks
+	c.Listen(ctx, opts)
+
+	// block 1 is retried, never skipped
+	for i := uint64(0); i < 3; i++ {
+		if b := recvBlock(t, blocks); b.Number != i {
+			t.Fatalf("block %d, want %d", b.Number, i)
+		}
+	}
+}
+
+func TestListenRedelivery(t *testing.T) {
+	_, c, done := newLedger(t, 2)
+	defer done()
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	blocks := make(chan *Block)
+	txs := make(chan *Transaction)
+	opts := testListen
+	opts.Blocks = blocks
+	opts.Txs = txs
+	s := c.Listen(ctx, opts)
+
+	// block 0 is received but not its transaction
+	recvBlock(t, blocks)
+	cancel()
+	<-s.Done()
+
+	if s.Next() != 0 {
+		t.Fatalf("Next() = %d after a partial delivery, want 0", s.Next())
+	}
+
+	ctx, cancel = context.WithCancel(context.Background())
+	defer cancel()
+
+	opts.FromBlock = s.Next()
+	c.Listen(ctx, opts)
+	if b := recvBlock(t, blocks); b.Number != 0 {
+		t.Errorf("redelivered block %d, want 0", b.Number)
+	}
+}
+
+func TestListenNoCredentials(t *testing.T) {
+	f := newTestClient(t, "http://127.0.0.1:1")
+
+	s := f.Chaincode().Listen(context.Background(), testListen)
+	select {
+	case <-s.Done():
+	case <-time.After(2 * time.Second):
+		t.Fatal("subscription without credentials still running")
+	}
+	if s.Err() != ErrNoCredentials {
+		t.Errorf("Err() = %v, want ErrNoCredentials", s.Err())
+	}
+}
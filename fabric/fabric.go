@@ -1,20 +1,37 @@
 package fabric
 
 import (
-	"encoding/json"
-	"fabricclient/logger"
+	"crypto/tls"
+	"errors"
 	"fabricclient/util"
-	"io/ioutil"
 	"net/http"
-	"os"
-	"sync"
+	"strings"
 	"time"
 )
 
 type FabricClient struct {
-	urlHead string
+	pool    *pool
 	cli     *http.Client
-	tp      *TestParam
+	log     Logger
+	signer  Signer
+	network *util.Network
+
+	baseURLs  []string
+	scheme    string
+	balancer  Balancer
+	health    HealthCheck
+	transport http.RoundTripper
+	tlsConfig *tls.Config
+	timeout   time.Duration
+
+	waitBackoff Backoff
+	retry       RetryPolicy
+	idempotent  bool
+
+	restURL   string
+	peers     []string
+	cred      *Credentials
+	chaincode *ChaincodeClient
 }
 
 type Wallet struct {
@@ -23,210 +40,120 @@ type Wallet struct {
 	PrivKey string `json:"privKey"`
 }
 
-type TestParam struct {
-	Token1Wallet Wallet `json:"token1Wallet"`
-	TokenID1     string `json:"tokenID1"`
-	Token2Wallet Wallet `json:"token2Wallet"`
-	TokenID2     string `json:"tokenID2"`
-}
-
-func (f *FabricClient) testTransfer() error {
-	tp := f.tp
-
-	for i := 0; i < 10; i++ {
-		f.Transfer(tp.TokenID1, tp.Token1Wallet.Address, tp.Token1Wallet.PrivKey, tp.Token2Wallet.Address, "50")
-		f.QueryBalance(tp.Token1Wallet.Address)
-		f.QueryBalance(tp.Token2Wallet.Address)
-	}
-
-	return nil
-}
-
-func (f *FabricClient) genWallets(num int) []*Wallet {
-	ws := []*Wallet{}
-
-	for i := 0; i < num; i++ {
-		w := &Wallet{}
-		w.PrivKey, _, w.Address = util.GetNewAddress()
-		ws = append(ws, w)
-	}
-
-	return ws
-}
-
-func (f *FabricClient) highConcurrent() error {
-	walletMum := 50
-	group1 := f.genWallets(walletMum)
-	group2 := f.genWallets(walletMum)
-
-	for i := 0; i < walletMum; i++ {
-		_, err := f.Transfer(f.tp.TokenID1, f.tp.Token1Wallet.Address, f.tp.Token1Wallet.PrivKey, group1[i].Address, "1")
-		if err != nil {
-			logger.Error(err)
-			return err
-		}
-	}
-
-	for i := 0; i < walletMum; i++ {
-		f.QueryBalance(group1[i].Address)
-	}
-
-	var wg sync.WaitGroup
-
-	for i := 0; i < walletMum; i++ {
-		wg.Add(1)
-		go func(fromAddr, fromPrivKey, toAddr string) {
-			defer wg.Done()
-
-			logger.Info("transfer start", fromAddr, toAddr)
-			time.Sleep(time.Second * 2)
+// configure returns a client holding the defaults overridden by opts,
+// none of its parts built yet.
+func configure(opts []Option) *FabricClient {
+	f := &FabricClient{
+		scheme:  "http",
+		health:  DefaultHealthCheck,
+		log:     stdLogger{},
+		network: util.MainNet,
 
-			_, err := f.Transfer(f.tp.TokenID1, fromAddr, fromPrivKey, toAddr, "1")
-			if err != nil {
-				logger.Error(err)
-				return
-			}
+		waitBackoff: DefaultWaitBackoff,
+		retry:       noRetry,
 
-			logger.Info("transfer end", fromAddr, toAddr)
-		}(group1[i].Address, group1[i].PrivKey, group2[i].Address)
+		restURL: DefaultRestURL,
+		peers:   DefaultPeers,
 	}
 
-	wg.Wait()
-
-	for i := 0; i < walletMum; i++ {
-		f.QueryBalance(group1[i].Address)
+	for _, opt := range opts {
+		opt(f)
 	}
 
-	for i := 0; i < walletMum; i++ {
-		f.QueryBalance(group2[i].Address)
+	if f.signer == nil {
+		f.signer = wifSigner{f.network}
 	}
 
-	return nil
+	return f
 }
 
-func (f *FabricClient) testApiInit() error {
-	var err error
-
-	if f.tp != nil {
-		err = f.QueryToken(f.tp.TokenID1)
-		if err == nil {
-			return nil
-		}
-	}
-
-	tp := &TestParam{}
-
-	tp.Token1Wallet.PrivKey, _, tp.Token1Wallet.Address = util.GetNewAddress()
-	tp.TokenID1, err = f.IssueToken(tp.Token1Wallet.Address, tp.Token1Wallet.PrivKey, "OCE", "10000")
-	if err != nil {
-		logger.Error(err)
-		return err
-	}
-
-	logger.Info("tp.TokenID1 =", tp.TokenID1)
+// Network returns the network of the keys and addresses of the client.
+func (f *FabricClient) Network() *util.Network {
+	return f.network
+}
 
-	err = f.QueryToken(tp.TokenID1)
+// NewWallet returns a new wallet on the network of the client.
+func (f *FabricClient) NewWallet() (*Wallet, error) {
+	wif, _, address, err := f.network.NewKey()
 	if err != nil {
-		logger.Error(err)
-		return err
+		return nil, err
 	}
 
-	tp.TokenID2, _ = f.IssueToken(tp.Token1Wallet.Address, tp.Token1Wallet.PrivKey, "OCE2", "20000")
-	err = f.QueryBalance(tp.Token1Wallet.Address)
-	if err != nil {
-		logger.Error(err)
-		return err
-	}
+	return &Wallet{Address: address, PrivKey: wif}, nil
+}
 
-	tp.Token2Wallet.PrivKey, _, tp.Token2Wallet.Address = util.GetNewAddress()
-	txID, err := f.Transfer(tp.TokenID1, tp.Token1Wallet.Address, tp.Token1Wallet.PrivKey, tp.Token2Wallet.Address, "100")
+// WalletOf returns the wallet of the wif key on the network of the client.
+func (f *FabricClient) WalletOf(privKey string) (*Wallet, error) {
+	pubKey, err := f.network.PubKey(privKey)
 	if err != nil {
-		logger.Error(err)
-		return err
+		return nil, err
 	}
 
-	err = f.QueryTx(txID)
+	address, err := f.network.Address(pubKey)
 	if err != nil {
-		logger.Error(err)
-		return err
+		return nil, err
 	}
 
-	f.QueryBalance(tp.Token1Wallet.Address)
-	f.QueryBalance(tp.Token2Wallet.Address)
+	return &Wallet{Address: address, PrivKey: privKey}, nil
+}
 
-	f.tp = tp
-	tpData, err := json.Marshal(tp)
-	if err != nil {
-		logger.Error(err)
-		return err
+// httpClient builds the http.Client of the http, tls and timeout options.
+func (f *FabricClient) httpClient() (*http.Client, error) {
+	// copy the http.Client so options never modify one owned by the caller
+	cli := &http.Client{}
+	if f.cli != nil {
+		*cli = *f.cli
 	}
-
-	err = ioutil.WriteFile("conf/TestParam.json", tpData, os.ModePerm)
-	if err != nil {
-		logger.Error(err)
-		return err
+	if f.transport != nil {
+		cli.Transport = f.transport
 	}
-
-	return nil
-}
-
-func (f *FabricClient) testApi() error {
-	if util.IsFileExist("conf/TestParam.json") {
-		data, err := ioutil.ReadFile("conf/TestParam.json")
+	if f.tlsConfig != nil {
+		rt, err := tlsTransport(cli.Transport, f.tlsConfig)
 		if err != nil {
-			logger.Error(err)
-			return err
+			return nil, err
 		}
-
-		tp := &TestParam{}
-		err = json.Unmarshal(data, tp)
-		if err != nil {
-			logger.Error(err)
-			return err
-		}
-
-		f.tp = tp
+		cli.Transport = rt
 	}
-
-	err := f.testApiInit()
-	if err != nil {
-		logger.Error(err)
-		return err
+	if f.timeout > 0 {
+		cli.Timeout = f.timeout
 	}
 
-	/*	err = f.testTransfer()
-		if err != nil {
-			logger.Error(err)
-			return err
-		}*/
-
-	return nil
+	return cli, nil
 }
 
-func (f *FabricClient) testing(wg *sync.WaitGroup) {
-	defer wg.Done()
-	var err error
+// NewFabricClient builds a client of the ocean gateways at ipport, a comma
+// separated list of host:port. It sends no request.
+func NewFabricClient(ipport string, opts ...Option) (*FabricClient, error) {
+	f := configure(opts)
 
-	err = f.testApi()
+	cli, err := f.httpClient()
 	if err != nil {
-		logger.Error(err)
-		return
+		return nil, err
 	}
+	f.cli = cli
 
-	err = f.highConcurrent()
-	if err != nil {
-		logger.Error(err)
-		return
+	urls := []string{}
+	if len(f.baseURLs) > 0 {
+		for _, u := range f.baseURLs {
+			urls = append(urls, strings.TrimRight(u, "/"))
+		}
+	} else {
+		for _, ipport := range strings.Split(ipport, ",") {
+			ipport = strings.TrimSpace(ipport)
+			if ipport != "" {
+				urls = append(urls, f.scheme+"://"+ipport)
+			}
+		}
 	}
-}
 
-func NewFabricClient(ipport string, wg *sync.WaitGroup) (*FabricClient, error) {
-	f := new(FabricClient)
+	if len(urls) == 0 {
+		return nil, errors.New("fabric server address is empty")
+	}
 
-	f.cli = &http.Client{}
-	f.urlHead = "http://" + ipport
+	f.pool = newPool(urls, f.balancer, f.health)
+	f.pool.probe = f.probe
 
-	go f.testing(wg)
+	f.chaincode = newChaincodeClient(f.cli, f.restURL, f.log, f.peers, f.cred)
 
 	return f, nil
 }
@@ -0,0 +1,142 @@
+package fabric
+
+import (
+	"fabricclient/fabric/fabrictest"
+	"fabricclient/util"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func newTestClient(t *testing.T, url string, opts ...Option) *FabricClient {
+	t.Helper()
+
+	opts = append([]Option{WithBaseURL(url), WithLogger(fabrictest.NopLogger{})}, opts...)
+	f, err := NewFabricClient("", opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return f
+}
+
+func newWallet() *Wallet {
+	w := &Wallet{}
+	w.Address, w.PrivKey = fabrictest.NewKey()
+	return w
+}
+
+// issue creates a token owned by a new wallet.
+func issue(t *testing.T, f *FabricClient, total string) (*Wallet, string) {
+	t.Helper()
+
+	w := newWallet()
+	tokenID, err := f.IssueToken(w.Address, w.PrivKey, "OCE", total)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return w, tokenID
+}
+
+func urls(f *FabricClient) []string {
+	us := []string{}
+	for _, n := range f.pool.nodes {
+		us = append(us, n.url)
+	}
+	return us
+}
+
+func TestNewFabricClient(t *testing.T) {
+	f, err := NewFabricClient("127.0.0.1:4000", WithLogger(fabrictest.NopLogger{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := urls(f); len(got) != 1 || got[0] != "http://127.0.0.1:4000" {
+		t.Errorf("urls = %v", got)
+	}
+
+	f, err = NewFabricClient("127.0.0.1:4000", WithScheme("https"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := urls(f); len(got) != 1 || got[0] != "https://127.0.0.1:4000" {
+		t.Errorf("urls = %v", got)
+	}
+
+	f, err = NewFabricClient("ignored:1", WithBaseURL("http://gateway:4000/"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := urls(f); len(got) != 1 || got[0] != "http://gateway:4000" {
+		t.Errorf("urls = %v", got)
+	}
+
+	f, err = NewFabricClient("10.0.0.1:4000, 10.0.0.2:4000")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := urls(f); len(got) != 2 || got[0] != "http://10.0.0.1:4000" || got[1] != "http://10.0.0.2:4000" {
+		t.Errorf("urls = %v", got)
+	}
+
+	_, err = NewFabricClient("")
+	if err == nil {
+		t.Error("NewFabricClient without address succeeded")
+	}
+}
+
+func TestNewFabricClientKeepsCallerClient(t *testing.T) {
+	cli := &http.Client{}
+	f, err := NewFabricClient("127.0.0.1:4000", WithHTTPClient(cli), WithTimeout(time.Second))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if cli.Timeout != 0 {
+		t.Error("WithTimeout modified the caller's http.Client")
+	}
+	if f.cli.Timeout != time.Second {
+		t.Errorf("client timeout = %v, want 1s", f.cli.Timeout)
+	}
+}
+
+func TestNewFabricClientNoSideEffect(t *testing.T) {
+	s := fabrictest.NewServer()
+	defer s.Close()
+
+	newTestClient(t, s.URL)
+
+	time.Sleep(50 * time.Millisecond)
+	if s.TxCount() != 0 {
+		t.Error("NewFabricClient sent transfers")
+	}
+}
+
+func TestWithNetwork(t *testing.T) {
+	s := fabrictest.NewServer()
+	defer s.Close()
+	s.Network = util.TestNet
+
+	f := newTestClient(t, s.URL, WithNetwork(util.TestNet))
+	w, err := f.NewWallet()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, err := f.WalletOf(w.PrivKey); err != nil || *got != *w {
+		t.Errorf("WalletOf = %+v, %v", got, err)
+	}
+	if _, err := util.MainNet.PubKey(w.PrivKey); err == nil {
+		t.Errorf("wallet %+v is a mainnet one", w)
+	}
+
+	if _, err := f.IssueToken(w.Address, w.PrivKey, "OCE", "10"); err != nil {
+		t.Errorf("IssueToken on testnet: %v", err)
+	}
+
+	// the default client signs with mainnet keys only
+	mainnet := newTestClient(t, s.URL)
+	if _, err := mainnet.IssueToken(w.Address, w.PrivKey, "OCE", "10"); err == nil {
+		t.Error("IssueToken with a testnet key on mainnet succeeded")
+	}
+}
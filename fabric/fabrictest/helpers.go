@@ -0,0 +1,18 @@
+package fabrictest
+
+import (
+	"fabricclient/util"
+)
+
+// NopLogger discards the logs of a client under test.
+type NopLogger struct{}
+
+func (NopLogger) Debug(v ...interface{}) {}
+func (NopLogger) Info(v ...interface{})  {}
+func (NopLogger) Error(v ...interface{}) {}
+
+// NewKey returns the address and wif private key of a new mainnet wallet.
+func NewKey() (string, string) {
+	privKey, _, address := util.GetNewAddress()
+	return address, privKey
+}
@@ -0,0 +1,512 @@
+// Package fabrictest provides an in-process ocean gateway for tests of code
+// built on fabric.FabricClient.
+package fabrictest
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fabricclient/util"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const apiPrefix = "/ocean/v1/"
+
+// Endpoint names accepted by Inject.
+const (
+	IssueToken   = "issueToken"
+	Transfer     = "transfer"
+	QueryToken   = "queryToken"
+	QueryBalance = "queryBalance"
+	QueryTx      = "queryTx"
+)
+
+// Fault alters the handling of requests to one endpoint.
+type Fault struct {
+	// Delay is added before the request is handled.
+	Delay time.Duration
+	// StatusCode is the http status returned, 200 if zero.
+	StatusCode int
+	// Message, if set, is returned as a status:false response.
+	Message string
+	// Body, if set, is returned verbatim instead of a json response.
+	Body string
+	// Drop closes the connection without any response.
+	Drop bool
+	// AfterApply applies the request to the ledger before failing, as if
+	// the response was lost on the way back.
+	AfterApply bool
+	// Times is the number of requests affected, 0 for all of them.
+	Times int
+}
+
+type token struct {
+	TokenID     string `json:"tokenID"`
+	TokenName   string `json:"tokenName"`
+	Address     string `json:"address"`
+	TotalNumber string `json:"totalNumber"`
+}
+
+type tx struct {
+	TxID           string `json:"txID"`
+	PubKey         string `json:"pubKey"`
+	Origin         string `json:"origin"`
+	Signature      string `json:"signature"`
+	ValidationCode string `json:"validationCode,omitempty"`
+
+	committedAt time.Time
+}
+
+// Server is an ocean gateway keeping its ledger in memory. Every write is
+// checked with util.Verify and must be signed by the key of the address
+// it spends from. It answers with the documented response shapes only,
+// the fields below opt in to behaviours the gateway is not known to have.
+// Set them before the first request.
+type Server struct {
+	*httptest.Server
+
+	// DedupeNonce rejects a transfer whose origin nonce was already
+	// applied with a "duplicate nonce" message carrying the first txID.
+	DedupeNonce bool
+	// ValidationCode, if set, is the validationCode returned by queryTx
+	// for every transfer.
+	ValidationCode string
+	// Network encodes the addresses of the signing keys, util.MainNet if
+	// nil.
+	Network *util.Network
+
+	mu          sync.Mutex
+	tokens      map[string]*token
+	balances    map[string]map[string]*big.Int
+	txs         map[string]*tx
+	nonces      map[string]string
+	seq         int
+	latency     time.Duration
+	commitDelay time.Duration
+	faults      map[string]*Fault
+}
+
+func newServer() *Server {
+	return &Server{
+		tokens:   map[string]*token{},
+		balances: map[string]map[string]*big.Int{},
+		txs:      map[string]*tx{},
+		nonces:   map[string]string{},
+		faults:   map[string]*Fault{},
+	}
+}
+
+// NewServer starts a Server, Close it when done.
+func NewServer() *Server {
+	s := newServer()
+	s.Server = httptest.NewServer(s)
+	return s
+}
+
+// NewUnstartedServer returns a Server whose embedded httptest.Server is not
+// started yet, e.g. to set its TLS config first.
+func NewUnstartedServer() *Server {
+	s := newServer()
+	s.Server = httptest.NewUnstartedServer(s)
+	return s
+}
+
+// Inject sets the fault of endpoint, replacing the previous one.
+func (s *Server) Inject(endpoint string, f Fault) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.faults[endpoint] = &f
+}
+
+// ClearFaults removes every injected fault.
+func (s *Server) ClearFaults() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.faults = map[string]*Fault{}
+}
+
+// SetLatency delays every request by d.
+func (s *Server) SetLatency(d time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.latency = d
+}
+
+// SetCommitDelay makes transfers visible to queryTx only d after they were
+// accepted, like a real orderer would.
+func (s *Server) SetCommitDelay(d time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.commitDelay = d
+}
+
+// Balance returns the amount of tokenID held by address.
+func (s *Server) Balance(address, tokenID string) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if n, ok := s.balances[address][tokenID]; ok {
+		return n.String()
+	}
+
+	return "0"
+}
+
+// TxCount returns the number of accepted transfers.
+func (s *Server) TxCount() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return len(s.txs)
+}
+
+// fault returns the fault of endpoint and consumes one use of it.
+func (s *Server) fault(endpoint string) *Fault {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	f, ok := s.faults[endpoint]
+	if !ok {
+		return nil
+	}
+
+	if f.Times > 0 {
+		f.Times--
+		if f.Times == 0 {
+			delete(s.faults, endpoint)
+		}
+	}
+
+	c := *f
+	return &c
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !strings.HasPrefix(r.URL.Path, apiPrefix) {
+		http.NotFound(w, r)
+		return
+	}
+
+	endpoint := strings.TrimPrefix(r.URL.Path, apiPrefix)
+	arg := ""
+	if i := strings.Index(endpoint, "/"); i >= 0 {
+		arg = endpoint[i+1:]
+		endpoint = endpoint[:i]
+	}
+
+	s.mu.Lock()
+	latency := s.latency
+	s.mu.Unlock()
+
+	f := s.fault(endpoint)
+
+	if f != nil {
+		latency += f.Delay
+	}
+	if latency > 0 {
+		select {
+		case <-time.After(latency):
+		case <-r.Context().Done():
+			return
+		}
+	}
+
+	if f != nil && !f.AfterApply {
+		writeFault(w, f)
+		return
+	}
+
+	var res interface{}
+
+	switch {
+	case endpoint == IssueToken && r.Method == "POST":
+		res = s.issueToken(r)
+	case endpoint == Transfer && r.Method == "POST":
+		res = s.transfer(r)
+	case endpoint == QueryToken && r.Method == "GET":
+		res = s.queryToken(arg)
+	case endpoint == QueryBalance && r.Method == "GET":
+		res = s.queryBalance(arg)
+	case endpoint == QueryTx && r.Method == "GET":
+		res = s.queryTx(arg)
+	default:
+		http.NotFound(w, r)
+		return
+	}
+
+	if f != nil {
+		writeFault(w, f)
+		return
+	}
+
+	writeJson(w, http.StatusOK, res)
+}
+
+func writeFault(w http.ResponseWriter, f *Fault) {
+	if f.Drop {
+		hj, ok := w.(http.Hijacker)
+		if ok {
+			conn, _, err := hj.Hijack()
+			if err == nil {
+				conn.Close()
+				return
+			}
+		}
+		f.StatusCode = http.StatusBadGateway
+	}
+
+	code := f.StatusCode
+	if code == 0 {
+		code = http.StatusOK
+	}
+
+	if f.Body != "" {
+		w.WriteHeader(code)
+		w.Write([]byte(f.Body))
+		return
+	}
+
+	writeJson(w, code, fail(f.Message))
+}
+
+func writeJson(w http.ResponseWriter, code int, v interface{}) {
+	data, _ := json.Marshal(v)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	w.Write(data)
+}
+
+type response struct {
+	Status  bool   `json:"status"`
+	Message string `json:"message"`
+	TokenID string `json:"tokenID,omitempty"`
+	TxID    string `json:"txID,omitempty"`
+	Data    []byte `json:"data,omitempty"`
+}
+
+func fail(msg string) *response {
+	return &response{Message: msg}
+}
+
+type sendData struct {
+	PubKey    string `json:"pubKey"`
+	Origin    string `json:"origin"`
+	Signature string `json:"signature"`
+}
+
+// verify decodes a signed envelope into origin and checks that it was
+// signed by the key of address() on net.
+func verify(r *http.Request, net *util.Network, origin interface{}, address func() string) (*sendData, string) {
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		return nil, err.Error()
+	}
+
+	sd := &sendData{}
+	err = json.Unmarshal(body, sd)
+	if err != nil {
+		return nil, "invalid request: " + err.Error()
+	}
+
+	ok, err := util.Verify(sd.PubKey, sd.Origin, sd.Signature)
+	if err != nil || !ok {
+		return nil, "invalid signature"
+	}
+
+	originJson, err := hex.DecodeString(sd.Origin)
+	if err != nil {
+		return nil, "invalid origin: " + err.Error()
+	}
+
+	err = json.Unmarshal(originJson, origin)
+	if err != nil {
+		return nil, "invalid origin: " + err.Error()
+	}
+
+	if addr, err := net.Address(sd.PubKey); err != nil || addr != address() {
+		return nil, "address does not match public key"
+	}
+
+	return sd, ""
+}
+
+func parseNumber(num string) (*big.Int, bool) {
+	n, ok := new(big.Int).SetString(num, 10)
+	if !ok || n.Sign() <= 0 {
+		return nil, false
+	}
+
+	return n, true
+}
+
+func (s *Server) credit(address, tokenID string, n *big.Int) {
+	b, ok := s.balances[address]
+	if !ok {
+		b = map[string]*big.Int{}
+		s.balances[address] = b
+	}
+
+	if _, ok := b[tokenID]; !ok {
+		b[tokenID] = new(big.Int)
+	}
+	b[tokenID].Add(b[tokenID], n)
+}
+
+// newID returns a unique id derived from seed, hex encoded.
+func (s *Server) newID(seed string) string {
+	s.seq++
+	h := sha256.Sum256([]byte(seed + strconv.Itoa(s.seq)))
+	return hex.EncodeToString(h[:])
+}
+
+func (s *Server) network() *util.Network {
+	if s.Network == nil {
+		return util.MainNet
+	}
+	return s.Network
+}
+
+func (s *Server) issueToken(r *http.Request) *response {
+	origin := struct {
+		Address     string `json:"address"`
+		TokenName   string `json:"tokenName"`
+		TotalNumber string `json:"totalNumber"`
+	}{}
+
+	sd, msg := verify(r, s.network(), &origin, func() string { return origin.Address })
+	if msg != "" {
+		return fail(msg)
+	}
+
+	n, ok := parseNumber(origin.TotalNumber)
+	if !ok || origin.TokenName == "" {
+		return fail("invalid token")
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	t := &token{
+		TokenID:     s.newID(sd.Origin),
+		TokenName:   origin.TokenName,
+		Address:     origin.Address,
+		TotalNumber: n.String(),
+	}
+	s.tokens[t.TokenID] = t
+	s.credit(t.Address, t.TokenID, n)
+
+	return &response{Status: true, TokenID: t.TokenID}
+}
+
+func (s *Server) transfer(r *http.Request) *response {
+	origin := struct {
+		FromAddress string `json:"fromAddress"`
+		ToAddress   string `json:"toAddress"`
+		TokenID     string `json:"tokenID"`
+		Number      string `json:"number"`
+		Nonce       string `json:"nonce"`
+	}{}
+
+	sd, msg := verify(r, s.network(), &origin, func() string { return origin.FromAddress })
+	if msg != "" {
+		return fail(msg)
+	}
+
+	n, ok := parseNumber(origin.Number)
+	if !ok || origin.ToAddress == "" {
+		return fail("invalid transfer")
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.DedupeNonce && origin.Nonce != "" {
+		if txID, ok := s.nonces[origin.Nonce]; ok {
+			return &response{Message: "duplicate nonce", TxID: txID}
+		}
+	}
+
+	if _, ok := s.tokens[origin.TokenID]; !ok {
+		return fail("token not found")
+	}
+
+	from := s.balances[origin.FromAddress][origin.TokenID]
+	if from == nil || from.Cmp(n) < 0 {
+		return fail("insufficient balance")
+	}
+
+	from.Sub(from, n)
+	s.credit(origin.ToAddress, origin.TokenID, n)
+
+	t := &tx{
+		TxID:           s.newID(sd.Origin),
+		PubKey:         sd.PubKey,
+		Origin:         sd.Origin,
+		Signature:      sd.Signature,
+		ValidationCode: s.ValidationCode,
+		committedAt:    time.Now().Add(s.commitDelay),
+	}
+	s.txs[t.TxID] = t
+	if s.DedupeNonce && origin.Nonce != "" {
+		s.nonces[origin.Nonce] = t.TxID
+	}
+
+	return &response{Status: true, TxID: t.TxID}
+}
+
+func dataResponse(v interface{}) *response {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return fail(err.Error())
+	}
+
+	return &response{Status: true, Data: data}
+}
+
+func (s *Server) queryToken(tokenID string) *response {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	t, ok := s.tokens[tokenID]
+	if !ok {
+		return fail("token not found")
+	}
+
+	return dataResponse(t)
+}
+
+func (s *Server) queryBalance(address string) *response {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	b := map[string]string{}
+	for tokenID, n := range s.balances[address] {
+		b[tokenID] = n.String()
+	}
+
+	return dataResponse(b)
+}
+
+func (s *Server) queryTx(txID string) *response {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	t, ok := s.txs[txID]
+	if !ok || time.Now().Before(t.committedAt) {
+		return fail("tx not found")
+	}
+
+	return dataResponse(t)
+}
@@ -0,0 +1,35 @@
+// Package testclient builds the fabric.FabricClient of a test against a
+// fabrictest.Server. It is apart from fabrictest, which the tests of
+// package fabric import.
+package testclient
+
+import (
+	"fabricclient/fabric"
+	"fabricclient/fabric/fabrictest"
+	"testing"
+	"time"
+)
+
+// Backoff is the WaitForTx polling of New, short for the in-process server.
+var Backoff = fabric.Backoff{Initial: time.Millisecond, Max: 10 * time.Millisecond, Multiplier: 2}
+
+// New returns a client of s logging nothing and polling with Backoff, opts
+// applied last.
+func New(t *testing.T, s *fabrictest.Server, opts ...fabric.Option) *fabric.FabricClient {
+	t.Helper()
+
+	opts = append([]fabric.Option{fabric.WithBaseURL(s.URL), fabric.WithLogger(fabrictest.NopLogger{}), fabric.WithWaitBackoff(Backoff)}, opts...)
+	f, err := fabric.NewFabricClient("", opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return f
+}
+
+// NewWallet returns a new mainnet wallet.
+func NewWallet() fabric.Wallet {
+	w := fabric.Wallet{}
+	w.Address, w.PrivKey = fabrictest.NewKey()
+	return w
+}
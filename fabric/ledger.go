@@ -0,0 +1,375 @@
+package fabric
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// ChainInfo is the height and the last block hashes of a channel, the
+// hashes hex encoded.
+type ChainInfo struct {
+	Height            uint64
+	CurrentBlockHash  string
+	PreviousBlockHash string
+
+	Raw json.RawMessage
+}
+
+// Block is a block returned by the query routes. Hashes are hex encoded.
+type Block struct {
+	Number       uint64
+	PreviousHash string
+	DataHash     string
+	// TxCount is the number of envelopes in the block.
+	TxCount int
+	// Txs are the decoded envelopes with their validation codes.
+	Txs []*Transaction
+
+	// Raw is the block json decoded by the rest server.
+	Raw json.RawMessage
+}
+
+// ProcessedTx is a transaction of the ledger with its validation code.
+type ProcessedTx struct {
+	TxID           string
+	ValidationCode int
+	// ValidationStatus is the name of ValidationCode, VALID for a valid
+	// transaction.
+	ValidationStatus string
+	// Tx is the decoded envelope.
+	Tx *Transaction
+
+	// Raw is the processed transaction json decoded by the rest server.
+	Raw json.RawMessage
+}
+
+// Valid reports whether the transaction was committed as valid.
+func (tx *ProcessedTx) Valid() bool {
+	return tx.ValidationCode == 0
+}
+
+// ChaincodeInfo is an installed or instantiated chaincode.
+type ChaincodeInfo struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+	Path    string `json:"path"`
+}
+
+// txValidationCodes names the fabric TxValidationCode values.
+var txValidationCodes = []string{
+	"VALID",
+	"NIL_ENVELOPE",
+	"BAD_PAYLOAD",
+	"BAD_COMMON_HEADER",
+	"BAD_CREATOR_SIGNATURE",
+	"INVALID_ENDORSER_TRANSACTION",
+	"INVALID_CONFIG_TRANSACTION",
+	"UNSUPPORTED_TX_PAYLOAD",
+	"BAD_PROPOSAL_TXID",
+	"DUPLICATE_TXID",
+	"ENDORSEMENT_POLICY_FAILURE",
+	"MVCC_READ_CONFLICT",
+	"PHANTOM_READ_CONFLICT",
+	"UNKNOWN_TX_TYPE",
+	"TARGET_CHAIN_NOT_FOUND",
+	"MARSHAL_TX_ERROR",
+	"NIL_TXACTION",
+	"EXPIRED_CHAINCODE",
+	"CHAINCODE_VERSION_CONFLICT",
+	"BAD_HEADER_EXTENSION",
+	"BAD_CHANNEL_HEADER",
+	"BAD_RESPONSE_PAYLOAD",
+	"BAD_RWSET",
+	"ILLEGAL_WRITESET",
+	"INVALID_WRITESET",
+	"INVALID_CHAINCODE",
+}
+
+// ValidationCodeName returns the name of a fabric transaction validation
+// code.
+func ValidationCodeName(code int) string {
+	if code == 254 {
+		return "NOT_VALIDATED"
+	}
+	if code < 0 || code >= len(txValidationCodes) {
+		return "INVALID_OTHER_REASON"
+	}
+	return txValidationCodes[code]
+}
+
+// ledgerGet sends a query route request to peer, the client's first peer
+// if empty, and decodes the json response into out. The rest server
+// answers failures as an error sentence.
+func (c *ChaincodeClient) ledgerGet(ctx context.Context, endpoint, peer string, q url.Values, out interface{}) ([]byte, error) {
+	if peer == "" && len(c.peers) > 0 {
+		peer = c.peers[0]
+	}
+	if q == nil {
+		q = url.Values{}
+	}
+	if peer != "" {
+		q.Set("peer", peer)
+	}
+
+	body, err := c.do(ctx, "GET", endpoint+"?"+q.Encode(), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	err = json.Unmarshal(body, out)
+	if err != nil {
+		msg := strings.TrimSpace(string(body))
+
+		var str string
+		if json.Unmarshal(body, &str) == nil {
+			msg = str
+		}
+
+		apiErr := &APIError{Kind: ErrBadResponse, Endpoint: endpoint, Body: body, Err: err}
+		if msg != "" && !strings.HasPrefix(msg, "{") && !strings.HasPrefix(msg, "[") {
+			apiErr = &APIError{Kind: rejectKind(msg), Endpoint: endpoint, Message: msg, Body: body}
+		}
+		c.log.Error(apiErr)
+		return nil, apiErr
+	}
+
+	return body, nil
+}
+
+func channelPath(channel string) string {
+	return "/channels/" + url.PathEscape(channel)
+}
+
+// ChainInfo returns the height and last block hashes of channel seen by
+// peer, the client's first peer if empty.
+func (c *ChaincodeClient) ChainInfo(ctx context.Context, channel, peer string) (*ChainInfo, error) {
+	res := struct {
+		Height            jsonUint  `json:"height"`
+		CurrentBlockHash  jsonBytes `json:"currentBlockHash"`
+		PreviousBlockHash jsonBytes `json:"previousBlockHash"`
+	}{}
+
+	body, err := c.ledgerGet(ctx, channelPath(channel), peer, nil, &res)
+	if err != nil {
+		return nil, err
+	}
+
+	return &ChainInfo{
+		Height:            uint64(res.Height),
+		CurrentBlockHash:  hex.EncodeToString(res.CurrentBlockHash),
+		PreviousBlockHash: hex.EncodeToString(res.PreviousBlockHash),
+		Raw:               body,
+	}, nil
+}
+
+// BlockByNumber returns block number of channel.
+func (c *ChaincodeClient) BlockByNumber(ctx context.Context, channel string, number uint64, peer string) (*Block, error) {
+	return c.block(ctx, channelPath(channel)+"/blocks/"+strconv.FormatUint(number, 10), peer, nil)
+}
+
+// BlockByHash returns the block of channel with the hex encoded hash.
+func (c *ChaincodeClient) BlockByHash(ctx context.Context, channel, hash, peer string) (*Block, error) {
+	return c.block(ctx, channelPath(channel)+"/blocks", peer, url.Values{"hash": {hash}})
+}
+
+func (c *ChaincodeClient) block(ctx context.Context, endpoint, peer string, q url.Values) (*Block, error) {
+	var res map[string]json.RawMessage
+
+	body, err := c.ledgerGet(ctx, endpoint, peer, q, &res)
+	if err != nil {
+		return nil, err
+	}
+
+	b, err := DecodeBlock(body)
+	if err != nil {
+		apiErr := &APIError{Kind: ErrBadResponse, Endpoint: endpoint, Body: body, Err: err}
+		c.log.Error(apiErr)
+		return nil, apiErr
+	}
+
+	return b, nil
+}
+
+// TransactionByID returns the transaction txID of channel with its
+// validation code and its decoded envelope.
+func (c *ChaincodeClient) TransactionByID(ctx context.Context, channel, txID, peer string) (*ProcessedTx, error) {
+	endpoint := channelPath(channel) + "/transactions/" + url.PathEscape(txID)
+
+	var res map[string]json.RawMessage
+
+	body, err := c.ledgerGet(ctx, endpoint, peer, nil, &res)
+	if err != nil {
+		return nil, err
+	}
+
+	tx, err := DecodeTransaction(body)
+	if err != nil {
+		apiErr := &APIError{Kind: ErrBadResponse, Endpoint: endpoint, Body: body, Err: err}
+		c.log.Error(apiErr)
+		return nil, apiErr
+	}
+	if tx.TxID == "" {
+		tx.TxID = txID
+	}
+
+	return &ProcessedTx{
+		TxID:             tx.TxID,
+		ValidationCode:   tx.ValidationCode,
+		ValidationStatus: tx.ValidationStatus,
+		Tx:               tx,
+		Raw:              body,
+	}, nil
+}
+
+// InstalledChaincodes returns the chaincodes installed on peer.
+func (c *ChaincodeClient) InstalledChaincodes(ctx context.Context, peer string) ([]ChaincodeInfo, error) {
+	return c.chaincodes(ctx, peer, url.Values{"type": {"installed"}})
+}
+
+// InstantiatedChaincodes returns the chaincodes instantiated on channel.
+func (c *ChaincodeClient) InstantiatedChaincodes(ctx context.Context, channel, peer string) ([]ChaincodeInfo, error) {
+	return c.chaincodes(ctx, peer, url.Values{"type": {"instantiated"}, "channel": {channel}})
+}
+
+func (c *ChaincodeClient) chaincodes(ctx context.Context, peer string, q url.Values) ([]ChaincodeInfo, error) {
+	// the sample formats each chaincode as "name: a, version: b, path: c"
+	res := []json.RawMessage{}
+
+	_, err := c.ledgerGet(ctx, "/chaincodes", peer, q, &res)
+	if err != nil {
+		return nil, err
+	}
+
+	infos := []ChaincodeInfo{}
+	for _, r := range res {
+		info := ChaincodeInfo{}
+
+		var s string
+		if json.Unmarshal(r, &s) == nil {
+			for _, field := range strings.Split(s, ",") {
+				kv := strings.SplitN(field, ":", 2)
+				if len(kv) != 2 {
+					continue
+				}
+
+				v := strings.TrimSpace(kv[1])
+				switch strings.TrimSpace(kv[0]) {
+				case "name":
+					info.Name = v
+				case "version":
+					info.Version = v
+				case "path":
+					info.Path = v
+				}
+			}
+		} else if err := json.Unmarshal(r, &info); err != nil {
+			apiErr := &APIError{Kind: ErrBadResponse, Endpoint: "/chaincodes", Body: r, Err: err}
+			c.log.Error(apiErr)
+			return nil, apiErr
+		}
+
+		infos = append(infos, info)
+	}
+
+	return infos, nil
+}
+
+// jsonUint decodes the integers of the node sdk, a json number, a decimal
+// string or a Long {"low":..,"high":..}.
+type jsonUint uint64
+
+func (n *jsonUint) UnmarshalJSON(data []byte) error {
+	var s string
+	if json.Unmarshal(data, &s) == nil {
+		v, err := strconv.ParseUint(s, 10, 64)
+		*n = jsonUint(v)
+		return err
+	}
+
+	var long struct {
+		Low  *int64 `json:"low"`
+		High int64  `json:"high"`
+	}
+	if json.Unmarshal(data, &long) == nil && long.Low != nil {
+		*n = jsonUint(uint64(uint32(*long.Low)) | uint64(uint32(long.High))<<32)
+		return nil
+	}
+
+	var v uint64
+	err := json.Unmarshal(data, &v)
+	*n = jsonUint(v)
+	return err
+}
+
+// jsonBytes decodes the byte fields of the node sdk, a base64 string as
+// in the protobuf json mapping, a node Buffer {"type":"Buffer","data":[..]}
+// or a ByteBuffer {"buffer":..,"offset":..,"limit":..}.
+type jsonBytes []byte
+
+func (b *jsonBytes) UnmarshalJSON(data []byte) error {
+	v, err := decodeBytes(data, base64.StdEncoding.DecodeString)
+	*b = v
+	return err
+}
+
+// hexBytes is jsonBytes for the fields that BlockDecoder turns into hex
+// strings.
+type hexBytes []byte
+
+func (b *hexBytes) UnmarshalJSON(data []byte) error {
+	v, err := decodeBytes(data, hex.DecodeString)
+	*b = v
+	return err
+}
+
+// decodeBytes decodes a Buffer, a ByteBuffer, or a string with
+// decodeString.
+func decodeBytes(data []byte, decodeString func(string) ([]byte, error)) ([]byte, error) {
+	if string(data) == "null" {
+		return nil, nil
+	}
+
+	var s string
+	if json.Unmarshal(data, &s) == nil {
+		return decodeString(s)
+	}
+
+	var buf struct {
+		Bytes  []int           `json:"data"`
+		Buffer json.RawMessage `json:"buffer"`
+		Offset *int            `json:"offset"`
+		Limit  *int            `json:"limit"`
+	}
+	err := json.Unmarshal(data, &buf)
+	if err != nil {
+		return nil, err
+	}
+
+	// ByteBuffer, the bytes between offset and limit of buffer
+	if buf.Buffer != nil {
+		inner, err := decodeBytes(buf.Buffer, decodeString)
+		if err != nil {
+			return nil, err
+		}
+
+		lo, hi := 0, len(inner)
+		if buf.Offset != nil && *buf.Offset >= 0 && *buf.Offset <= hi {
+			lo = *buf.Offset
+		}
+		if buf.Limit != nil && *buf.Limit >= lo && *buf.Limit <= hi {
+			hi = *buf.Limit
+		}
+		return inner[lo:hi], nil
+	}
+
+	v := make([]byte, len(buf.Bytes))
+	for i, x := range buf.Bytes {
+		v[i] = byte(x)
+	}
+	return v, nil
+}
@@ -0,0 +1,158 @@
+package fabric
+
+import (
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"testing"
+)
+
+func TestChainInfo(t *testing.T) {
+	for _, reply := range []string{
+		// ByteBuffers and a Long, as the node sdk serializes them
+		`{"height":{"low":5,"high":0,"unsigned":true},
+		  "currentBlockHash":{"buffer":{"type":"Buffer","data":[9,9,1,2,3,9]},"offset":2,"markedOffset":-1,"limit":5,"littleEndian":false},
+		  "previousBlockHash":{"buffer":{"type":"Buffer","data":[4,5]},"offset":0,"limit":2}}`,
+		`{"height":"5","currentBlockHash":"AQID","previousBlockHash":"BAU="}`,
+		`{"height":5,"currentBlockHash":"AQID","previousBlockHash":{"type":"Buffer","data":[4,5]}}`,
+	} {
+		s := newRestServer(replyWith(reply))
+		c := newTestChaincode(t, s)
+
+		info, err := c.ChainInfo(context.Background(), "mychannel", "")
+		if err != nil {
+			t.Fatalf("reply %s: %v", reply, err)
+		}
+		if info.Height != 5 || info.CurrentBlockHash != "010203" || info.PreviousBlockHash != "0405" {
+			t.Errorf("reply %s: ChainInfo() = %+v", reply, info)
+		}
+
+		r := s.last()
+		if r.Path != "/channels/mychannel" || r.Query.Get("peer") != DefaultPeers[0] || r.Auth == "" {
+			t.Errorf("ChainInfo() sent %s %v", r.Path, r.Query)
+		}
+
+		s.Close()
+	}
+}
+
+func TestBytesEncoding(t *testing.T) {
+	// hex digits only, yet base64 where base64 is used
+	var b64 jsonBytes
+	var hx hexBytes
+	if err := json.Unmarshal([]byte(`"deadbeef"`), &b64); err != nil || hex.EncodeToString(b64) != "75e69d6de79f" {
+		t.Errorf("jsonBytes = %x, %v", []byte(b64), err)
+	}
+	if err := json.Unmarshal([]byte(`"deadbeef"`), &hx); err != nil || hex.EncodeToString(hx) != "deadbeef" {
+		t.Errorf("hexBytes = %x, %v", []byte(hx), err)
+	}
+
+	if err := json.Unmarshal([]byte(`"AQID"`), &hx); err == nil {
+		t.Error("hexBytes decoded base64")
+	}
+	if err := json.Unmarshal([]byte(`{"buffer":{"type":"Buffer","data":[1,2,3]},"offset":1,"limit":3}`), &hx); err != nil || hex.EncodeToString(hx) != "0203" {
+		t.Errorf("hexBytes of a ByteBuffer = %x, %v", []byte(hx), err)
+	}
+}
+
+const testBlock = `{
+	"header": {"number": "3", "previous_hash": "aa01", "data_hash": "bb02"},
+	"data": {"data": [{"payload": {}}, {"payload": {}}]},
+	"metadata": {"metadata": []}
+}`
+
+func TestBlock(t *testing.T) {
+	s := newRestServer(replyWith(testBlock))
+	defer s.Close()
+
+	c := newTestChaincode(t, s)
+
+	b, err := c.BlockByNumber(context.Background(), "mychannel", 3, "peer1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b.Number != 3 || b.PreviousHash != "aa01" || b.DataHash != "bb02" || b.TxCount != 2 || len(b.Raw) == 0 {
+		t.Errorf("BlockByNumber() = %+v", b)
+	}
+	if r := s.last(); r.Path != "/channels/mychannel/blocks/3" || r.Query.Get("peer") != "peer1" {
+		t.Errorf("BlockByNumber() sent %s %v", r.Path, r.Query)
+	}
+
+	if _, err := c.BlockByHash(context.Background(), "mychannel", "ab12", ""); err != nil {
+		t.Fatal(err)
+	}
+	if r := s.last(); r.Path != "/channels/mychannel/blocks" || r.Query.Get("hash") != "ab12" {
+		t.Errorf("BlockByHash() sent %s %v", r.Path, r.Query)
+	}
+}
+
+func TestBlockNotFound(t *testing.T) {
+	s := newRestServer(replyWith(`"Error: Entry not found in index"`))
+	defer s.Close()
+
+	_, err := newTestChaincode(t, s).BlockByNumber(context.Background(), "mychannel", 99, "")
+	if !errors.Is(err, ErrNotFound) {
+		t.Errorf("BlockByNumber() err = %v, want ErrNotFound", err)
+	}
+}
+
+func TestTransactionByID(t *testing.T) {
+	s := newRestServer(replyWith(`{
+		"validationCode": 11,
+		"transactionEnvelope": {"payload": {"header": {"channel_header": {"tx_id": "a1b2", "type": 3}}}}
+	}`))
+	defer s.Close()
+
+	c := newTestChaincode(t, s)
+
+	tx, err := c.TransactionByID(context.Background(), "mychannel", "a1b2", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tx.TxID != "a1b2" || tx.ValidationCode != 11 || tx.ValidationStatus != "MVCC_READ_CONFLICT" || tx.Valid() {
+		t.Errorf("TransactionByID() = %+v", tx)
+	}
+	if r := s.last(); r.Path != "/channels/mychannel/transactions/a1b2" {
+		t.Errorf("TransactionByID() sent %s", r.Path)
+	}
+
+	s.reply = replyWith(`{"transactionEnvelope":{}}`)
+	if _, err := c.TransactionByID(context.Background(), "mychannel", "a1b2", ""); !errors.Is(err, ErrBadResponse) {
+		t.Errorf("TransactionByID() err = %v, want ErrBadResponse", err)
+	}
+}
+
+func TestChaincodes(t *testing.T) {
+	s := newRestServer(replyWith(`["name: mycc, version: v0, path: github.com/example_cc/go", {"name":"token","version":"1.1","path":"token"}]`))
+	defer s.Close()
+
+	c := newTestChaincode(t, s)
+
+	infos, err := c.InstalledChaincodes(context.Background(), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []ChaincodeInfo{{"mycc", "v0", "github.com/example_cc/go"}, {"token", "1.1", "token"}}
+	if len(infos) != len(want) || infos[0] != want[0] || infos[1] != want[1] {
+		t.Errorf("InstalledChaincodes() = %+v", infos)
+	}
+	if r := s.last(); r.Path != "/chaincodes" || r.Query.Get("type") != "installed" {
+		t.Errorf("InstalledChaincodes() sent %s %v", r.Path, r.Query)
+	}
+
+	if _, err := c.InstantiatedChaincodes(context.Background(), "mychannel", ""); err != nil {
+		t.Fatal(err)
+	}
+	if r := s.last(); r.Query.Get("type") != "instantiated" || r.Query.Get("channel") != "mychannel" {
+		t.Errorf("InstantiatedChaincodes() sent %v", r.Query)
+	}
+}
+
+func TestValidationCodeName(t *testing.T) {
+	for code, want := range map[int]string{0: "VALID", 10: "ENDORSEMENT_POLICY_FAILURE", 254: "NOT_VALIDATED", 255: "INVALID_OTHER_REASON"} {
+		if got := ValidationCodeName(code); got != want {
+			t.Errorf("ValidationCodeName(%d) = %s, want %s", code, got, want)
+		}
+	}
+}
@@ -0,0 +1,176 @@
+package fabric
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+// redirect sends every request to the test server, whatever its host.
+type redirect struct {
+	target *url.URL
+}
+
+func (rt redirect) RoundTrip(req *http.Request) (*http.Response, error) {
+	req.URL.Scheme = rt.target.Scheme
+	req.URL.Host = rt.target.Host
+	return http.DefaultTransport.RoundTrip(req)
+}
+
+type chaincodeCall struct {
+	Method string
+	Path   string
+	Query  url.Values
+	Auth   string
+	Body   map[string]interface{}
+}
+
+func newChaincodeServer(t *testing.T, reply string) (*FabricClient, *[]chaincodeCall, func()) {
+	calls := &[]chaincodeCall{}
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/users" {
+			writeLogin(w, time.Now().Add(time.Hour))
+			return
+		}
+
+		c := chaincodeCall{
+			Method: r.Method,
+			Path:   r.URL.Path,
+			Query:  r.URL.Query(),
+			Auth:   r.Header.Get("Authorization"),
+		}
+		if r.Method == "POST" {
+			json.NewDecoder(r.Body).Decode(&c.Body)
+		}
+		*calls = append(*calls, c)
+
+		w.Write([]byte(reply))
+	}))
+
+	u, _ := url.Parse(ts.URL)
+	f := newTestClient(t, ts.URL, WithTransport(redirect{u}), WithCredentials(Credentials{"Jim", "Org1"}))
+
+	return f, calls, ts.Close
+}
+
+func TestInitValueAndMove(t *testing.T) {
+	f, calls, done := newChaincodeServer(t, "ok")
+	defer done()
+
+	err := f.initValue(context.Background(), "a", "100")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = f.move(context.Background(), "a", "b", "10")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(*calls) != 2 {
+		t.Fatalf("%d calls, want 2", len(*calls))
+	}
+
+	for i, want := range []struct {
+		fcn  string
+		args []interface{}
+	}{
+		{"initValue", []interface{}{"a", "100"}},
+		{"move", []interface{}{"a", "b", "10"}},
+	} {
+		c := (*calls)[i]
+		if c.Method != "POST" || c.Path != "/channels/mychannel/chaincodes/mycc" {
+			t.Errorf("call %d: %s %s", i, c.Method, c.Path)
+		}
+		if !strings.HasPrefix(c.Auth, "Bearer ey") {
+			t.Errorf("call %d: Authorization = %q", i, c.Auth)
+		}
+		if c.Body["fcn"] != want.fcn {
+			t.Errorf("call %d: fcn = %v, want %s", i, c.Body["fcn"], want.fcn)
+		}
+
+		args, _ := c.Body["args"].([]interface{})
+		if len(args) != len(want.args) {
+			t.Fatalf("call %d: args = %v, want %v", i, args, want.args)
+		}
+		for j := range args {
+			if args[j] != want.args[j] {
+				t.Errorf("call %d: args = %v, want %v", i, args, want.args)
+			}
+		}
+	}
+}
+
+func TestQuery(t *testing.T) {
+	f, calls, done := newChaincodeServer(t, "90")
+	defer done()
+
+	b, err := f.query(context.Background(), "a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b != "90" {
+		t.Errorf("query() = %s, want 90", b)
+	}
+
+	c := (*calls)[0]
+	if c.Method != "GET" || c.Query.Get("fcn") != "query" || c.Query.Get("peer") != "peer0.org1.example.com" {
+		t.Errorf("query() sent %s %v", c.Method, c.Query)
+	}
+	if c.Query.Get("args") != `["a"]` {
+		t.Errorf("query() args = %s", c.Query.Get("args"))
+	}
+}
+
+func TestQuerySentence(t *testing.T) {
+	f, _, done := newChaincodeServer(t, "a now has 90 after the move")
+	defer done()
+
+	b, err := f.query(context.Background(), "a")
+	if err != nil || b != "90" {
+		t.Errorf("query() = %s, %v, want 90", b, err)
+	}
+}
+
+func TestQueryEmpty(t *testing.T) {
+	f, _, done := newChaincodeServer(t, "")
+	defer done()
+
+	b, err := f.query(context.Background(), "a")
+	if err == nil {
+		t.Error("query() with an empty response succeeded")
+	}
+	if b != "0" {
+		t.Errorf("query() = %s, want 0", b)
+	}
+}
+
+func TestChaincodeContextCanceled(t *testing.T) {
+	f, calls, done := newChaincodeServer(t, "ok")
+	defer done()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if err := f.move(ctx, "a", "b", "1"); err == nil {
+		t.Error("move() with a canceled context succeeded")
+	}
+	if len(*calls) != 0 {
+		t.Error("canceled request reached the server")
+	}
+}
+
+func TestChaincodeNoCredentials(t *testing.T) {
+	f := newTestClient(t, "http://127.0.0.1:1")
+
+	err := f.move(context.Background(), "a", "b", "1")
+	if err != ErrNoCredentials {
+		t.Errorf("move() err = %v, want ErrNoCredentials", err)
+	}
+}
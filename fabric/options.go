@@ -0,0 +1,169 @@
+package fabric
+
+import (
+	"fabricclient/logger"
+	"fabricclient/util"
+	"net/http"
+	"time"
+)
+
+// Logger is the logging interface used by FabricClient. Every
+// logger.Handler satisfies it.
+type Logger interface {
+	Debug(v ...interface{})
+	Info(v ...interface{})
+	Error(v ...interface{})
+}
+
+// Signer signs request origins on behalf of a WIF private key.
+type Signer interface {
+	Sign(privKey string, data []byte) (string, error)
+	PubKey(privKey string) (string, error)
+}
+
+// Option configures a FabricClient built by NewFabricClient.
+type Option func(*FabricClient)
+
+// WithBaseURL sets the full gateway url, e.g. "http://127.0.0.1:4000".
+// It takes precedence over the ipport and scheme.
+func WithBaseURL(url string) Option {
+	return WithEndpoints(url)
+}
+
+// WithEndpoints sets the full urls of several gateway replicas. It takes
+// precedence over the ipport and scheme.
+func WithEndpoints(urls ...string) Option {
+	return func(f *FabricClient) {
+		f.baseURLs = urls
+	}
+}
+
+// WithBalancer sets how a gateway is selected, RoundRobin by default.
+func WithBalancer(b Balancer) Option {
+	return func(f *FabricClient) {
+		f.balancer = b
+	}
+}
+
+// WithHealthCheck sets the passive health tracking of the gateways.
+func WithHealthCheck(h HealthCheck) Option {
+	return func(f *FabricClient) {
+		f.health = h
+	}
+}
+
+// WithScheme sets the url scheme used with the ipport, "http" by default.
+func WithScheme(scheme string) Option {
+	return func(f *FabricClient) {
+		f.scheme = scheme
+	}
+}
+
+// WithHTTPClient sets the http.Client used for every request.
+func WithHTTPClient(cli *http.Client) Option {
+	return func(f *FabricClient) {
+		f.cli = cli
+	}
+}
+
+// WithTransport sets the transport of the http.Client.
+func WithTransport(rt http.RoundTripper) Option {
+	return func(f *FabricClient) {
+		f.transport = rt
+	}
+}
+
+// WithTimeout sets the overall timeout of a single request.
+func WithTimeout(d time.Duration) Option {
+	return func(f *FabricClient) {
+		f.timeout = d
+	}
+}
+
+// WithLogger sets the logger, the global logger package by default.
+func WithLogger(l Logger) Option {
+	return func(f *FabricClient) {
+		f.log = l
+	}
+}
+
+// WithSigner sets the signer, one of the wif keys of the network by
+// default.
+func WithSigner(s Signer) Option {
+	return func(f *FabricClient) {
+		f.signer = s
+	}
+}
+
+// WithNetwork sets the network of the keys and addresses, util.MainNet by
+// default.
+func WithNetwork(n *util.Network) Option {
+	return func(f *FabricClient) {
+		f.network = n
+	}
+}
+
+// WithWaitBackoff sets the polling interval of WaitForTx.
+func WithWaitBackoff(b Backoff) Option {
+	return func(f *FabricClient) {
+		f.waitBackoff = b
+	}
+}
+
+// WithRetryPolicy enables retries, calls are never retried by default.
+func WithRetryPolicy(p RetryPolicy) Option {
+	return func(f *FabricClient) {
+		f.retry = p
+	}
+}
+
+// WithIdempotentTransfers declares that the gateways apply a transfer
+// once per nonce of its origin. A transfer then carries a nonce and is
+// retried on any retryable error, not only when it cannot have been sent.
+func WithIdempotentTransfers() Option {
+	return func(f *FabricClient) {
+		f.idempotent = true
+	}
+}
+
+// WithRestURL sets the url of the fabric rest server used by the chaincode
+// calls, DefaultRestURL by default.
+func WithRestURL(url string) Option {
+	return func(f *FabricClient) {
+		f.restURL = url
+	}
+}
+
+// WithPeers sets the default endorsing peers of the chaincode calls.
+func WithPeers(peers ...string) Option {
+	return func(f *FabricClient) {
+		f.peers = peers
+	}
+}
+
+// WithCredentials sets the user logging in to the fabric rest server for
+// the chaincode calls.
+func WithCredentials(cred Credentials) Option {
+	return func(f *FabricClient) {
+		f.cred = &cred
+	}
+}
+
+type stdLogger struct{}
+
+func (stdLogger) Debug(v ...interface{}) { logger.Debug(v...) }
+func (stdLogger) Info(v ...interface{})  { logger.Info(v...) }
+func (stdLogger) Error(v ...interface{}) { logger.Error(v...) }
+
+// wifSigner signs with the wif keys of net.
+type wifSigner struct {
+	net *util.Network
+}
+
+func (s wifSigner) Sign(privKey string, data []byte) (string, error) {
+	return s.net.Sign(privKey, data)
+}
+
+func (s wifSigner) PubKey(privKey string) (string, error) {
+	return s.net.PubKey(privKey)
+}
@@ -0,0 +1,197 @@
+package fabric
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Balancer selects the gateway endpoint of each request.
+type Balancer int
+
+const (
+	RoundRobin Balancer = iota
+	LeastLatency
+)
+
+// HealthCheck controls the passive health tracking of the endpoints. An
+// endpoint is ejected after MaxFails consecutive failures. Once EjectFor
+// has elapsed it is probed with a GET of ProbePath, and brought back only
+// if the probe gets a response below 500.
+type HealthCheck struct {
+	MaxFails  int
+	EjectFor  time.Duration
+	ProbePath string
+}
+
+var DefaultHealthCheck = HealthCheck{
+	MaxFails:  3,
+	EjectFor:  10 * time.Second,
+	ProbePath: "/ocean/v1/queryBalance/probe",
+}
+
+type node struct {
+	url string
+
+	fails        int
+	ejected      bool
+	ejectedUntil time.Time
+	probing      bool
+	// latency is a moving average of successful requests
+	latency time.Duration
+}
+
+type pool struct {
+	mu       sync.Mutex
+	nodes    []*node
+	balancer Balancer
+	health   HealthCheck
+	next     int
+	probe    func(ep *node) bool
+}
+
+func newPool(urls []string, balancer Balancer, health HealthCheck) *pool {
+	p := &pool{
+		balancer: balancer,
+		health:   health,
+	}
+
+	for _, u := range urls {
+		p.nodes = append(p.nodes, &node{url: u})
+	}
+
+	return p
+}
+
+// pick returns the endpoint for the next request, skipping the ones in
+// tried. It returns nil once every endpoint was tried. If every remaining
+// endpoint is ejected, the least recently ejected one is used anyway.
+func (p *pool) pick(tried map[*node]bool) *node {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	now := time.Now()
+
+	var best, fallback *node
+	n := len(p.nodes)
+
+	for i := 0; i < n; i++ {
+		ep := p.nodes[(p.next+i)%n]
+		if tried[ep] {
+			continue
+		}
+
+		if ep.ejected {
+			if !ep.probing && now.After(ep.ejectedUntil) && p.probe != nil {
+				ep.probing = true
+				go p.runProbe(ep)
+			}
+			if fallback == nil || ep.ejectedUntil.Before(fallback.ejectedUntil) {
+				fallback = ep
+			}
+			continue
+		}
+
+		if best == nil {
+			best = ep
+			if p.balancer == RoundRobin {
+				break
+			}
+		} else if ep.latency < best.latency {
+			best = ep
+		}
+	}
+
+	if best == nil {
+		best = fallback
+	}
+
+	if p.balancer == RoundRobin {
+		p.next = (p.next + 1) % n
+	}
+
+	return best
+}
+
+func (p *pool) runProbe(ep *node) {
+	ok := p.probe(ep)
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	ep.probing = false
+	if ok {
+		ep.ejected = false
+		ep.fails = 0
+	} else {
+		ep.ejectedUntil = time.Now().Add(p.health.EjectFor)
+	}
+}
+
+// report records the outcome of a request sent to ep. A success on an
+// ejected endpoint, picked as the fallback, does not bring it back, only
+// its probe does.
+func (p *pool) report(ep *node, latency time.Duration, err error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if err != nil && IsRetryable(err) {
+		ep.fails++
+		if p.health.MaxFails > 0 && ep.fails >= p.health.MaxFails && !ep.ejected {
+			ep.ejected = true
+			ep.ejectedUntil = time.Now().Add(p.health.EjectFor)
+		}
+		return
+	}
+
+	if !ep.ejected {
+		ep.fails = 0
+	}
+	if ep.latency == 0 {
+		ep.latency = latency
+	} else {
+		ep.latency = (ep.latency*7 + latency*3) / 10
+	}
+}
+
+// probe sends a GET of the probe path to ep with a short timeout.
+func (f *FabricClient) probe(ep *node) bool {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, "GET", ep.url+f.pool.health.ProbePath, nil)
+	if err != nil {
+		return false
+	}
+
+	resp, err := f.cli.Do(req)
+	if err != nil {
+		f.log.Info("probe failed", ep.url, err)
+		return false
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode >= 500 {
+		f.log.Info("probe failed", ep.url, resp.Status)
+		return false
+	}
+
+	f.log.Info("endpoint is back", ep.url)
+
+	return true
+}
+
+// notSent reports whether err proves the request never reached the server,
+// so that even a write may be sent to another endpoint.
+func notSent(err error) bool {
+	var opErr *net.OpError
+	if errors.As(err, &opErr) && opErr.Op == "dial" {
+		return true
+	}
+
+	var dnsErr *net.DNSError
+	return errors.As(err, &dnsErr)
+}
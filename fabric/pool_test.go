@@ -0,0 +1,216 @@
+package fabric
+
+import (
+	"context"
+	"errors"
+	"fabricclient/fabric/fabrictest"
+	"net/http"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// counting wraps a fabrictest.Server and counts the requests it handles.
+type counting struct {
+	*fabrictest.Server
+	n int32
+}
+
+func newCounting() *counting {
+	c := &counting{Server: fabrictest.NewUnstartedServer()}
+	h := c.Server.Config.Handler
+	c.Server.Config.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&c.n, 1)
+		h.ServeHTTP(w, r)
+	})
+	c.Server.Start()
+	return c
+}
+
+func (c *counting) count() int {
+	return int(atomic.LoadInt32(&c.n))
+}
+
+func deadURL() string {
+	s := fabrictest.NewServer()
+	s.Close()
+	return s.URL
+}
+
+func TestRoundRobin(t *testing.T) {
+	a, b := newCounting(), newCounting()
+	defer a.Close()
+	defer b.Close()
+
+	f := newTestClient(t, "", WithEndpoints(a.URL, b.URL))
+
+	for i := 0; i < 10; i++ {
+		if _, err := f.QueryBalance("addr"); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if a.count() != 5 || b.count() != 5 {
+		t.Errorf("requests = %d, %d, want 5, 5", a.count(), b.count())
+	}
+}
+
+func TestLeastLatency(t *testing.T) {
+	slow, fast := newCounting(), newCounting()
+	defer slow.Close()
+	defer fast.Close()
+	slow.SetLatency(50 * time.Millisecond)
+
+	f := newTestClient(t, "", WithEndpoints(slow.URL, fast.URL), WithBalancer(LeastLatency))
+
+	for i := 0; i < 10; i++ {
+		if _, err := f.QueryBalance("addr"); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if slow.count() != 1 || fast.count() != 9 {
+		t.Errorf("requests = %d slow, %d fast, want 1, 9", slow.count(), fast.count())
+	}
+}
+
+func TestQueryFailover(t *testing.T) {
+	s := newCounting()
+	defer s.Close()
+
+	f := newTestClient(t, "", WithEndpoints(deadURL(), s.URL))
+
+	for i := 0; i < 4; i++ {
+		if _, err := f.QueryBalance("addr"); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if s.count() != 4 {
+		t.Errorf("requests = %d, want 4", s.count())
+	}
+}
+
+func TestWriteFailover(t *testing.T) {
+	s := fabrictest.NewServer()
+	defer s.Close()
+
+	f := newTestClient(t, "", WithEndpoints(deadURL(), s.URL))
+
+	// a refused connection proves the transfer was never sent
+	w, tokenID := issue(t, f, "10")
+	_, err := f.Transfer(tokenID, w.Address, w.PrivKey, newWallet().Address, "1")
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestNoWriteFailoverAfterSend(t *testing.T) {
+	a, b := newCounting(), newCounting()
+	defer a.Close()
+	defer b.Close()
+	a.Inject(fabrictest.Transfer, fabrictest.Fault{StatusCode: http.StatusBadGateway})
+
+	f := newTestClient(t, "", WithEndpoints(a.URL, b.URL))
+
+	w := newWallet()
+	_, err := f.Transfer("token", w.Address, w.PrivKey, newWallet().Address, "1")
+	if !errors.Is(err, ErrHTTPStatus) {
+		t.Errorf("Transfer() err = %v, want ErrHTTPStatus", err)
+	}
+	if b.count() != 0 {
+		t.Error("transfer was sent to a second gateway")
+	}
+}
+
+func TestEjectAndProbe(t *testing.T) {
+	a, b := newCounting(), newCounting()
+	defer a.Close()
+	defer b.Close()
+
+	f := newTestClient(t, "", WithEndpoints(a.URL, b.URL),
+		WithHealthCheck(HealthCheck{MaxFails: 2, EjectFor: 100 * time.Millisecond, ProbePath: "/ocean/v1/queryBalance/probe"}))
+
+	a.Inject(fabrictest.QueryBalance, fabrictest.Fault{StatusCode: http.StatusServiceUnavailable})
+
+	for i := 0; i < 10; i++ {
+		if _, err := f.QueryBalance("addr"); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// two failures eject a, then every query goes to b
+	if a.count() != 2 {
+		t.Errorf("requests to a = %d, want 2", a.count())
+	}
+
+	a.ClearFaults()
+	time.Sleep(150 * time.Millisecond)
+
+	// the first pick after EjectFor starts the probe
+	f.QueryBalance("addr")
+
+	deadline := time.Now().Add(time.Second)
+	for time.Now().Before(deadline) {
+		f.pool.mu.Lock()
+		ejected := f.pool.nodes[0].ejected
+		f.pool.mu.Unlock()
+		if !ejected {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	t.Error("a was not brought back after a successful probe")
+}
+
+func TestProbeStatus(t *testing.T) {
+	s := fabrictest.NewServer()
+	defer s.Close()
+	f := newTestClient(t, s.URL)
+	ep := f.pool.nodes[0]
+
+	if !f.probe(ep) {
+		t.Error("probe of a healthy gateway failed")
+	}
+
+	s.Inject(fabrictest.QueryBalance, fabrictest.Fault{StatusCode: http.StatusNotFound, Times: 1})
+	if !f.probe(ep) {
+		t.Error("probe answered 404 failed")
+	}
+
+	s.Inject(fabrictest.QueryBalance, fabrictest.Fault{StatusCode: http.StatusServiceUnavailable, Times: 1})
+	if f.probe(ep) {
+		t.Error("probe answered 503 succeeded")
+	}
+}
+
+func TestReportKeepsEjection(t *testing.T) {
+	p := newPool([]string{"http://a"}, RoundRobin, HealthCheck{MaxFails: 1, EjectFor: time.Hour})
+	ep := p.nodes[0]
+
+	p.report(ep, time.Millisecond, &APIError{Kind: ErrTransport})
+	p.report(ep, time.Millisecond, nil)
+	if !ep.ejected || ep.fails != 1 {
+		t.Errorf("after a success ejected = %v, fails = %d, want ejected until the probe passes", ep.ejected, ep.fails)
+	}
+}
+
+func TestCallerDeadlineNotReported(t *testing.T) {
+	s := fabrictest.NewServer()
+	defer s.Close()
+	s.SetLatency(100 * time.Millisecond)
+
+	f := newTestClient(t, s.URL, WithHealthCheck(HealthCheck{MaxFails: 1, EjectFor: time.Hour}))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, err := f.QueryBalanceContext(ctx, "addr"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("QueryBalanceContext() err = %v, want DeadlineExceeded", err)
+	}
+
+	ep := f.pool.nodes[0]
+	if ep.ejected || ep.fails != 0 {
+		t.Errorf("ejected = %v, fails = %d after the caller's deadline", ep.ejected, ep.fails)
+	}
+}
@@ -0,0 +1,95 @@
+package fabric
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"net/http"
+	"time"
+)
+
+// RetryPolicy controls how failed calls are retried. Queries are retried
+// on any retryable error. A transfer is resent only if it never reached a
+// gateway or was refused with 429, since the gateway is not known to
+// detect a resubmitted transfer. WithIdempotentTransfers retries it on
+// any retryable error, for gateways that deduplicate on the nonce of the
+// origin. IssueToken is never retried.
+type RetryPolicy struct {
+	// MaxAttempts is the number of attempts including the first one.
+	MaxAttempts int
+	Backoff     Backoff
+	// Jitter randomizes each delay by up to this fraction, 0 to 1.
+	Jitter float64
+	// Retryable classifies errors, IsRetryable if nil.
+	Retryable func(error) bool
+}
+
+// DefaultRetryPolicy is a reasonable policy for callers opting in to
+// retries with WithRetryPolicy.
+var DefaultRetryPolicy = RetryPolicy{
+	MaxAttempts: 3,
+	Backoff: Backoff{
+		Initial:    200 * time.Millisecond,
+		Max:        5 * time.Second,
+		Multiplier: 2,
+	},
+	Jitter: 0.2,
+}
+
+// noRetry is the policy of a client built without WithRetryPolicy.
+var noRetry = RetryPolicy{MaxAttempts: 1}
+
+func (p RetryPolicy) enabled() bool {
+	return p.MaxAttempts > 1
+}
+
+// shouldRetry reports whether a failed attempt n, starting at 0, may be
+// followed by another one.
+func (p RetryPolicy) shouldRetry(n int, err error) bool {
+	if n+1 >= p.MaxAttempts {
+		return false
+	}
+
+	if p.Retryable != nil {
+		return p.Retryable(err)
+	}
+
+	return IsRetryable(err)
+}
+
+// wait sleeps before attempt n+1, or returns early with the error of ctx.
+func (p RetryPolicy) wait(ctx context.Context, n int) error {
+	d := p.Backoff.delay(n)
+	if p.Jitter > 0 {
+		d += time.Duration((rand.Float64()*2 - 1) * p.Jitter * float64(d))
+	}
+
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// resendable reports whether a failed write cannot have been applied: it
+// never reached a gateway, or the gateway refused it with 429. A 503 may
+// come from a proxy after forwarding the write, it is not resendable.
+func resendable(err error) bool {
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) {
+		return false
+	}
+
+	switch apiErr.Kind {
+	case ErrTransport:
+		return notSent(apiErr.Err)
+	case ErrHTTPStatus:
+		return apiErr.StatusCode == http.StatusTooManyRequests
+	}
+
+	return false
+}
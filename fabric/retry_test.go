@@ -0,0 +1,181 @@
+package fabric
+
+import (
+	"errors"
+	"fabricclient/fabric/fabrictest"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+var testRetry = RetryPolicy{
+	MaxAttempts: 3,
+	Backoff:     testBackoff,
+	Jitter:      0.5,
+}
+
+func TestRetryQuery(t *testing.T) {
+	s := fabrictest.NewServer()
+	defer s.Close()
+	f := newTestClient(t, s.URL, WithRetryPolicy(testRetry))
+
+	s.Inject(fabrictest.QueryBalance, fabrictest.Fault{StatusCode: http.StatusServiceUnavailable, Times: 2})
+
+	_, err := f.QueryBalance("addr")
+	if err != nil {
+		t.Errorf("QueryBalance() err = %v", err)
+	}
+
+	s.Inject(fabrictest.QueryBalance, fabrictest.Fault{StatusCode: http.StatusServiceUnavailable, Times: 3})
+
+	_, err = f.QueryBalance("addr")
+	if !errors.Is(err, ErrHTTPStatus) {
+		t.Errorf("QueryBalance() err = %v, want ErrHTTPStatus", err)
+	}
+}
+
+func TestRetryTransferIsIdempotent(t *testing.T) {
+	s := fabrictest.NewServer()
+	defer s.Close()
+	s.DedupeNonce = true
+	f := newTestClient(t, s.URL, WithRetryPolicy(testRetry), WithIdempotentTransfers())
+
+	from, tokenID := issue(t, f, "10")
+	to := newWallet()
+
+	// the first transfer is applied but its response is lost
+	s.Inject(fabrictest.Transfer, fabrictest.Fault{Drop: true, AfterApply: true, Times: 1})
+
+	txID, err := f.Transfer(tokenID, from.Address, from.PrivKey, to.Address, "3")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if txID == "" {
+		t.Error("Transfer() returned an empty txID")
+	}
+
+	if got := s.Balance(to.Address, tokenID); got != "3" {
+		t.Errorf("to balance = %s, want 3", got)
+	}
+	if s.TxCount() != 1 {
+		t.Errorf("%d transfers applied, want 1", s.TxCount())
+	}
+}
+
+func TestRetryTransferDuplicateWithoutTxID(t *testing.T) {
+	calls := 0
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if calls == 1 {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		w.Write([]byte(`{"status":false,"message":"duplicate nonce"}`))
+	}))
+	defer s.Close()
+	f := newTestClient(t, s.URL, WithRetryPolicy(testRetry), WithIdempotentTransfers())
+
+	from := newWallet()
+	txID, err := f.Transfer("token", from.Address, from.PrivKey, newWallet().Address, "1")
+	if err != ErrAppliedUnknownTx || txID != "" || calls != 2 {
+		t.Errorf("Transfer() = %q, %v after %d calls, want ErrAppliedUnknownTx", txID, err, calls)
+	}
+}
+
+func TestRetryTransferRejectedAfterResend(t *testing.T) {
+	calls := 0
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if calls == 1 {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		w.Write([]byte(`{"status":false,"message":"utxo already spent","txID":"abc"}`))
+	}))
+	defer s.Close()
+	f := newTestClient(t, s.URL, WithRetryPolicy(testRetry), WithIdempotentTransfers())
+
+	// only the duplicate nonce reply means the first attempt was applied
+	from := newWallet()
+	txID, err := f.Transfer("token", from.Address, from.PrivKey, newWallet().Address, "1")
+	if !errors.Is(err, ErrRejected) || txID != "" || calls != 2 {
+		t.Errorf("Transfer() = %q, %v after %d calls, want ErrRejected", txID, err, calls)
+	}
+}
+
+func TestRetryTransferNotResent(t *testing.T) {
+	s := fabrictest.NewServer()
+	defer s.Close()
+	f := newTestClient(t, s.URL, WithRetryPolicy(testRetry))
+
+	from, tokenID := issue(t, f, "10")
+	to := newWallet()
+
+	// a lost response may hide an applied transfer, it is not sent again
+	s.Inject(fabrictest.Transfer, fabrictest.Fault{Drop: true, AfterApply: true, Times: 1})
+	if _, err := f.Transfer(tokenID, from.Address, from.PrivKey, to.Address, "3"); !errors.Is(err, ErrTransport) {
+		t.Errorf("Transfer() err = %v, want ErrTransport", err)
+	}
+
+	s.Inject(fabrictest.Transfer, fabrictest.Fault{StatusCode: http.StatusInternalServerError, Times: 1})
+	if _, err := f.Transfer(tokenID, from.Address, from.PrivKey, to.Address, "3"); !errors.Is(err, ErrHTTPStatus) {
+		t.Errorf("Transfer() err = %v, want ErrHTTPStatus", err)
+	}
+	if s.TxCount() != 1 {
+		t.Errorf("%d transfers applied, want 1", s.TxCount())
+	}
+
+	// a proxy may answer 503 after forwarding the transfer
+	s.Inject(fabrictest.Transfer, fabrictest.Fault{StatusCode: http.StatusServiceUnavailable, AfterApply: true, Times: 1})
+	if _, err := f.Transfer(tokenID, from.Address, from.PrivKey, to.Address, "3"); !errors.Is(err, ErrHTTPStatus) {
+		t.Errorf("Transfer() err = %v, want ErrHTTPStatus", err)
+	}
+	if s.TxCount() != 2 {
+		t.Errorf("%d transfers applied, want 2", s.TxCount())
+	}
+
+	// a 429 was refused before being applied
+	s.Inject(fabrictest.Transfer, fabrictest.Fault{StatusCode: http.StatusTooManyRequests, Times: 2})
+	if _, err := f.Transfer(tokenID, from.Address, from.PrivKey, to.Address, "3"); err != nil {
+		t.Fatal(err)
+	}
+	if got := s.Balance(to.Address, tokenID); got != "9" {
+		t.Errorf("to balance = %s, want 9", got)
+	}
+}
+
+func TestNoRetryByDefault(t *testing.T) {
+	s := fabrictest.NewServer()
+	defer s.Close()
+	f := newTestClient(t, s.URL)
+
+	s.Inject(fabrictest.QueryBalance, fabrictest.Fault{StatusCode: http.StatusServiceUnavailable, Times: 1})
+
+	_, err := f.QueryBalance("addr")
+	if !errors.Is(err, ErrHTTPStatus) {
+		t.Errorf("QueryBalance() err = %v, want ErrHTTPStatus", err)
+	}
+}
+
+func TestRetryNotRetryable(t *testing.T) {
+	s := fabrictest.NewServer()
+	defer s.Close()
+
+	calls := 0
+	p := testRetry
+	p.Retryable = func(err error) bool {
+		calls++
+		return false
+	}
+	f := newTestClient(t, s.URL, WithRetryPolicy(p))
+
+	start := time.Now()
+	_, err := f.QueryTx("unknown")
+	if !errors.Is(err, ErrNotFound) || calls != 1 {
+		t.Errorf("QueryTx() err = %v after %d calls", err, calls)
+	}
+	if time.Since(start) > time.Second {
+		t.Error("QueryTx() waited before failing")
+	}
+}
@@ -0,0 +1,95 @@
+package fabric
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"io/ioutil"
+	"net/http"
+)
+
+// TLSConfig describes the tls connection to the gateways, every field is
+// optional.
+type TLSConfig struct {
+	// CAFile is a pem bundle trusted instead of the system roots.
+	CAFile string
+	// CertFile and KeyFile are the client certificate for mutual tls.
+	CertFile string
+	KeyFile  string
+	// ServerName overrides the name checked against the server certificate.
+	ServerName string
+	// MinVersion is one of "1.0", "1.1", "1.2" and "1.3", "1.2" if empty.
+	MinVersion string
+}
+
+var tlsVersions = map[string]uint16{
+	"1.0": tls.VersionTLS10,
+	"1.1": tls.VersionTLS11,
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+// Build loads the files of c into a tls.Config.
+func (c *TLSConfig) Build() (*tls.Config, error) {
+	conf := &tls.Config{
+		ServerName: c.ServerName,
+		MinVersion: tls.VersionTLS12,
+	}
+
+	if c.MinVersion != "" {
+		v, ok := tlsVersions[c.MinVersion]
+		if !ok {
+			return nil, errors.New("unknown tls version " + c.MinVersion)
+		}
+		conf.MinVersion = v
+	}
+
+	if c.CAFile != "" {
+		pem, err := ioutil.ReadFile(c.CAFile)
+		if err != nil {
+			return nil, err
+		}
+
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, errors.New("no certificate found in " + c.CAFile)
+		}
+		conf.RootCAs = pool
+	}
+
+	if c.CertFile != "" || c.KeyFile != "" {
+		cert, err := tls.LoadX509KeyPair(c.CertFile, c.KeyFile)
+		if err != nil {
+			return nil, err
+		}
+		conf.Certificates = []tls.Certificate{cert}
+	}
+
+	return conf, nil
+}
+
+// WithTLS connects to the gateways over https with conf.
+func WithTLS(conf *tls.Config) Option {
+	return func(f *FabricClient) {
+		f.scheme = "https"
+		f.tlsConfig = conf
+	}
+}
+
+// tlsTransport returns a copy of rt using conf, rt must be nil or an
+// *http.Transport.
+func tlsTransport(rt http.RoundTripper, conf *tls.Config) (http.RoundTripper, error) {
+	if rt == nil {
+		rt = http.DefaultTransport
+	}
+
+	t, ok := rt.(*http.Transport)
+	if !ok {
+		return nil, errors.New("WithTLS needs an *http.Transport")
+	}
+
+	t = t.Clone()
+	t.TLSClientConfig = conf
+
+	return t, nil
+}
@@ -0,0 +1,193 @@
+package fabric
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"fabricclient/fabric/fabrictest"
+	"io/ioutil"
+	"math/big"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+type testCert struct {
+	cert    *x509.Certificate
+	key     *ecdsa.PrivateKey
+	certPem []byte
+	keyPem  []byte
+}
+
+// newCert issues a certificate for dnsName signed by parent, or a self
+// signed ca if parent is nil.
+func newCert(t *testing.T, dnsName string, parent *testCert) *testCert {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: dnsName},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+	}
+
+	signer, signerKey := tmpl, key
+	if parent == nil {
+		tmpl.IsCA = true
+		tmpl.BasicConstraintsValid = true
+	} else {
+		tmpl.DNSNames = []string{dnsName}
+		signer, signerKey = parent.cert, parent.key
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, signer, &key.PublicKey, signerKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, _ := x509.ParseCertificate(der)
+
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return &testCert{
+		cert:    cert,
+		key:     key,
+		certPem: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		keyPem:  pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer}),
+	}
+}
+
+func writeFile(t *testing.T, dir, name string, data []byte) string {
+	t.Helper()
+
+	p := filepath.Join(dir, name)
+	if err := ioutil.WriteFile(p, data, 0600); err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
+
+func TestTLS(t *testing.T) {
+	s := fabrictest.NewUnstartedServer()
+	s.StartTLS()
+	defer s.Close()
+
+	dir := t.TempDir()
+	caFile := writeFile(t, dir, "ca.pem", pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: s.Certificate().Raw}))
+
+	conf, err := (&TLSConfig{CAFile: caFile, MinVersion: "1.2"}).Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	f := newTestClient(t, s.URL, WithTLS(conf))
+	if _, err := f.QueryBalance("addr"); err != nil {
+		t.Fatal(err)
+	}
+
+	// the system roots do not trust the test server
+	_, err = newTestClient(t, s.URL).QueryBalance("addr")
+	if !errors.Is(err, ErrTransport) {
+		t.Errorf("QueryBalance() err = %v, want ErrTransport", err)
+	}
+}
+
+func TestMutualTLS(t *testing.T) {
+	ca := newCert(t, "test ca", nil)
+	server := newCert(t, "gateway.test", ca)
+	client := newCert(t, "client", ca)
+
+	roots := x509.NewCertPool()
+	roots.AddCert(ca.cert)
+
+	serverCert, err := tls.X509KeyPair(server.certPem, server.keyPem)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s := fabrictest.NewUnstartedServer()
+	s.TLS = &tls.Config{
+		Certificates: []tls.Certificate{serverCert},
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+		ClientCAs:    roots,
+	}
+	s.StartTLS()
+	defer s.Close()
+
+	dir := t.TempDir()
+	c := &TLSConfig{
+		CAFile:     writeFile(t, dir, "ca.pem", ca.certPem),
+		CertFile:   writeFile(t, dir, "client.pem", client.certPem),
+		KeyFile:    writeFile(t, dir, "client.key", client.keyPem),
+		ServerName: "gateway.test",
+	}
+
+	conf, err := c.Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	f := newTestClient(t, s.URL, WithTLS(conf))
+	if _, err := f.QueryBalance("addr"); err != nil {
+		t.Fatal(err)
+	}
+
+	// without a client certificate the handshake fails
+	c.CertFile, c.KeyFile = "", ""
+	conf, err = c.Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = newTestClient(t, s.URL, WithTLS(conf)).QueryBalance("addr")
+	if !errors.Is(err, ErrTransport) {
+		t.Errorf("QueryBalance() err = %v, want ErrTransport", err)
+	}
+}
+
+func TestTLSConfigBuild(t *testing.T) {
+	dir := t.TempDir()
+
+	for _, c := range []*TLSConfig{
+		{MinVersion: "2.0"},
+		{CAFile: filepath.Join(dir, "missing.pem")},
+		{CAFile: writeFile(t, dir, "empty.pem", []byte("no pem"))},
+		{CertFile: filepath.Join(dir, "missing.pem")},
+	} {
+		if _, err := c.Build(); err == nil {
+			t.Errorf("Build(%+v) succeeded", c)
+		}
+	}
+
+	conf, err := (&TLSConfig{}).Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if conf.MinVersion != tls.VersionTLS12 {
+		t.Errorf("MinVersion = %x, want tls 1.2", conf.MinVersion)
+	}
+}
+
+func TestWithTLSScheme(t *testing.T) {
+	f, err := NewFabricClient("127.0.0.1:4000", WithTLS(&tls.Config{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := urls(f); got[0] != "https://127.0.0.1:4000" {
+		t.Errorf("urls = %v", got)
+	}
+}
@@ -1,323 +1,344 @@
 package fabric
 
 import (
+	"bytes"
+	"context"
 	"encoding/hex"
 	"encoding/json"
 	"errors"
-	"fabricclient/logger"
 	"fabricclient/util"
 	"io/ioutil"
 	"net/http"
-	"strings"
+	"time"
 )
 
-func (f *FabricClient) IssueToken(addr, privKey, tokenName, totalNumber string) (string, error) {
-	type Token struct {
-		Address     string `json:"address"`
-		TokenName   string `json:"tokenName"`
-		TotalNumber string `json:"totalNumber"`
-	}
+// sendData is the signed envelope posted to the ocean write endpoints.
+type sendData struct {
+	PubKey    string `json:"pubKey"`
+	Origin    string `json:"origin"`
+	Signature string `json:"signature"`
+}
 
-	origin := Token{
-		Address:     addr,
-		TokenName:   tokenName,
-		TotalNumber: totalNumber,
-	}
+// response is the envelope common to every ocean response.
+type response struct {
+	Status bool   `json:"status"`
+	Msg    string `json:"message"`
+}
 
-	originJson, err := json.Marshal(&origin)
+// sign marshals origin and signs its hex encoding with privKey.
+func (f *FabricClient) sign(privKey string, origin interface{}) ([]byte, error) {
+	originJson, err := json.Marshal(origin)
 	if err != nil {
-		logger.Error(err)
-		return "", err
+		f.log.Error(err)
+		return nil, err
 	}
 
 	originJsonHexStr := hex.EncodeToString(originJson)
 
-	signatureHexStr, err := util.Sign(privKey, []byte(originJsonHexStr))
+	signatureHexStr, err := f.signer.Sign(privKey, []byte(originJsonHexStr))
 	if err != nil {
-		logger.Error(err)
-		return "", err
-	}
-
-	type SendData struct {
-		PubKey    string `json:"pubKey"`
-		Origin    string `json:"origin"`
-		Signature string `json:"signature"`
+		f.log.Error(err)
+		return nil, err
 	}
 
-	pubKey, err := util.GetPubKeyByPrivKey(privKey)
+	pubKey, err := f.signer.PubKey(privKey)
 	if err != nil {
-		logger.Error(err)
-		return "", err
+		f.log.Error(err)
+		return nil, err
 	}
 
-	sendData := SendData{
+	data, err := json.Marshal(&sendData{
 		PubKey:    pubKey,
 		Origin:    originJsonHexStr,
 		Signature: signatureHexStr,
+	})
+	if err != nil {
+		f.log.Error(err)
+		return nil, err
 	}
 
-	data, err := json.Marshal(&sendData)
-	if err != nil {
-		logger.Error(err)
-		return "", err
+	return data, nil
+}
+
+// do sends a request to endpoint and decodes the response into out, which
+// must embed the status and message fields. Every failure of the call
+// itself is returned as an *APIError. Queries fail over to the next
+// gateway on a retryable error, writes only if they were never sent.
+func (f *FabricClient) do(ctx context.Context, method, endpoint string, data []byte, out interface{}) error {
+	tried := map[*node]bool{}
+
+	for {
+		n := f.pool.pick(tried)
+		tried[n] = true
+
+		start := time.Now()
+		err := f.send(ctx, n.url, method, endpoint, data, out)
+		// the caller's own deadline or cancellation says nothing of n
+		if ctx.Err() == nil {
+			f.pool.report(n, time.Since(start), err)
+		}
+
+		if err == nil || ctx.Err() != nil || !IsRetryable(err) || len(tried) == len(f.pool.nodes) {
+			return err
+		}
+
+		var apiErr *APIError
+		if method != "GET" && !(errors.As(err, &apiErr) && apiErr.Kind == ErrTransport && notSent(apiErr.Err)) {
+			return err
+		}
+
+		f.log.Info("fail over from", n.url)
 	}
+}
 
-	req, err := http.NewRequest("POST", f.urlHead+"/ocean/v1/issueToken", strings.NewReader(string(data)))
+// send is a single attempt of do against the gateway at urlHead.
+func (f *FabricClient) send(ctx context.Context, urlHead, method, endpoint string, data []byte, out interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, method, urlHead+endpoint, bytes.NewReader(data))
 	if err != nil {
-		logger.Error(err)
-		return "", err
+		f.log.Error(err)
+		return err
 	}
 
-	req.Header.Set("Content-Type", "application/json")
+	if data != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
 
 	resp, err := f.cli.Do(req)
 	if err != nil {
-		logger.Error(err)
-		return "", err
+		f.log.Error(err)
+		return &APIError{Kind: ErrTransport, Endpoint: endpoint, Err: err}
 	}
 	defer resp.Body.Close()
 
 	body, err := ioutil.ReadAll(resp.Body)
 	if err != nil {
-		logger.Error(err)
-		return "", err
+		f.log.Error(err)
+		return &APIError{Kind: ErrTransport, Endpoint: endpoint, StatusCode: resp.StatusCode, Err: err}
 	}
-	logger.Info(string(body))
 
-	type Response struct {
-		Status  bool   `json:"status"`
-		Msg     string `json:"message"`
-		TokenID string `json:"tokenID"`
-	}
+	f.log.Debug(string(body))
 
-	res := Response{}
-	err = json.Unmarshal(body, &res)
-	if err != nil {
-		logger.Error(err)
-		return "", err
+	res := response{}
+	decodeErr := json.Unmarshal(body, &res)
+
+	var apiErr *APIError
+
+	switch {
+	case resp.StatusCode < 200 || resp.StatusCode > 299:
+		apiErr = &APIError{Kind: ErrHTTPStatus, Message: res.Msg}
+	case decodeErr != nil:
+		apiErr = &APIError{Kind: ErrBadResponse, Err: decodeErr}
+	case !res.Status:
+		apiErr = &APIError{Kind: rejectKind(res.Msg), Message: res.Msg}
+	default:
+		err = json.Unmarshal(body, out)
+		if err != nil {
+			apiErr = &APIError{Kind: ErrBadResponse, Err: err}
+		}
 	}
 
-	if !res.Status {
-		logger.Error(res.Msg)
-		return "", errors.New(res.Msg)
+	if apiErr != nil {
+		apiErr.Endpoint = endpoint
+		apiErr.StatusCode = resp.StatusCode
+		apiErr.Body = body
+		f.log.Error(apiErr)
+		return apiErr
 	}
 
-	logger.Info("Successfully IssueToken, tokenID =", res.TokenID)
+	return nil
+}
 
-	return res.TokenID, nil
+func (f *FabricClient) IssueToken(addr, privKey, tokenName, totalNumber string) (string, error) {
+	return f.IssueTokenContext(context.Background(), addr, privKey, tokenName, totalNumber)
 }
 
-func (f *FabricClient) QueryToken(tokenID string) error {
-	resp, err := f.cli.Get(f.urlHead + "/ocean/v1/queryToken/" + tokenID)
-	if err != nil {
-		logger.Error(err)
-		return err
+// IssueTokenContext is like IssueToken but the request is bound to ctx.
+func (f *FabricClient) IssueTokenContext(ctx context.Context, addr, privKey, tokenName, totalNumber string) (string, error) {
+	origin := TokenOrigin{
+		Address:     addr,
+		TokenName:   tokenName,
+		TotalNumber: totalNumber,
 	}
-	defer resp.Body.Close()
 
-	body, err := ioutil.ReadAll(resp.Body)
+	data, err := f.sign(privKey, &origin)
 	if err != nil {
-		logger.Error(err)
-		return err
+		return "", err
 	}
 
-	logger.Info(string(body))
-
 	type Response struct {
-		Status bool   `json:"status"`
-		Msg    string `json:"message"`
-		Data   []byte `json:"data"`
+		response
+		TokenID string `json:"tokenID"`
 	}
 
 	res := Response{}
-	err = json.Unmarshal(body, &res)
+	err = f.do(ctx, "POST", "/ocean/v1/issueToken", data, &res)
 	if err != nil {
-		logger.Error(err)
-		return err
+		return "", err
 	}
 
-	logger.Info(string(res.Data))
-
-	if !res.Status {
-		logger.Error(res.Msg)
-		return errors.New(res.Msg)
-	}
+	f.log.Info("Successfully IssueToken, tokenID =", res.TokenID)
 
-	return nil
+	return res.TokenID, nil
 }
 
+// ErrAppliedUnknownTx is returned by a transfer retried with
+// WithIdempotentTransfers when the gateway reports that an earlier attempt
+// was applied without returning its txID.
+var ErrAppliedUnknownTx = errors.New("transfer applied by an earlier attempt, txID unknown")
+
 func (f *FabricClient) Transfer(tokenID, from, fromPriv, to, num string) (string, error) {
-	type Transfer struct {
-		FromAddress string `json:"fromAddress"`
-		ToAddress   string `json:"toAddress"`
-		TokenID     string `json:"tokenID"`
-		Number      string `json:"number"`
-	}
+	return f.TransferContext(context.Background(), tokenID, from, fromPriv, to, num)
+}
 
-	origin := Transfer{
+// TransferContext is like Transfer but the request is bound to ctx. With
+// WithIdempotentTransfers, a retry finding the transfer already applied
+// returns the txID of the gateway reply, or ErrAppliedUnknownTx without
+// one.
+func (f *FabricClient) TransferContext(ctx context.Context, tokenID, from, fromPriv, to, num string) (string, error) {
+	origin := TransferOrigin{
 		FromAddress: from,
 		ToAddress:   to,
 		TokenID:     tokenID,
 		Number:      num,
 	}
 
-	originJson, err := json.Marshal(&origin)
-	if err != nil {
-		logger.Error(err)
-		return "", err
+	if f.idempotent {
+		origin.Nonce = util.GetUUID()
 	}
 
-	originJsonHexStr := hex.EncodeToString(originJson)
-
-	signatureHexStr, err := util.Sign(fromPriv, []byte(originJsonHexStr))
+	// the envelope is signed once, every attempt sends the same bytes
+	data, err := f.sign(fromPriv, &origin)
 	if err != nil {
-		logger.Error(err)
 		return "", err
 	}
 
-	type SendData struct {
-		PubKey    string `json:"pubKey"`
-		Origin    string `json:"origin"`
-		Signature string `json:"signature"`
-	}
-
-	pubKey, err := util.GetPubKeyByPrivKey(fromPriv)
-	if err != nil {
-		logger.Error(err)
-		return "", err
+	type Response struct {
+		response
+		TxID string `json:"txID"`
 	}
 
-	sendData := SendData{
-		PubKey:    pubKey,
-		Origin:    originJsonHexStr,
-		Signature: signatureHexStr,
-	}
+	for n := 0; ; n++ {
+		res := Response{}
+		err = f.do(ctx, "POST", "/ocean/v1/transfer", data, &res)
+		if err == nil {
+			f.log.Info("Successfully Transfer, txID =", res.TxID)
+			return res.TxID, nil
+		}
 
-	data, err := json.Marshal(&sendData)
-	if err != nil {
-		logger.Error(err)
-		return "", err
-	}
+		// a previous attempt went through, its txID is only known if the
+		// gateway returns it with the duplicate
+		var apiErr *APIError
+		if n > 0 && f.idempotent && errors.As(err, &apiErr) && isDuplicateNonce(apiErr) {
+			if err := json.Unmarshal(apiErr.Body, &res); err != nil || res.TxID == "" {
+				f.log.Error("Transfer already applied, no txID:", err)
+				return "", ErrAppliedUnknownTx
+			}
 
-	req, err := http.NewRequest("POST", f.urlHead+"/ocean/v1/transfer", strings.NewReader(string(data)))
-	if err != nil {
-		logger.Error(err)
-		return "", err
-	}
+			f.log.Info("Transfer already applied, txID =", res.TxID)
+			return res.TxID, nil
+		}
 
-	req.Header.Set("Content-Type", "application/json")
+		if !f.retry.shouldRetry(n, err) || !f.idempotent && !resendable(err) {
+			return "", err
+		}
 
-	resp, err := f.cli.Do(req)
-	if err != nil {
-		logger.Error(err)
-		return "", err
-	}
-	defer resp.Body.Close()
+		f.log.Info("retry transfer, nonce =", origin.Nonce)
 
-	body, err := ioutil.ReadAll(resp.Body)
-	if err != nil {
-		logger.Error(err)
-		return "", err
+		if f.retry.wait(ctx, n) != nil {
+			return "", err
+		}
 	}
-	logger.Debug(string(body))
+}
 
+// getData fetches one of the ocean query endpoints and returns the data
+// field of the response.
+func (f *FabricClient) getData(ctx context.Context, endpoint string) ([]byte, error) {
 	type Response struct {
-		Status bool   `json:"status"`
-		Msg    string `json:"message"`
-		TxID   string `json:"txID"`
+		response
+		Data []byte `json:"data"`
 	}
 
-	res := Response{}
-	err = json.Unmarshal(body, &res)
-	if err != nil {
-		logger.Error(err)
-		return "", err
-	}
+	for n := 0; ; n++ {
+		res := Response{}
+		err := f.do(ctx, "GET", endpoint, nil, &res)
+		if err == nil {
+			return res.Data, nil
+		}
 
-	if !res.Status {
-		logger.Error(res.Msg)
-		return "", errors.New(res.Msg)
+		if !f.retry.shouldRetry(n, err) || f.retry.wait(ctx, n) != nil {
+			return nil, err
+		}
 	}
+}
 
-	logger.Info("Successfully Transfer, txID =", res.TxID)
-
-	return res.TxID, nil
+func (f *FabricClient) QueryToken(tokenID string) (*TokenInfo, error) {
+	return f.QueryTokenContext(context.Background(), tokenID)
 }
 
-func (f *FabricClient) QueryTx(txID string) error {
-	resp, err := f.cli.Get(f.urlHead + "/ocean/v1/queryTx/" + txID)
-	if err != nil {
-		logger.Error(err)
-		return err
-	}
-	defer resp.Body.Close()
+// QueryTokenContext is like QueryToken but the request is bound to ctx.
+func (f *FabricClient) QueryTokenContext(ctx context.Context, tokenID string) (*TokenInfo, error) {
+	endpoint := "/ocean/v1/queryToken/" + tokenID
 
-	body, err := ioutil.ReadAll(resp.Body)
+	data, err := f.getData(ctx, endpoint)
 	if err != nil {
-		logger.Error(err)
-		return err
-	}
-
-	logger.Info(string(body))
-
-	type Response struct {
-		Status bool   `json:"status"`
-		Msg    string `json:"message"`
-		Data   []byte `json:"data"`
+		return nil, err
 	}
 
-	res := Response{}
-	err = json.Unmarshal(body, &res)
+	info, err := decodeTokenInfo(tokenID, data)
 	if err != nil {
-		logger.Error(err)
-		return err
+		f.log.Error(err)
+		return nil, &APIError{Kind: ErrBadResponse, Endpoint: endpoint, Body: data, Err: err}
 	}
 
-	logger.Info(string(res.Data))
+	f.log.Info("token", info.TokenID, info.TokenName, info.TotalNumber)
 
-	if !res.Status {
-		logger.Error(res.Msg)
-		return errors.New(res.Msg)
-	}
+	return info, nil
+}
 
-	return nil
+func (f *FabricClient) QueryTx(txID string) (*TxRecord, error) {
+	return f.QueryTxContext(context.Background(), txID)
 }
 
-func (f *FabricClient) QueryBalance(address string) error {
-	resp, err := f.cli.Get(f.urlHead + "/ocean/v1/queryBalance/" + address)
+// QueryTxContext is like QueryTx but the request is bound to ctx.
+func (f *FabricClient) QueryTxContext(ctx context.Context, txID string) (*TxRecord, error) {
+	endpoint := "/ocean/v1/queryTx/" + txID
+
+	data, err := f.getData(ctx, endpoint)
 	if err != nil {
-		logger.Error(err)
-		return err
+		return nil, err
 	}
-	defer resp.Body.Close()
 
-	body, err := ioutil.ReadAll(resp.Body)
+	tx, err := decodeTxRecord(txID, data)
 	if err != nil {
-		logger.Error(err)
-		return err
+		f.log.Error(err)
+		return nil, &APIError{Kind: ErrBadResponse, Endpoint: endpoint, Body: data, Err: err}
 	}
 
-	logger.Debug(string(body))
+	f.log.Info("tx", tx.TxID, string(tx.OriginJson))
 
-	type Response struct {
-		Status bool   `json:"status"`
-		Msg    string `json:"message"`
-		Data   []byte `json:"data"`
-	}
+	return tx, nil
+}
 
-	res := Response{}
-	err = json.Unmarshal(body, &res)
+func (f *FabricClient) QueryBalance(address string) (*Balance, error) {
+	return f.QueryBalanceContext(context.Background(), address)
+}
+
+// QueryBalanceContext is like QueryBalance but the request is bound to ctx.
+func (f *FabricClient) QueryBalanceContext(ctx context.Context, address string) (*Balance, error) {
+	endpoint := "/ocean/v1/queryBalance/" + address
+
+	data, err := f.getData(ctx, endpoint)
 	if err != nil {
-		logger.Error(err)
-		return err
+		return nil, err
 	}
 
-	logger.Info(string(res.Data))
-
-	if !res.Status {
-		logger.Error(res.Msg)
-		return errors.New(res.Msg)
+	b, err := decodeBalance(address, data)
+	if err != nil {
+		f.log.Error(err)
+		return nil, &APIError{Kind: ErrBadResponse, Endpoint: endpoint, Body: data, Err: err}
 	}
 
-	return nil
+	f.log.Info("balance", address, b.Tokens)
+
+	return b, nil
 }
@@ -0,0 +1,226 @@
+package fabric
+
+import (
+	"context"
+	"errors"
+	"fabricclient/fabric/fabrictest"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestIssueAndQueryToken(t *testing.T) {
+	s := fabrictest.NewServer()
+	defer s.Close()
+	f := newTestClient(t, s.URL)
+
+	w, tokenID := issue(t, f, "10000")
+
+	info, err := f.QueryToken(tokenID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.TokenID != tokenID || info.TokenName != "OCE" || info.Address != w.Address || info.TotalNumber != "10000" {
+		t.Errorf("QueryToken() = %+v", info)
+	}
+	if len(info.Raw) == 0 {
+		t.Error("QueryToken() Raw is empty")
+	}
+
+	b, err := f.QueryBalance(w.Address)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b.Get(tokenID) != "10000" {
+		t.Errorf("balance = %s, want 10000", b.Get(tokenID))
+	}
+	if b.Get("unknown") != "0" {
+		t.Errorf("balance of unknown token = %s", b.Get("unknown"))
+	}
+}
+
+func TestTransferAndQueryTx(t *testing.T) {
+	s := fabrictest.NewServer()
+	defer s.Close()
+	f := newTestClient(t, s.URL)
+
+	from, tokenID := issue(t, f, "1000")
+	to := newWallet()
+
+	txID, err := f.Transfer(tokenID, from.Address, from.PrivKey, to.Address, "100")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got := s.Balance(from.Address, tokenID); got != "900" {
+		t.Errorf("from balance = %s, want 900", got)
+	}
+	if got := s.Balance(to.Address, tokenID); got != "100" {
+		t.Errorf("to balance = %s, want 100", got)
+	}
+
+	tx, err := f.QueryTx(txID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tx.TxID != txID || tx.Transfer == nil || tx.Issue != nil {
+		t.Fatalf("QueryTx() = %+v", tx)
+	}
+	want := TransferOrigin{FromAddress: from.Address, ToAddress: to.Address, TokenID: tokenID, Number: "100"}
+	if *tx.Transfer != want {
+		t.Errorf("QueryTx() origin = %+v, want %+v", *tx.Transfer, want)
+	}
+}
+
+func TestTransferRejected(t *testing.T) {
+	s := fabrictest.NewServer()
+	defer s.Close()
+	f := newTestClient(t, s.URL)
+
+	from, tokenID := issue(t, f, "10")
+	to := newWallet()
+
+	_, err := f.Transfer(tokenID, from.Address, from.PrivKey, to.Address, "11")
+	if !errors.Is(err, ErrInsufficientBalance) || !errors.Is(err, ErrRejected) {
+		t.Errorf("Transfer() err = %v, want ErrInsufficientBalance", err)
+	}
+
+	// signed by a key which does not own the from address
+	_, err = f.Transfer(tokenID, from.Address, to.PrivKey, to.Address, "1")
+	if !errors.Is(err, ErrRejected) {
+		t.Errorf("Transfer() err = %v, want ErrRejected", err)
+	}
+
+	_, err = f.QueryTx("unknown")
+	if !errors.Is(err, ErrNotFound) {
+		t.Errorf("QueryTx() err = %v, want ErrNotFound", err)
+	}
+
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) || apiErr.Message != "tx not found" || apiErr.Endpoint != "/ocean/v1/queryTx/unknown" {
+		t.Errorf("QueryTx() err = %#v", err)
+	}
+	if IsRetryable(err) {
+		t.Error("status:false is retryable")
+	}
+}
+
+func TestRejectKind(t *testing.T) {
+	for msg, want := range map[string]error{
+		"insufficient balance":            ErrInsufficientBalance,
+		"Token not found":                 ErrNotFound,
+		"tx not found":                    ErrNotFound,
+		"Error: Entry not found in index": ErrNotFound,
+		"duplicate nonce":                 ErrDuplicate,
+		// unrelated rejections stay unclassified
+		"utxo already spent":        ErrRejected,
+		"channel does not exist":    ErrRejected,
+		"address already has a key": ErrRejected,
+		"duplicate token name":      ErrRejected,
+		"not enough endorsements":   ErrRejected,
+		"":                          ErrRejected,
+	} {
+		if got := rejectKind(msg); got != want {
+			t.Errorf("rejectKind(%q) = %v, want %v", msg, got, want)
+		}
+	}
+}
+
+func TestMalformedWIF(t *testing.T) {
+	s := fabrictest.NewServer()
+	defer s.Close()
+	f := newTestClient(t, s.URL)
+
+	to := newWallet()
+
+	_, err := f.IssueToken(to.Address, "not-a-wif", "OCE", "1")
+	if err == nil {
+		t.Error("IssueToken() with malformed key succeeded")
+	}
+
+	_, err = f.Transfer("token", to.Address, "not-a-wif", to.Address, "1")
+	if err == nil {
+		t.Error("Transfer() with malformed key succeeded")
+	}
+
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		t.Errorf("malformed key reached the server: %v", err)
+	}
+}
+
+func TestHTTPStatus(t *testing.T) {
+	s := fabrictest.NewServer()
+	defer s.Close()
+	f := newTestClient(t, s.URL)
+
+	s.Inject(fabrictest.QueryBalance, fabrictest.Fault{StatusCode: http.StatusInternalServerError, Message: "boom"})
+
+	_, err := f.QueryBalance("addr")
+
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) || apiErr.Kind != ErrHTTPStatus {
+		t.Fatalf("QueryBalance() err = %v, want ErrHTTPStatus", err)
+	}
+	if apiErr.StatusCode != 500 || apiErr.Message != "boom" || len(apiErr.Body) == 0 {
+		t.Errorf("QueryBalance() err = %#v", apiErr)
+	}
+	if !IsRetryable(err) {
+		t.Error("http 500 is not retryable")
+	}
+}
+
+func TestBadJson(t *testing.T) {
+	s := fabrictest.NewServer()
+	defer s.Close()
+	f := newTestClient(t, s.URL)
+
+	s.Inject(fabrictest.QueryToken, fabrictest.Fault{Body: "<html>"})
+
+	_, err := f.QueryToken("token")
+	if !errors.Is(err, ErrBadResponse) {
+		t.Errorf("QueryToken() err = %v, want ErrBadResponse", err)
+	}
+
+	// a valid envelope whose data is not a token
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"status":true,"message":"","data":"WzEsMl0="}`))
+	}))
+	defer ts.Close()
+
+	_, err = newTestClient(t, ts.URL).QueryToken("token")
+	if !errors.Is(err, ErrBadResponse) {
+		t.Errorf("QueryToken() err = %v, want ErrBadResponse", err)
+	}
+}
+
+func TestTimeout(t *testing.T) {
+	s := fabrictest.NewServer()
+	defer s.Close()
+	s.SetLatency(time.Second)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	_, err := newTestClient(t, s.URL).QueryBalanceContext(ctx, "addr")
+	if !errors.Is(err, ErrTransport) || !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("QueryBalanceContext() err = %v, want deadline exceeded", err)
+	}
+
+	_, err = newTestClient(t, s.URL, WithTimeout(50*time.Millisecond)).QueryBalance("addr")
+	if !errors.Is(err, ErrTransport) {
+		t.Errorf("QueryBalance() err = %v, want ErrTransport", err)
+	}
+}
+
+func TestConnectionRefused(t *testing.T) {
+	s := fabrictest.NewServer()
+	url := s.URL
+	s.Close()
+
+	_, err := newTestClient(t, url).QueryBalance("addr")
+	if !errors.Is(err, ErrTransport) || !IsRetryable(err) {
+		t.Errorf("QueryBalance() err = %v, want retryable ErrTransport", err)
+	}
+}
@@ -0,0 +1,158 @@
+package fabric
+
+import (
+	"encoding/hex"
+	"encoding/json"
+)
+
+// TokenOrigin is the signed payload of an issueToken request.
+type TokenOrigin struct {
+	Address     string `json:"address"`
+	TokenName   string `json:"tokenName"`
+	TotalNumber string `json:"totalNumber"`
+}
+
+// TransferOrigin is the signed payload of a transfer request.
+type TransferOrigin struct {
+	FromAddress string `json:"fromAddress"`
+	ToAddress   string `json:"toAddress"`
+	TokenID     string `json:"tokenID"`
+	Number      string `json:"number"`
+	// Nonce is a client generated idempotency key, set with
+	// WithIdempotentTransfers for gateways recognizing a resubmitted
+	// transfer by it.
+	Nonce string `json:"nonce,omitempty"`
+}
+
+// TokenInfo is the metadata of a token returned by queryToken.
+type TokenInfo struct {
+	TokenID     string `json:"tokenID"`
+	TokenName   string `json:"tokenName"`
+	Address     string `json:"address"`
+	TotalNumber string `json:"totalNumber"`
+
+	// Raw is the undecoded data field of the response.
+	Raw json.RawMessage `json:"-"`
+}
+
+// Balance holds the amount of every token owned by an address.
+type Balance struct {
+	Address string
+	// Tokens maps tokenID to amount.
+	Tokens map[string]string
+
+	// Raw is the undecoded data field of the response.
+	Raw json.RawMessage
+}
+
+// Get returns the amount of tokenID, "0" if the address holds none.
+func (b *Balance) Get(tokenID string) string {
+	if n, ok := b.Tokens[tokenID]; ok {
+		return n
+	}
+
+	return "0"
+}
+
+// TxRecord is a transaction returned by queryTx.
+type TxRecord struct {
+	TxID      string `json:"txID"`
+	PubKey    string `json:"pubKey"`
+	Origin    string `json:"origin"`
+	Signature string `json:"signature"`
+	// ValidationCode is set by the server once the transaction is in a
+	// block, "VALID" or "0" for a valid one.
+	ValidationCode string `json:"validationCode,omitempty"`
+
+	// OriginJson is the hex decoded Origin.
+	OriginJson json.RawMessage `json:"-"`
+	// Issue is set when the origin is an issueToken payload.
+	Issue *TokenOrigin `json:"-"`
+	// Transfer is set when the origin is a transfer payload.
+	Transfer *TransferOrigin `json:"-"`
+
+	// Raw is the undecoded data field of the response.
+	Raw json.RawMessage `json:"-"`
+}
+
+func decodeTokenInfo(tokenID string, data []byte) (*TokenInfo, error) {
+	info := &TokenInfo{}
+	err := json.Unmarshal(data, info)
+	if err != nil {
+		return nil, err
+	}
+
+	if info.TokenID == "" {
+		info.TokenID = tokenID
+	}
+	info.Raw = data
+
+	return info, nil
+}
+
+func decodeBalance(address string, data []byte) (*Balance, error) {
+	m := map[string]json.RawMessage{}
+	err := json.Unmarshal(data, &m)
+	if err != nil {
+		return nil, err
+	}
+
+	b := &Balance{
+		Address: address,
+		Tokens:  map[string]string{},
+		Raw:     data,
+	}
+
+	for tokenID, v := range m {
+		var num string
+		if json.Unmarshal(v, &num) != nil {
+			// amounts may also come back as bare json numbers
+			num = string(v)
+		}
+		b.Tokens[tokenID] = num
+	}
+
+	return b, nil
+}
+
+func decodeTxRecord(txID string, data []byte) (*TxRecord, error) {
+	tx := &TxRecord{}
+	err := json.Unmarshal(data, tx)
+	if err != nil {
+		return nil, err
+	}
+
+	if tx.TxID == "" {
+		tx.TxID = txID
+	}
+	tx.Raw = data
+
+	if tx.Origin == "" {
+		return tx, nil
+	}
+
+	originJson, err := hex.DecodeString(tx.Origin)
+	if err != nil {
+		return nil, err
+	}
+	tx.OriginJson = originJson
+
+	fields := map[string]json.RawMessage{}
+	err = json.Unmarshal(originJson, &fields)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, ok := fields["fromAddress"]; ok {
+		tx.Transfer = &TransferOrigin{}
+		err = json.Unmarshal(originJson, tx.Transfer)
+	} else if _, ok := fields["tokenName"]; ok {
+		tx.Issue = &TokenOrigin{}
+		err = json.Unmarshal(originJson, tx.Issue)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return tx, nil
+}
@@ -0,0 +1,141 @@
+package fabric
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// ErrTxInvalid is returned by WaitForTx when the transaction was committed
+// to the ledger but marked invalid.
+var ErrTxInvalid = errors.New("transaction is invalid")
+
+type TxStatus int
+
+const (
+	TxPending TxStatus = iota
+	TxCommitted
+	TxInvalid
+	TxTimeout
+)
+
+func (s TxStatus) String() string {
+	switch s {
+	case TxPending:
+		return "pending"
+	case TxCommitted:
+		return "committed"
+	case TxInvalid:
+		return "invalid"
+	case TxTimeout:
+		return "timeout"
+	}
+
+	return "unknown"
+}
+
+// Backoff is an exponential delay between two attempts.
+type Backoff struct {
+	Initial time.Duration
+	Max     time.Duration
+	// Multiplier grows the delay after each attempt, a value below 1
+	// keeps it at Initial.
+	Multiplier float64
+}
+
+// DefaultWaitBackoff is the polling interval used by WaitForTx.
+var DefaultWaitBackoff = Backoff{
+	Initial:    500 * time.Millisecond,
+	Max:        5 * time.Second,
+	Multiplier: 2,
+}
+
+// delay returns the wait before attempt n, starting at 0.
+func (b Backoff) delay(n int) time.Duration {
+	m := b.Multiplier
+	if m < 1 {
+		m = 1
+	}
+
+	d := float64(b.Initial)
+	for i := 0; i < n; i++ {
+		d *= m
+		if b.Max > 0 && d >= float64(b.Max) {
+			return b.Max
+		}
+	}
+
+	return time.Duration(d)
+}
+
+// TxResult is the final state of a transaction seen by WaitForTx.
+type TxResult struct {
+	TxID   string
+	Status TxStatus
+	// Tx is the committed transaction, nil on timeout.
+	Tx *TxRecord
+}
+
+// txStatus maps the validation code of a committed transaction.
+func txStatus(tx *TxRecord) TxStatus {
+	switch tx.ValidationCode {
+	case "", "0", "VALID":
+		return TxCommitted
+	}
+
+	return TxInvalid
+}
+
+// Status returns the status of a committed transaction, TxCommitted or
+// TxInvalid.
+func (tx *TxRecord) Status() TxStatus {
+	return txStatus(tx)
+}
+
+// WaitForTx polls queryTx until txID is committed or ctx is done. The
+// status of the result is TxCommitted with a nil error, TxInvalid with
+// ErrTxInvalid, or TxTimeout with the error of ctx.
+func (f *FabricClient) WaitForTx(ctx context.Context, txID string) (*TxResult, error) {
+	res := &TxResult{
+		TxID:   txID,
+		Status: TxPending,
+	}
+
+	for n := 0; ; n++ {
+		tx, err := f.QueryTxContext(ctx, txID)
+		if err == nil {
+			res.Tx = tx
+			res.Status = txStatus(tx)
+			if res.Status == TxInvalid {
+				return res, ErrTxInvalid
+			}
+
+			f.log.Info("tx committed, txID =", txID)
+			return res, nil
+		}
+
+		// a transaction not found yet is still in flight
+		if ctx.Err() == nil && !errors.Is(err, ErrNotFound) && !IsRetryable(err) {
+			return res, err
+		}
+
+		timer := time.NewTimer(f.waitBackoff.delay(n))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			res.Status = TxTimeout
+			return res, ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// TransferAndWait submits a transfer and waits for it to be committed.
+func (f *FabricClient) TransferAndWait(ctx context.Context, tokenID, from, fromPriv, to, num string) (*TxResult, error) {
+	txID, err := f.TransferContext(ctx, tokenID, from, fromPriv, to, num)
+	if err != nil {
+		return nil, err
+	}
+
+	return f.WaitForTx(ctx, txID)
+}
@@ -0,0 +1,113 @@
+package fabric
+
+import (
+	"context"
+	"errors"
+	"fabricclient/fabric/fabrictest"
+	"testing"
+	"time"
+)
+
+var testBackoff = Backoff{Initial: 10 * time.Millisecond, Max: 50 * time.Millisecond, Multiplier: 2}
+
+func TestBackoffDelay(t *testing.T) {
+	b := Backoff{Initial: time.Second, Max: 5 * time.Second, Multiplier: 2}
+
+	want := []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 5 * time.Second, 5 * time.Second}
+	for n, d := range want {
+		if got := b.delay(n); got != d {
+			t.Errorf("delay(%d) = %v, want %v", n, got, d)
+		}
+	}
+
+	// a zero or shrinking multiplier never polls in a tight loop
+	for _, m := range []float64{0, 0.5} {
+		b := Backoff{Initial: time.Second, Multiplier: m}
+		for n := 0; n < 5; n++ {
+			if got := b.delay(n); got != time.Second {
+				t.Errorf("multiplier %v: delay(%d) = %v, want 1s", m, n, got)
+			}
+		}
+	}
+}
+
+func TestWaitForTx(t *testing.T) {
+	s := fabrictest.NewServer()
+	defer s.Close()
+	s.SetCommitDelay(100 * time.Millisecond)
+	f := newTestClient(t, s.URL, WithWaitBackoff(testBackoff))
+
+	from, tokenID := issue(t, f, "10")
+	to := newWallet()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	res, err := f.TransferAndWait(ctx, tokenID, from.Address, from.PrivKey, to.Address, "5")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Status != TxCommitted || res.Tx == nil || res.Tx.Transfer.Number != "5" {
+		t.Errorf("TransferAndWait() = %+v", res)
+	}
+}
+
+func TestWaitForTxInvalid(t *testing.T) {
+	s := fabrictest.NewServer()
+	defer s.Close()
+	// assumes a gateway reporting the fabric validation code
+	s.ValidationCode = "MVCC_READ_CONFLICT"
+	f := newTestClient(t, s.URL, WithWaitBackoff(testBackoff))
+
+	from, tokenID := issue(t, f, "10")
+	to := newWallet()
+
+	res, err := f.TransferAndWait(context.Background(), tokenID, from.Address, from.PrivKey, to.Address, "5")
+	if !errors.Is(err, ErrTxInvalid) || res.Status != TxInvalid {
+		t.Errorf("TransferAndWait() = %+v, %v, want ErrTxInvalid", res, err)
+	}
+}
+
+func TestWaitForTxTimeout(t *testing.T) {
+	s := fabrictest.NewServer()
+	defer s.Close()
+	f := newTestClient(t, s.URL, WithWaitBackoff(testBackoff))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	res, err := f.WaitForTx(ctx, "unknown")
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("WaitForTx() err = %v, want deadline exceeded", err)
+	}
+	if res.Status != TxTimeout {
+		t.Errorf("WaitForTx() status = %v, want timeout", res.Status)
+	}
+}
+
+func TestWaitForTxServerError(t *testing.T) {
+	s := fabrictest.NewServer()
+	defer s.Close()
+	f := newTestClient(t, s.URL, WithWaitBackoff(testBackoff))
+
+	s.Inject(fabrictest.QueryTx, fabrictest.Fault{Body: "not json"})
+
+	_, err := f.WaitForTx(context.Background(), "unknown")
+	if !errors.Is(err, ErrBadResponse) {
+		t.Errorf("WaitForTx() err = %v, want ErrBadResponse", err)
+	}
+}
+
+func TestTxStatus(t *testing.T) {
+	for code, want := range map[string]TxStatus{
+		"":                   TxCommitted,
+		"0":                  TxCommitted,
+		"VALID":              TxCommitted,
+		"MVCC_READ_CONFLICT": TxInvalid,
+		"11":                 TxInvalid,
+	} {
+		if got := txStatus(&TxRecord{ValidationCode: code}); got != want {
+			t.Errorf("txStatus(%q) = %v, want %v", code, got, want)
+		}
+	}
+}
@@ -0,0 +1,181 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"os"
+
+	"fabricclient/fabric"
+	"fabricclient/hdwallet"
+	"fabricclient/load"
+)
+
+const (
+	// mnemonicEnv holds the BIP39 mnemonic of the hd commands, asked on
+	// the terminal if not set.
+	mnemonicEnv = "FABRICCLIENT_MNEMONIC"
+	// mnemonicPasswordEnv holds the optional BIP39 password.
+	mnemonicPasswordEnv = "FABRICCLIENT_MNEMONIC_PASSWORD"
+)
+
+// seed is the mnemonic and derivation path of the hd commands.
+type seed struct {
+	path *string
+}
+
+func seedFlags(fs *flag.FlagSet) *seed {
+	return &seed{
+		path: fs.String("path", hdwallet.DefaultPath, "derivation path, wallet i is path/i"),
+	}
+}
+
+// open reads the mnemonic and returns its wallets on the network of f
+// with the parsed path.
+func (s *seed) open(f *fabric.FabricClient) (*hdwallet.HD, hdwallet.Path, error) {
+	path, err := hdwallet.ParsePath(*s.path)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	mnemonic, err := readSecret(mnemonicEnv, "mnemonic: ")
+	if err != nil {
+		return nil, nil, err
+	}
+
+	hd, err := hdwallet.New(mnemonic, os.Getenv(mnemonicPasswordEnv), f.Network())
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return hd, path, nil
+}
+
+func hdNew(f *fabric.FabricClient, args []string) error {
+	fs := flag.NewFlagSet("hd new", flag.ContinueOnError)
+	bits := fs.Int("bits", hdwallet.DefaultBits, "entropy, 128 to 256 by steps of 32")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	mnemonic, err := hdwallet.NewMnemonic(*bits)
+	if err != nil {
+		return err
+	}
+
+	return show(map[string]string{"mnemonic": mnemonic}, fields("mnemonic", mnemonic))
+}
+
+type derivedView struct {
+	Path    string `json:"path"`
+	Address string `json:"address"`
+	PrivKey string `json:"privKey,omitempty"`
+}
+
+func hdDerive(f *fabric.FabricClient, args []string) error {
+	fs := flag.NewFlagSet("hd derive", flag.ContinueOnError)
+	sd := seedFlags(fs)
+	from := fs.Int("from", 0, "first wallet index")
+	n := fs.Int("n", 1, "number of wallets")
+	keys := fs.Bool("keys", false, "show the private keys")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	hd, path, err := sd.open(f)
+	if err != nil {
+		return err
+	}
+
+	wallets, err := hd.Wallets(path, *from, *n)
+	if err != nil {
+		return err
+	}
+
+	views := []derivedView{}
+	t := &table{header: []string{"PATH", "ADDRESS"}}
+	if *keys {
+		t.header = append(t.header, "PRIVKEY")
+	}
+	for i, w := range wallets {
+		v := derivedView{Path: path.Child(uint32(*from + i)).String(), Address: w.Address}
+		if *keys {
+			v.PrivKey = w.PrivKey
+			t.add(v.Path, v.Address, v.PrivKey)
+		} else {
+			t.add(v.Path, v.Address)
+		}
+		views = append(views, v)
+	}
+
+	return show(views, t)
+}
+
+func hdImport(f *fabric.FabricClient, args []string) error {
+	fs := flag.NewFlagSet("hd import", flag.ContinueOnError)
+	name := fs.String("name", "", "wallet name in the keystore")
+	sd := seedFlags(fs)
+	index := fs.Int("index", 0, "wallet index")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if err := required(fs, "name"); err != nil {
+		return err
+	}
+
+	hd, path, err := sd.open(f)
+	if err != nil {
+		return err
+	}
+
+	w, err := hd.Derive(path.Child(uint32(*index)))
+	if err != nil {
+		return err
+	}
+
+	err = importWallet(f, *name, *w)
+	if err != nil {
+		return err
+	}
+
+	v := &derivedView{Path: path.Child(uint32(*index)).String(), Address: w.Address}
+	return show(v, fields("name", *name, "path", v.Path, "address", v.Address))
+}
+
+func hdSweep(f *fabric.FabricClient, args []string) error {
+	fs := flag.NewFlagSet("hd sweep", flag.ContinueOnError)
+	sd := seedFlags(fs)
+	to := fs.String("to", "", "address receiving the balances")
+	tokenID := fs.String("token", "", "token swept, all if empty")
+	from := fs.Int("from", 0, "first wallet index")
+	n := fs.Int("n", 100, "number of wallets")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if err := required(fs, "to"); err != nil {
+		return err
+	}
+
+	hd, path, err := sd.open(f)
+	if err != nil {
+		return err
+	}
+
+	wallets, err := hd.Wallets(path, *from, *n)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), callTimeout)
+	defer cancel()
+
+	swept, err := load.Sweep(ctx, f, wallets, *tokenID, *to)
+
+	t := &table{header: []string{"ADDRESS", "TOKEN", "AMOUNT", "TX"}}
+	for _, s := range swept {
+		t.add(s.Address, s.TokenID, s.Amount, s.TxID)
+	}
+	if serr := show(swept, t); err == nil {
+		err = serr
+	}
+	return err
+}
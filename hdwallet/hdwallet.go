@@ -0,0 +1,156 @@
+// Package hdwallet derives wallets from a BIP39 mnemonic along BIP32
+// paths, so that a set of wallets can be recreated from one seed.
+package hdwallet
+
+import (
+	"errors"
+	"fabricclient/fabric"
+	"fabricclient/util"
+	"fmt"
+	"github.com/btcsuite/btcutil/hdkeychain"
+	"github.com/tyler-smith/go-bip39"
+	"strconv"
+	"strings"
+)
+
+// DefaultPath is the BIP44 external chain of the first account, wallet i
+// is DefaultPath/i.
+const DefaultPath = "m/44'/0'/0'/0"
+
+// DefaultBits is the entropy of a new mnemonic, 12 words.
+const DefaultBits = 128
+
+var ErrMnemonic = errors.New("hdwallet: invalid mnemonic")
+
+// Path is a BIP32 derivation path, hardened indexes are offset by
+// hdkeychain.HardenedKeyStart.
+type Path []uint32
+
+// ParsePath parses a path such as m/44'/0'/0'/0, ' or h marking the
+// hardened indexes.
+func ParsePath(s string) (Path, error) {
+	parts := strings.Split(strings.TrimSpace(s), "/")
+	if parts[0] != "m" {
+		return nil, fmt.Errorf("hdwallet: path %q does not start with m", s)
+	}
+
+	p := Path{}
+	for _, part := range parts[1:] {
+		offset := uint32(0)
+		if strings.HasSuffix(part, "'") || strings.HasSuffix(part, "h") {
+			offset = hdkeychain.HardenedKeyStart
+			part = part[:len(part)-1]
+		}
+
+		n, err := strconv.ParseUint(part, 10, 32)
+		if err != nil || uint32(n) >= hdkeychain.HardenedKeyStart {
+			return nil, fmt.Errorf("hdwallet: invalid index %q in path %q", part, s)
+		}
+
+		p = append(p, uint32(n)+offset)
+	}
+
+	return p, nil
+}
+
+// MustParsePath is ParsePath for constant paths.
+func MustParsePath(s string) Path {
+	p, err := ParsePath(s)
+	if err != nil {
+		panic(err)
+	}
+	return p
+}
+
+func (p Path) String() string {
+	b := &strings.Builder{}
+	b.WriteString("m")
+
+	for _, i := range p {
+		if i >= hdkeychain.HardenedKeyStart {
+			fmt.Fprintf(b, "/%d'", i-hdkeychain.HardenedKeyStart)
+		} else {
+			fmt.Fprintf(b, "/%d", i)
+		}
+	}
+
+	return b.String()
+}
+
+// Child returns the path of child i of p.
+func (p Path) Child(i uint32) Path {
+	c := make(Path, len(p), len(p)+1)
+	copy(c, p)
+	return append(c, i)
+}
+
+// NewMnemonic returns a mnemonic of bits of entropy, 128 to 256 by steps
+// of 32.
+func NewMnemonic(bits int) (string, error) {
+	entropy, err := bip39.NewEntropy(bits)
+	if err != nil {
+		return "", err
+	}
+	return bip39.NewMnemonic(entropy)
+}
+
+// HD derives the wallets of one seed.
+type HD struct {
+	master *hdkeychain.ExtendedKey
+	net    *util.Network
+}
+
+// New returns the HD wallet of mnemonic, protected by the optional BIP39
+// password. Its wallets are encoded for net.
+func New(mnemonic, password string, net *util.Network) (*HD, error) {
+	mnemonic = strings.Join(strings.Fields(mnemonic), " ")
+
+	seed, err := bip39.NewSeedWithErrorChecking(mnemonic, password)
+	if err != nil {
+		return nil, ErrMnemonic
+	}
+
+	master, err := hdkeychain.NewMaster(seed, net.Params)
+	if err != nil {
+		return nil, err
+	}
+
+	return &HD{master: master, net: net}, nil
+}
+
+// Derive returns the wallet at path.
+func (h *HD) Derive(path Path) (*fabric.Wallet, error) {
+	k := h.master
+	for _, i := range path {
+		var err error
+		k, err = k.Derive(i)
+		if err != nil {
+			return nil, fmt.Errorf("hdwallet: %s: %v", path, err)
+		}
+	}
+
+	priv, err := k.ECPrivKey()
+	if err != nil {
+		return nil, err
+	}
+
+	wif, _, address, err := h.net.Encode(priv)
+	if err != nil {
+		return nil, err
+	}
+
+	return &fabric.Wallet{Address: address, PrivKey: wif}, nil
+}
+
+// Wallets returns the n wallets base/from to base/from+n-1.
+func (h *HD) Wallets(base Path, from, n int) ([]*fabric.Wallet, error) {
+	wallets := []*fabric.Wallet{}
+	for i := from; i < from+n; i++ {
+		w, err := h.Derive(base.Child(uint32(i)))
+		if err != nil {
+			return nil, err
+		}
+		wallets = append(wallets, w)
+	}
+	return wallets, nil
+}
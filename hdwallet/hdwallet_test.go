@@ -0,0 +1,122 @@
+package hdwallet
+
+import (
+	"fabricclient/util"
+	"strings"
+	"testing"
+)
+
+const testMnemonic = "abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon about"
+
+func TestDerive(t *testing.T) {
+	hd, err := New(testMnemonic, "", util.MainNet)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// first BIP44 address of the test mnemonic
+	w, err := hd.Derive(MustParsePath(DefaultPath).Child(0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if w.Address != "1LqBGSKuX5yYUonjxT5qGfpUsXKYYWeabA" {
+		t.Errorf("address %s", w.Address)
+	}
+
+	pubKey, err := util.GetPubKeyByPrivKey(w.PrivKey)
+	if err != nil || util.GetAddress(pubKey) != w.Address {
+		t.Errorf("key %s does not match %s: %v", w.PrivKey, w.Address, err)
+	}
+
+	// the password and the whitespace of the mnemonic
+	other, _ := New(testMnemonic, "TREZOR", util.MainNet)
+	if o, _ := other.Derive(MustParsePath(DefaultPath).Child(0)); o.Address == w.Address {
+		t.Error("the password does not change the wallets")
+	}
+	same, _ := New("  "+strings.Replace(testMnemonic, " ", "\n", 3), "", util.MainNet)
+	if s, _ := same.Derive(MustParsePath(DefaultPath).Child(0)); *s != *w {
+		t.Error("whitespace changes the wallets")
+	}
+
+	// the same key encoded for another network
+	testnet, _ := New(testMnemonic, "", util.TestNet)
+	tw, err := testnet.Derive(MustParsePath(DefaultPath).Child(0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tpk, _ := util.TestNet.PubKey(tw.PrivKey); tpk != pubKey || tw.Address != testnetAddress(t, pubKey) {
+		t.Errorf("testnet wallet %+v", tw)
+	}
+
+	wallets, err := hd.Wallets(MustParsePath(DefaultPath), 0, 3)
+	if err != nil || len(wallets) != 3 || *wallets[0] != *w || wallets[1].Address == w.Address {
+		t.Errorf("Wallets = %v, %v", wallets, err)
+	}
+}
+
+func testnetAddress(t *testing.T, pubKey string) string {
+	t.Helper()
+
+	addr, err := util.TestNet.Address(pubKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return addr
+}
+
+func TestNewMnemonic(t *testing.T) {
+	m, err := NewMnemonic(DefaultBits)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(strings.Fields(m)) != 12 {
+		t.Errorf("mnemonic %q", m)
+	}
+	if _, err := New(m, "", util.MainNet); err != nil {
+		t.Error(err)
+	}
+
+	if m, err := NewMnemonic(256); err != nil || len(strings.Fields(m)) != 24 {
+		t.Errorf("256 bits: %q, %v", m, err)
+	}
+	if _, err := NewMnemonic(100); err == nil {
+		t.Error("100 bits succeeded")
+	}
+
+	for _, m := range []string{"", "abandon abandon", strings.Replace(testMnemonic, "about", "abandon", 1)} {
+		if _, err := New(m, "", util.MainNet); err != ErrMnemonic {
+			t.Errorf("New(%q): %v", m, err)
+		}
+	}
+}
+
+func TestPath(t *testing.T) {
+	for s, want := range map[string]string{
+		"m":               "m",
+		"m/44'/0'/0'/0":   "m/44'/0'/0'/0",
+		"m/44h/60h/0h/0/": "",
+		"m/0/1h/2":        "m/0/1'/2",
+		" m/2147483647' ": "m/2147483647'",
+		"44'/0'":          "",
+		"m/x":             "",
+		"m/2147483648":    "",
+		"m/-1":            "",
+	} {
+		p, err := ParsePath(s)
+		if want == "" {
+			if err == nil {
+				t.Errorf("ParsePath(%q) = %v", s, p)
+			}
+			continue
+		}
+		if err != nil || p.String() != want {
+			t.Errorf("ParsePath(%q) = %v, %v", s, p, err)
+		}
+	}
+
+	base := MustParsePath("m/1")
+	a, b := base.Child(2), base.Child(3)
+	if a.String() != "m/1/2" || b.String() != "m/1/3" || base.String() != "m/1" {
+		t.Errorf("children %s %s of %s", a, b, base)
+	}
+}
@@ -0,0 +1,411 @@
+// Package keystore keeps wallets encrypted on disk, one json file per
+// wallet in a directory. The private key is sealed with AES-256-GCM under
+// a key derived from a passphrase with scrypt:
+//
+//	{
+//	  "version": 1,
+//	  "name": "alice",
+//	  "address": "1Mh...",
+//	  "crypto": {
+//	    "kdf": "scrypt",
+//	    "kdfparams": {"n": 32768, "r": 8, "p": 1, "salt": "..."},
+//	    "cipher": "aes-256-gcm",
+//	    "nonce": "...",
+//	    "ciphertext": "..."
+//	  }
+//	}
+//
+// Files are written with mode 0600 in a 0700 directory.
+package keystore
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fabricclient/fabric"
+	"fabricclient/util"
+	"fmt"
+	"golang.org/x/crypto/scrypt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Version is the version of the files written.
+const Version = 1
+
+const (
+	kdfScrypt = "scrypt"
+	cipherGCM = "aes-256-gcm"
+	keyLen    = 32
+	saltLen   = 32
+	fileExt   = ".json"
+)
+
+var (
+	ErrNotFound   = errors.New("keystore: wallet not found")
+	ErrExists     = errors.New("keystore: wallet already exists")
+	ErrPassphrase = errors.New("keystore: wrong passphrase")
+	ErrLocked     = errors.New("keystore: wallet is locked")
+	ErrVersion    = errors.New("keystore: unsupported file version")
+)
+
+var validName = regexp.MustCompile(`^[A-Za-z0-9_-][A-Za-z0-9._-]*$`)
+
+// ScryptParams are the cost parameters of the key derivation.
+type ScryptParams struct {
+	N int `json:"n"`
+	R int `json:"r"`
+	P int `json:"p"`
+}
+
+var (
+	// DefaultScrypt takes about 100ms on a laptop.
+	DefaultScrypt = ScryptParams{N: 1 << 15, R: 8, P: 1}
+	// LightScrypt is for tests and throwaway wallets.
+	LightScrypt = ScryptParams{N: 1 << 12, R: 8, P: 1}
+)
+
+type kdfParams struct {
+	ScryptParams
+	Salt string `json:"salt"`
+}
+
+type cryptoJson struct {
+	KDF        string    `json:"kdf"`
+	KDFParams  kdfParams `json:"kdfparams"`
+	Cipher     string    `json:"cipher"`
+	Nonce      string    `json:"nonce"`
+	CipherText string    `json:"ciphertext"`
+}
+
+type keyFile struct {
+	Version int        `json:"version"`
+	Name    string     `json:"name"`
+	Address string     `json:"address"`
+	Crypto  cryptoJson `json:"crypto"`
+}
+
+// Entry is a wallet of the store, without its key.
+type Entry struct {
+	Name    string `json:"name"`
+	Address string `json:"address"`
+}
+
+type unlocked struct {
+	wallet fabric.Wallet
+	// until is the end of the unlock, zero for no end.
+	until time.Time
+}
+
+// Store is a keystore directory. It is safe for concurrent use.
+type Store struct {
+	dir string
+	// Scrypt is the cost of the wallets written, DefaultScrypt by Open.
+	Scrypt ScryptParams
+	// Network checks the address of the keys imported, util.MainNet by
+	// Open.
+	Network *util.Network
+
+	mu       sync.Mutex
+	unlocked map[string]*unlocked
+	now      func() time.Time
+}
+
+// Open opens the keystore in dir, creating the directory if needed.
+func Open(dir string) (*Store, error) {
+	err := os.MkdirAll(dir, 0700)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Store{
+		dir:      dir,
+		Scrypt:   DefaultScrypt,
+		Network:  util.MainNet,
+		unlocked: map[string]*unlocked{},
+		now:      time.Now,
+	}, nil
+}
+
+// Dir returns the directory of the store.
+func (s *Store) Dir() string {
+	return s.dir
+}
+
+func (s *Store) path(name string) (string, error) {
+	if !validName.MatchString(name) {
+		return "", fmt.Errorf("keystore: invalid wallet name %q", name)
+	}
+	return filepath.Join(s.dir, name+fileExt), nil
+}
+
+func (s *Store) read(name string) (*keyFile, error) {
+	path, err := s.path(name)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	kf := &keyFile{}
+	err = json.Unmarshal(data, kf)
+	if err != nil {
+		return nil, fmt.Errorf("keystore: %s: %v", path, err)
+	}
+	if kf.Version != Version {
+		return nil, ErrVersion
+	}
+
+	return kf, nil
+}
+
+// List returns the wallets of the store sorted by name.
+func (s *Store) List() ([]Entry, error) {
+	files, err := ioutil.ReadDir(s.dir)
+	if err != nil {
+		return nil, err
+	}
+
+	entries := []Entry{}
+	for _, fi := range files {
+		name := strings.TrimSuffix(fi.Name(), fileExt)
+		if fi.IsDir() || name == fi.Name() || !validName.MatchString(name) {
+			continue
+		}
+
+		kf, err := s.read(name)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, Entry{Name: name, Address: kf.Address})
+	}
+
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Name < entries[j].Name })
+
+	return entries, nil
+}
+
+// Address returns the address of the wallet name, without decrypting it.
+func (s *Store) Address(name string) (string, error) {
+	kf, err := s.read(name)
+	if err != nil {
+		return "", err
+	}
+	return kf.Address, nil
+}
+
+func deriveKey(passphrase string, p kdfParams) ([]byte, error) {
+	salt, err := hex.DecodeString(p.Salt)
+	if err != nil {
+		return nil, err
+	}
+	return scrypt.Key([]byte(passphrase), salt, p.N, p.R, p.P, keyLen)
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// Import encrypts w with passphrase and stores it as name.
+func (s *Store) Import(name string, w fabric.Wallet, passphrase string) error {
+	path, err := s.path(name)
+	if err != nil {
+		return err
+	}
+
+	pubKey, err := s.Network.PubKey(w.PrivKey)
+	if err != nil {
+		return err
+	}
+	addr, err := s.Network.Address(pubKey)
+	if err != nil {
+		return err
+	}
+	if w.Address == "" {
+		w.Address = addr
+	} else if w.Address != addr {
+		return errors.New("keystore: the key does not match the address " + w.Address)
+	}
+
+	salt := make([]byte, saltLen)
+	if _, err := rand.Read(salt); err != nil {
+		return err
+	}
+	params := kdfParams{ScryptParams: s.Scrypt, Salt: hex.EncodeToString(salt)}
+
+	key, err := deriveKey(passphrase, params)
+	if err != nil {
+		return err
+	}
+	aead, err := newGCM(key)
+	if err != nil {
+		return err
+	}
+
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return err
+	}
+
+	// the address is authenticated along with the key
+	sealed := aead.Seal(nil, nonce, []byte(w.PrivKey), []byte(w.Address))
+
+	data, err := json.MarshalIndent(&keyFile{
+		Version: Version,
+		Name:    name,
+		Address: w.Address,
+		Crypto: cryptoJson{
+			KDF:        kdfScrypt,
+			KDFParams:  params,
+			Cipher:     cipherGCM,
+			Nonce:      hex.EncodeToString(nonce),
+			CipherText: hex.EncodeToString(sealed),
+		},
+	}, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return writeNew(path, data)
+}
+
+// writeNew writes data to path with mode 0600, ErrExists if path exists.
+func writeNew(path string, data []byte) error {
+	tmp, err := ioutil.TempFile(filepath.Dir(path), ".tmp-")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	_, err = tmp.Write(data)
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return err
+	}
+
+	// a hard link fails if path exists, unlike a rename
+	err = os.Link(tmp.Name(), path)
+	if os.IsExist(err) {
+		return ErrExists
+	}
+	return err
+}
+
+// Export decrypts the wallet name with passphrase.
+func (s *Store) Export(name, passphrase string) (fabric.Wallet, error) {
+	kf, err := s.read(name)
+	if err != nil {
+		return fabric.Wallet{}, err
+	}
+
+	c := kf.Crypto
+	if c.KDF != kdfScrypt || c.Cipher != cipherGCM {
+		return fabric.Wallet{}, fmt.Errorf("keystore: unsupported kdf %s or cipher %s", c.KDF, c.Cipher)
+	}
+
+	key, err := deriveKey(passphrase, c.KDFParams)
+	if err != nil {
+		return fabric.Wallet{}, err
+	}
+	aead, err := newGCM(key)
+	if err != nil {
+		return fabric.Wallet{}, err
+	}
+
+	nonce, err := hex.DecodeString(c.Nonce)
+	if err != nil || len(nonce) != aead.NonceSize() {
+		return fabric.Wallet{}, errors.New("keystore: invalid nonce")
+	}
+	sealed, err := hex.DecodeString(c.CipherText)
+	if err != nil {
+		return fabric.Wallet{}, err
+	}
+
+	privKey, err := aead.Open(nil, nonce, sealed, []byte(kf.Address))
+	if err != nil {
+		return fabric.Wallet{}, ErrPassphrase
+	}
+
+	return fabric.Wallet{Address: kf.Address, PrivKey: string(privKey)}, nil
+}
+
+// Delete removes the wallet name and locks it.
+func (s *Store) Delete(name string) error {
+	path, err := s.path(name)
+	if err != nil {
+		return err
+	}
+
+	s.Lock(name)
+
+	err = os.Remove(path)
+	if os.IsNotExist(err) {
+		return ErrNotFound
+	}
+	return err
+}
+
+// Unlock decrypts the wallet name and keeps it in memory for d, until
+// Lock if d is 0.
+func (s *Store) Unlock(name, passphrase string, d time.Duration) (fabric.Wallet, error) {
+	w, err := s.Export(name, passphrase)
+	if err != nil {
+		return fabric.Wallet{}, err
+	}
+
+	u := &unlocked{wallet: w}
+	if d > 0 {
+		u.until = s.now().Add(d)
+	}
+
+	s.mu.Lock()
+	s.unlocked[name] = u
+	s.mu.Unlock()
+
+	return w, nil
+}
+
+// Lock forgets the key of the wallet name.
+func (s *Store) Lock(name string) {
+	s.mu.Lock()
+	delete(s.unlocked, name)
+	s.mu.Unlock()
+}
+
+// Wallet returns the wallet name if it is unlocked, ErrLocked otherwise.
+func (s *Store) Wallet(name string) (fabric.Wallet, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	u, ok := s.unlocked[name]
+	if !ok {
+		return fabric.Wallet{}, ErrLocked
+	}
+	if !u.until.IsZero() && !s.now().Before(u.until) {
+		delete(s.unlocked, name)
+		return fabric.Wallet{}, ErrLocked
+	}
+
+	return u.wallet, nil
+}
@@ -0,0 +1,179 @@
+package keystore
+
+import (
+	"encoding/json"
+	"errors"
+	"fabricclient/fabric"
+	"fabricclient/fabric/fabrictest/testclient"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newStore(t *testing.T) *Store {
+	t.Helper()
+
+	s, err := Open(filepath.Join(t.TempDir(), "keystore"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.Scrypt = LightScrypt
+	return s
+}
+
+func TestImportExport(t *testing.T) {
+	s := newStore(t)
+	w := testclient.NewWallet()
+
+	if err := s.Import("alice", w, "secret"); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := s.Export("alice", "secret")
+	if err != nil || got != w {
+		t.Fatalf("Export = %+v, %v", got, err)
+	}
+
+	if _, err := s.Export("alice", "guess"); err != ErrPassphrase {
+		t.Errorf("wrong passphrase: %v", err)
+	}
+	if _, err := s.Export("bob", "secret"); err != ErrNotFound {
+		t.Errorf("missing wallet: %v", err)
+	}
+	if err := s.Import("alice", testclient.NewWallet(), "secret"); err != ErrExists {
+		t.Errorf("second import: %v", err)
+	}
+}
+
+func TestFile(t *testing.T) {
+	s := newStore(t)
+	w := testclient.NewWallet()
+	s.Import("alice", w, "secret")
+
+	path := filepath.Join(s.Dir(), "alice.json")
+	fi, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fi.Mode().Perm() != 0600 {
+		t.Errorf("file mode %v", fi.Mode())
+	}
+	if fi, _ := os.Stat(s.Dir()); fi.Mode().Perm() != 0700 {
+		t.Errorf("dir mode %v", fi.Mode())
+	}
+
+	data, _ := ioutil.ReadFile(path)
+	if strings.Contains(string(data), w.PrivKey) {
+		t.Fatal("plaintext key in the file")
+	}
+
+	kf := keyFile{}
+	if err := json.Unmarshal(data, &kf); err != nil {
+		t.Fatal(err)
+	}
+	if kf.Version != Version || kf.Address != w.Address || kf.Crypto.KDF != "scrypt" ||
+		kf.Crypto.Cipher != "aes-256-gcm" || kf.Crypto.KDFParams.N != LightScrypt.N {
+		t.Errorf("file:\n%s", data)
+	}
+
+	// a file moved to another address does not decrypt
+	kf.Address = testclient.NewWallet().Address
+	data, _ = json.Marshal(kf)
+	ioutil.WriteFile(path, data, 0600)
+	if _, err := s.Export("alice", "secret"); err != ErrPassphrase {
+		t.Errorf("tampered address: %v", err)
+	}
+
+	kf.Version = 2
+	data, _ = json.Marshal(kf)
+	ioutil.WriteFile(path, data, 0600)
+	if _, err := s.Export("alice", "secret"); err != ErrVersion {
+		t.Errorf("version 2: %v", err)
+	}
+}
+
+func TestListDelete(t *testing.T) {
+	s := newStore(t)
+	a, b := testclient.NewWallet(), testclient.NewWallet()
+	s.Import("b", b, "x")
+	s.Import("a", a, "y")
+	ioutil.WriteFile(filepath.Join(s.Dir(), "notes.txt"), []byte("hi"), 0600)
+
+	list, err := s.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list) != 2 || list[0] != (Entry{"a", a.Address}) || list[1] != (Entry{"b", b.Address}) {
+		t.Errorf("List = %+v", list)
+	}
+
+	if err := s.Delete("a"); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Delete("a"); err != ErrNotFound {
+		t.Errorf("second delete: %v", err)
+	}
+	if list, _ := s.List(); len(list) != 1 {
+		t.Errorf("List = %+v", list)
+	}
+}
+
+func TestInvalid(t *testing.T) {
+	s := newStore(t)
+	w := testclient.NewWallet()
+
+	for _, name := range []string{"", "../x", "a/b", ".hidden"} {
+		if err := s.Import(name, w, "x"); err == nil {
+			t.Errorf("Import(%q) succeeded", name)
+		}
+	}
+
+	if err := s.Import("bad", fabric.Wallet{PrivKey: "nope"}, "x"); err == nil {
+		t.Error("import of an invalid key succeeded")
+	}
+	if err := s.Import("other", fabric.Wallet{Address: testclient.NewWallet().Address, PrivKey: w.PrivKey}, "x"); err == nil {
+		t.Error("import with the address of another key succeeded")
+	}
+}
+
+func TestUnlock(t *testing.T) {
+	s := newStore(t)
+	w := testclient.NewWallet()
+	s.Import("alice", w, "secret")
+
+	now := time.Now()
+	s.now = func() time.Time { return now }
+
+	if _, err := s.Wallet("alice"); err != ErrLocked {
+		t.Errorf("locked wallet: %v", err)
+	}
+	if _, err := s.Unlock("alice", "guess", time.Minute); !errors.Is(err, ErrPassphrase) {
+		t.Errorf("unlock with a wrong passphrase: %v", err)
+	}
+
+	if _, err := s.Unlock("alice", "secret", time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := s.Wallet("alice"); err != nil || got != w {
+		t.Errorf("Wallet = %+v, %v", got, err)
+	}
+
+	now = now.Add(time.Minute)
+	if _, err := s.Wallet("alice"); err != ErrLocked {
+		t.Errorf("after the unlock: %v", err)
+	}
+
+	s.Unlock("alice", "secret", 0)
+	now = now.Add(time.Hour)
+	if _, err := s.Wallet("alice"); err != nil {
+		t.Errorf("unlock without end: %v", err)
+	}
+
+	s.Lock("alice")
+	if _, err := s.Wallet("alice"); err != ErrLocked {
+		t.Errorf("after Lock: %v", err)
+	}
+}
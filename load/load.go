@@ -0,0 +1,336 @@
+// Package load generates transfer, query or issue load against an ocean
+// gateway and reports throughput, errors and latency percentiles.
+package load
+
+import (
+	"context"
+	"errors"
+	"fabricclient/audit"
+	"fabricclient/fabric"
+	"fabricclient/hdwallet"
+	"fabricclient/logger"
+	"sync"
+	"time"
+)
+
+type Workload string
+
+const (
+	// Transfer moves Amount around a ring of funded wallets.
+	Transfer Workload = "transfer"
+	// Query queries the balance of the wallets.
+	Query Workload = "query"
+	// Issue issues a new token from the wallets.
+	Issue Workload = "issue"
+)
+
+const (
+	DefaultTimeout = 30 * time.Second
+
+	defaultAmount = "1"
+	defaultFund   = "10"
+
+	issueName  = "LOAD"
+	issueTotal = "1000"
+)
+
+// Config describes a load run. The run stops after Duration or once Count
+// requests were measured, whichever comes first.
+type Config struct {
+	Workload Workload
+	// Concurrency is the maximum number of requests in flight.
+	Concurrency int
+	// TPS is the target request rate. Requests are sent on schedule
+	// whatever the latency of the previous ones (open loop) and their
+	// latency counts from the scheduled time. 0 sends back to back on
+	// Concurrency workers (closed loop).
+	TPS float64
+	// Duration of the measured phase, after Warmup.
+	Duration time.Duration
+	// Count is the number of measured requests, 0 for no limit.
+	Count int
+	// Warmup runs load without measuring it.
+	Warmup time.Duration
+	// Timeout bounds a request, DefaultTimeout if 0.
+	Timeout time.Duration
+
+	// Wallets is the number of wallets used, Concurrency if 0. A wallet
+	// sends one transfer at a time.
+	Wallets int
+	// HD, if set, derives wallet i at HDPath/i, hdwallet.DefaultPath if
+	// nil, so that a later run or a Sweep finds the same wallets. They are
+	// random otherwise.
+	HD     *hdwallet.HD
+	HDPath hdwallet.Path
+
+	// TokenID and Funder are the token transferred and the wallet funding
+	// the ring with Fund units per wallet, Transfer only.
+	TokenID string
+	Funder  fabric.Wallet
+	Fund    string
+	// Amount is the amount of a transfer, 1 if empty.
+	Amount string
+	// WaitCommit includes the commit of a transfer in its latency.
+	WaitCommit bool
+
+	// Audit, if set, records the ring and every transfer of the run,
+	// Transfer only.
+	Audit *audit.Checker
+}
+
+// Generator runs a Config against a FabricClient.
+type Generator struct {
+	f   *fabric.FabricClient
+	cfg Config
+
+	wallets []*fabric.Wallet
+	// free holds the indexes of the wallets without a transfer in flight.
+	free chan int
+}
+
+func New(f *fabric.FabricClient, cfg Config) (*Generator, error) {
+	switch cfg.Workload {
+	case Transfer:
+		if cfg.TokenID == "" || cfg.Funder.PrivKey == "" {
+			return nil, errors.New("load: transfer needs a token and a funder wallet")
+		}
+	case Query, Issue:
+	default:
+		return nil, errors.New("load: unknown workload " + string(cfg.Workload))
+	}
+
+	if cfg.Concurrency <= 0 {
+		return nil, errors.New("load: concurrency must be positive")
+	}
+	if cfg.Duration <= 0 && cfg.Count <= 0 {
+		return nil, errors.New("load: needs a duration or a count")
+	}
+	if cfg.TPS < 0 {
+		return nil, errors.New("load: negative tps")
+	}
+
+	if cfg.Wallets <= 0 {
+		cfg.Wallets = cfg.Concurrency
+	}
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = DefaultTimeout
+	}
+	if cfg.Amount == "" {
+		cfg.Amount = defaultAmount
+	}
+	if cfg.Fund == "" {
+		cfg.Fund = defaultFund
+	}
+	if cfg.HD != nil && cfg.HDPath == nil {
+		cfg.HDPath = hdwallet.MustParsePath(hdwallet.DefaultPath)
+	}
+
+	return &Generator{f: f, cfg: cfg}, nil
+}
+
+// setup creates the wallets and funds them for a transfer load.
+func (g *Generator) setup(ctx context.Context) error {
+	if g.cfg.HD != nil {
+		wallets, err := g.cfg.HD.Wallets(g.cfg.HDPath, 0, g.cfg.Wallets)
+		if err != nil {
+			return err
+		}
+		g.wallets = wallets
+	} else {
+		for i := 0; i < g.cfg.Wallets; i++ {
+			w, err := g.f.NewWallet()
+			if err != nil {
+				return err
+			}
+			g.wallets = append(g.wallets, w)
+		}
+	}
+
+	g.free = make(chan int, g.cfg.Wallets)
+	for i := range g.wallets {
+		g.free <- i
+	}
+
+	if g.cfg.Workload != Transfer {
+		return nil
+	}
+
+	if g.cfg.Audit != nil {
+		addrs := []string{g.cfg.Funder.Address}
+		for _, w := range g.wallets {
+			addrs = append(addrs, w.Address)
+		}
+		g.cfg.Audit.Track(g.cfg.TokenID, addrs...)
+
+		err := g.cfg.Audit.Snapshot(ctx, g.f, 0)
+		if err != nil {
+			return err
+		}
+	}
+
+	logger.Info("funding", len(g.wallets), "wallets")
+
+	txIDs := []string{}
+	for _, w := range g.wallets {
+		txID, err := g.f.TransferContext(ctx, g.cfg.TokenID, g.cfg.Funder.Address, g.cfg.Funder.PrivKey, w.Address, g.cfg.Fund)
+		g.record(g.cfg.Funder.Address, w.Address, g.cfg.Fund, txID, err)
+		if err != nil {
+			logger.Error(err)
+			return err
+		}
+		txIDs = append(txIDs, txID)
+	}
+
+	for _, txID := range txIDs {
+		wctx, cancel := context.WithTimeout(ctx, g.cfg.Timeout)
+		_, err := g.f.WaitForTx(wctx, txID)
+		cancel()
+		if err != nil {
+			logger.Error(err)
+			return err
+		}
+	}
+
+	return nil
+}
+
+// record passes a transfer to the audit, if any.
+func (g *Generator) record(from, to, amount, txID string, err error) {
+	if g.cfg.Audit == nil {
+		return
+	}
+
+	if aerr := g.cfg.Audit.Transfer(g.cfg.TokenID, from, to, amount, txID, err); aerr != nil {
+		logger.Error(aerr)
+	}
+}
+
+// request sends request n of the workload.
+func (g *Generator) request(ctx context.Context, n int) error {
+	ctx, cancel := context.WithTimeout(ctx, g.cfg.Timeout)
+	defer cancel()
+
+	switch g.cfg.Workload {
+	case Query:
+		_, err := g.f.QueryBalanceContext(ctx, g.wallets[n%len(g.wallets)].Address)
+		return err
+
+	case Issue:
+		w := g.wallets[n%len(g.wallets)]
+		_, err := g.f.IssueTokenContext(ctx, w.Address, w.PrivKey, issueName, issueTotal)
+		return err
+	}
+
+	var i int
+	select {
+	case i = <-g.free:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	defer func() { g.free <- i }()
+
+	from, to := g.wallets[i], g.wallets[(i+1)%len(g.wallets)]
+
+	txID, err := g.f.TransferContext(ctx, g.cfg.TokenID, from.Address, from.PrivKey, to.Address, g.cfg.Amount)
+	g.record(from.Address, to.Address, g.cfg.Amount, txID, err)
+	if err != nil || !g.cfg.WaitCommit {
+		return err
+	}
+
+	_, err = g.f.WaitForTx(ctx, txID)
+	return err
+}
+
+// Run sets up the wallets and runs the load. It stops early when ctx is
+// done and reports the requests sent so far.
+func (g *Generator) Run(ctx context.Context) (*Report, error) {
+	err := g.setup(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	cfg := g.cfg
+	rec := &recorder{errors: map[string]int{}}
+
+	start := time.Now()
+	measureFrom := start.Add(cfg.Warmup)
+	var end time.Time
+	if cfg.Duration > 0 {
+		end = measureFrom.Add(cfg.Duration)
+	}
+
+	logger.Info("load start", cfg.Workload, "concurrency", cfg.Concurrency, "tps", cfg.TPS)
+
+	sem := make(chan struct{}, cfg.Concurrency)
+	var wg sync.WaitGroup
+	measured := 0
+
+dispatch:
+	for n := 0; ; n++ {
+		at := time.Now()
+		if cfg.TPS > 0 {
+			at = start.Add(time.Duration(float64(n) / cfg.TPS * float64(time.Second)))
+			if !sleepUntil(ctx, at) {
+				break
+			}
+		}
+
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+			break dispatch
+		}
+
+		if cfg.TPS == 0 {
+			at = time.Now()
+		}
+		if !end.IsZero() && !at.Before(end) {
+			<-sem
+			break
+		}
+
+		measure := !at.Before(measureFrom)
+		if measure {
+			if cfg.Count > 0 && measured == cfg.Count {
+				<-sem
+				break
+			}
+			measured++
+		}
+
+		wg.Add(1)
+		go func(n int, at time.Time, measure bool) {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			err := g.request(ctx, n)
+			if measure {
+				rec.add(time.Since(at), err)
+			}
+		}(n, at, measure)
+	}
+
+	wg.Wait()
+
+	r := rec.report(cfg.Workload, measureFrom)
+	logger.Info("load end", cfg.Workload, r.Total, "requests", r.Throughput, "tx/s")
+
+	return r, nil
+}
+
+func sleepUntil(ctx context.Context, at time.Time) bool {
+	d := time.Until(at)
+	if d <= 0 {
+		return ctx.Err() == nil
+	}
+
+	t := time.NewTimer(d)
+	defer t.Stop()
+
+	select {
+	case <-t.C:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
@@ -0,0 +1,322 @@
+package load
+
+import (
+	"context"
+	"fabricclient/audit"
+	"fabricclient/fabric"
+	"fabricclient/fabric/fabrictest"
+	"fabricclient/fabric/fabrictest/testclient"
+	"fabricclient/hdwallet"
+	"fabricclient/util"
+	"math/big"
+	"net/http"
+	"testing"
+	"time"
+)
+
+// funder issues a token of total units to a new wallet.
+func funder(t *testing.T, f *fabric.FabricClient, total string) (fabric.Wallet, string) {
+	t.Helper()
+
+	w := testclient.NewWallet()
+	tokenID, err := f.IssueToken(w.Address, w.PrivKey, "OCE", total)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return w, tokenID
+}
+
+func TestTransferLoad(t *testing.T) {
+	s := fabrictest.NewServer()
+	defer s.Close()
+
+	f := testclient.New(t, s)
+	w, tokenID := funder(t, f, "1000")
+
+	g, err := New(f, Config{
+		Workload:    Transfer,
+		Concurrency: 4,
+		Count:       40,
+		Wallets:     6,
+		TokenID:     tokenID,
+		Funder:      w,
+		WaitCommit:  true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r, err := g.Run(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if r.Total != 40 || r.Success != 40 || r.Failed != 0 {
+		t.Errorf("report = %+v", r)
+	}
+	if r.P50 <= 0 || r.P50 > r.P90 || r.P90 > r.P99 || r.P99 > r.Max || r.Throughput <= 0 {
+		t.Errorf("latencies = %v %v %v %v, throughput %v", r.P50, r.P90, r.P99, r.Max, r.Throughput)
+	}
+
+	// the ring keeps the funded units
+	sum := new(big.Int)
+	for _, rw := range g.wallets {
+		n, _ := new(big.Int).SetString(s.Balance(rw.Address, tokenID), 10)
+		sum.Add(sum, n)
+	}
+	if sum.Int64() != 60 || s.Balance(w.Address, tokenID) != "940" {
+		t.Errorf("ring holds %v, funder %s", sum, s.Balance(w.Address, tokenID))
+	}
+
+	// funding and the measured transfers
+	if s.TxCount() != 6+40 {
+		t.Errorf("%d txs on the ledger", s.TxCount())
+	}
+}
+
+func TestOpenLoop(t *testing.T) {
+	s := fabrictest.NewServer()
+	defer s.Close()
+
+	// slower than the schedule allows in a closed loop of 2
+	s.SetLatency(20 * time.Millisecond)
+
+	g, err := New(testclient.New(t, s), Config{
+		Workload:    Query,
+		Concurrency: 20,
+		TPS:         200,
+		Duration:    300 * time.Millisecond,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r, err := g.Run(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if r.Total < 45 || r.Total > 61 || r.Failed != 0 {
+		t.Errorf("%d requests in 300ms at 200 tps, %d failed", r.Total, r.Failed)
+	}
+}
+
+func TestOpenLoopLatencyFromSchedule(t *testing.T) {
+	s := fabrictest.NewServer()
+	defer s.Close()
+	s.SetLatency(20 * time.Millisecond)
+
+	// one request in flight at a time, the schedule falls behind
+	g, _ := New(testclient.New(t, s), Config{Workload: Query, Concurrency: 1, TPS: 200, Count: 10})
+
+	r, err := g.Run(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the last request was due at 45ms but sent around 180ms
+	if r.Max < 100*time.Millisecond {
+		t.Errorf("max latency %v, the queueing delay is not counted", r.Max)
+	}
+}
+
+func TestWarmup(t *testing.T) {
+	s := fabrictest.NewServer()
+	defer s.Close()
+
+	f := testclient.New(t, s)
+	w, tokenID := funder(t, f, "1000")
+
+	g, _ := New(f, Config{
+		Workload:    Transfer,
+		Concurrency: 5,
+		TPS:         100,
+		Warmup:      200 * time.Millisecond,
+		Count:       10,
+		TokenID:     tokenID,
+		Funder:      w,
+	})
+
+	r, err := g.Run(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if r.Total != 10 || r.Success != 10 {
+		t.Errorf("report = %+v", r)
+	}
+	// funding, about 20 warmup and the measured transfers
+	if s.TxCount() < 5+15+10 {
+		t.Errorf("%d transfers, warmup did not run", s.TxCount())
+	}
+}
+
+func TestErrorKinds(t *testing.T) {
+	s := fabrictest.NewServer()
+	defer s.Close()
+
+	s.Inject(fabrictest.QueryBalance, fabrictest.Fault{StatusCode: http.StatusServiceUnavailable, Times: 3})
+
+	f := testclient.New(t, s)
+	g, _ := New(f, Config{Workload: Query, Concurrency: 1, Count: 10})
+
+	r, err := g.Run(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Failed != 3 || r.Errors["http 503"] != 3 || r.Success != 7 {
+		t.Errorf("report = %+v", r)
+	}
+
+	w, tokenID := funder(t, f, "1000")
+	g, _ = New(f, Config{Workload: Transfer, Concurrency: 2, Count: 4, TokenID: tokenID, Funder: w, Fund: "1", Amount: "5"})
+
+	r, err = g.Run(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Errors["insufficient balance"] != 4 {
+		t.Errorf("errors = %v", r.Errors)
+	}
+}
+
+func TestRunCanceled(t *testing.T) {
+	s := fabrictest.NewServer()
+	defer s.Close()
+
+	g, _ := New(testclient.New(t, s), Config{Workload: Query, Concurrency: 2, TPS: 50, Duration: time.Hour})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	r, err := g.Run(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if time.Since(start) > time.Second || r.Total == 0 {
+		t.Errorf("canceled run took %v, %d requests", time.Since(start), r.Total)
+	}
+}
+
+func TestNewInvalid(t *testing.T) {
+	for _, cfg := range []Config{
+		{Workload: "mint", Concurrency: 1, Count: 1},
+		{Workload: Query, Count: 1},
+		{Workload: Query, Concurrency: 1},
+		{Workload: Query, Concurrency: 1, Count: 1, TPS: -1},
+		{Workload: Transfer, Concurrency: 1, Count: 1},
+	} {
+		if _, err := New(nil, cfg); err == nil {
+			t.Errorf("New(%+v) succeeded", cfg)
+		}
+	}
+}
+
+func TestPercentile(t *testing.T) {
+	sorted := []time.Duration{}
+	for i := 1; i <= 100; i++ {
+		sorted = append(sorted, time.Duration(i))
+	}
+
+	for p, want := range map[float64]time.Duration{50: 50, 90: 90, 99: 99, 100: 100, 0: 1} {
+		if got := percentile(sorted, p); got != want {
+			t.Errorf("percentile(%v) = %v, want %v", p, got, want)
+		}
+	}
+
+	if got := percentile(sorted[:1], 99); got != 1 {
+		t.Errorf("percentile of one = %v", got)
+	}
+}
+
+func TestIssueLoad(t *testing.T) {
+	s := fabrictest.NewServer()
+	defer s.Close()
+
+	g, _ := New(testclient.New(t, s), Config{Workload: Issue, Concurrency: 2, Count: 5})
+
+	r, err := g.Run(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Total != 5 || r.Success != 5 {
+		t.Errorf("report = %+v", r)
+	}
+}
+
+func TestTransferAudit(t *testing.T) {
+	s := fabrictest.NewServer()
+	defer s.Close()
+
+	f := testclient.New(t, s)
+	w, tokenID := funder(t, f, "1000")
+
+	checker := audit.NewChecker()
+	g, _ := New(f, Config{
+		Workload:    Transfer,
+		Concurrency: 4,
+		Count:       20,
+		TokenID:     tokenID,
+		Funder:      w,
+		Audit:       checker,
+	})
+
+	if _, err := g.Run(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	r, err := checker.Verify(context.Background(), f, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !r.OK() || r.Wallets != 5 || r.Transfers != 4+20 {
+		t.Errorf("audit:\n%s", r)
+	}
+}
+
+func TestHDSweep(t *testing.T) {
+	s := fabrictest.NewServer()
+	defer s.Close()
+
+	f := testclient.New(t, s)
+	w, tokenID := funder(t, f, "1000")
+
+	hd, err := hdwallet.New("abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon about", "", util.MainNet)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	g, _ := New(f, Config{
+		Workload:    Transfer,
+		Concurrency: 2,
+		Count:       10,
+		Wallets:     3,
+		TokenID:     tokenID,
+		Funder:      w,
+		HD:          hd,
+	})
+	if _, err := g.Run(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	// a later run finds the ring from the seed alone
+	wallets, _ := hd.Wallets(hdwallet.MustParsePath(hdwallet.DefaultPath), 0, 4)
+	for i, rw := range g.wallets {
+		if *rw != *wallets[i] {
+			t.Fatalf("wallet %d is %s, derived %s", i, rw.Address, wallets[i].Address)
+		}
+	}
+
+	swept, err := Sweep(context.Background(), f, wallets, tokenID, w.Address)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(swept) != 3 || s.Balance(w.Address, tokenID) != "1000" {
+		t.Errorf("swept %+v, funder has %s", swept, s.Balance(w.Address, tokenID))
+	}
+
+	if swept, _ := Sweep(context.Background(), f, wallets, "", w.Address); len(swept) != 0 {
+		t.Errorf("second sweep %+v", swept)
+	}
+}
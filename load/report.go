@@ -0,0 +1,168 @@
+package load
+
+import (
+	"context"
+	"errors"
+	"fabricclient/fabric"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Report is the outcome of the measured phase of a run.
+type Report struct {
+	Workload Workload      `json:"workload"`
+	Elapsed  time.Duration `json:"elapsed"`
+
+	Total   int `json:"total"`
+	Success int `json:"success"`
+	Failed  int `json:"failed"`
+	// Errors counts the failures by kind, see ErrorKind.
+	Errors map[string]int `json:"errors"`
+
+	// Throughput is the number of successful requests per second.
+	Throughput float64 `json:"throughput"`
+
+	// Latency percentiles of all the requests, failed ones included.
+	P50  time.Duration `json:"p50"`
+	P90  time.Duration `json:"p90"`
+	P99  time.Duration `json:"p99"`
+	Max  time.Duration `json:"max"`
+	Mean time.Duration `json:"mean"`
+}
+
+func (r *Report) String() string {
+	b := &strings.Builder{}
+
+	fmt.Fprintf(b, "workload    %s\n", r.Workload)
+	fmt.Fprintf(b, "elapsed     %v\n", r.Elapsed.Round(time.Millisecond))
+	fmt.Fprintf(b, "requests    %d, %d ok, %d failed\n", r.Total, r.Success, r.Failed)
+	fmt.Fprintf(b, "throughput  %.1f tx/s\n", r.Throughput)
+	fmt.Fprintf(b, "latency     p50 %v  p90 %v  p99 %v  max %v  mean %v\n",
+		r.P50.Round(time.Microsecond), r.P90.Round(time.Microsecond), r.P99.Round(time.Microsecond),
+		r.Max.Round(time.Microsecond), r.Mean.Round(time.Microsecond))
+
+	kinds := []string{}
+	for k := range r.Errors {
+		kinds = append(kinds, k)
+	}
+	sort.Strings(kinds)
+
+	for _, k := range kinds {
+		fmt.Fprintf(b, "error       %s: %d\n", k, r.Errors[k])
+	}
+
+	return b.String()
+}
+
+// ErrorKind classifies a request error for the report.
+func ErrorKind(err error) string {
+	var apiErr *fabric.APIError
+
+	switch {
+	case errors.Is(err, context.DeadlineExceeded):
+		return "timeout"
+	case errors.Is(err, context.Canceled):
+		return "canceled"
+	case errors.Is(err, fabric.ErrInsufficientBalance):
+		return "insufficient balance"
+	case errors.Is(err, fabric.ErrNotFound):
+		return "not found"
+	case errors.Is(err, fabric.ErrDuplicate):
+		return "duplicate"
+	case errors.Is(err, fabric.ErrTxInvalid):
+		return "invalid tx"
+	case errors.Is(err, fabric.ErrRejected):
+		return "rejected"
+	case errors.Is(err, fabric.ErrTransport):
+		return "transport"
+	case errors.Is(err, fabric.ErrBadResponse):
+		return "bad response"
+	case errors.As(err, &apiErr) && apiErr.StatusCode != 0:
+		return "http " + strconv.Itoa(apiErr.StatusCode)
+	}
+
+	return "other"
+}
+
+type recorder struct {
+	mu        sync.Mutex
+	latencies []time.Duration
+	errors    map[string]int
+	success   int
+	last      time.Time
+}
+
+func (rec *recorder) add(latency time.Duration, err error) {
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+
+	rec.latencies = append(rec.latencies, latency)
+	rec.last = time.Now()
+
+	if err != nil {
+		rec.errors[ErrorKind(err)]++
+	} else {
+		rec.success++
+	}
+}
+
+// percentile returns the nearest rank percentile p of sorted.
+func percentile(sorted []time.Duration, p float64) time.Duration {
+	if len(sorted) == 0 {
+		return 0
+	}
+
+	i := int(math.Ceil(float64(len(sorted))*p/100)) - 1
+	if i < 0 {
+		i = 0
+	}
+	if i >= len(sorted) {
+		i = len(sorted) - 1
+	}
+
+	return sorted[i]
+}
+
+// report sums up the requests measured since from.
+func (rec *recorder) report(w Workload, from time.Time) *Report {
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+
+	r := &Report{
+		Workload: w,
+		Total:    len(rec.latencies),
+		Success:  rec.success,
+		Failed:   len(rec.latencies) - rec.success,
+		Errors:   rec.errors,
+	}
+
+	if r.Total == 0 {
+		return r
+	}
+
+	r.Elapsed = rec.last.Sub(from)
+	if r.Elapsed > 0 {
+		r.Throughput = float64(r.Success) / r.Elapsed.Seconds()
+	}
+
+	sorted := append([]time.Duration{}, rec.latencies...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+
+	var sum time.Duration
+	for _, d := range sorted {
+		sum += d
+	}
+
+	r.P50 = percentile(sorted, 50)
+	r.P90 = percentile(sorted, 90)
+	r.P99 = percentile(sorted, 99)
+	r.Max = sorted[len(sorted)-1]
+	r.Mean = sum / time.Duration(len(sorted))
+
+	return r
+}
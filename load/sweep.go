@@ -0,0 +1,63 @@
+package load
+
+import (
+	"context"
+	"fabricclient/fabric"
+	"fabricclient/logger"
+	"math/big"
+	"sort"
+)
+
+// Swept is a balance moved by Sweep.
+type Swept struct {
+	Address string `json:"address"`
+	TokenID string `json:"tokenID"`
+	Amount  string `json:"amount"`
+	TxID    string `json:"txID"`
+}
+
+// Sweep moves the balances of wallets, of tokenID only if not empty, to
+// the address to and waits for their commit. It recovers the funds left in
+// the ring of a run with Config.HD. The transfers sent before an error are
+// returned with it.
+func Sweep(ctx context.Context, f *fabric.FabricClient, wallets []*fabric.Wallet, tokenID, to string) ([]Swept, error) {
+	swept := []Swept{}
+	for _, w := range wallets {
+		if w.Address == to {
+			continue
+		}
+
+		b, err := f.QueryBalanceContext(ctx, w.Address)
+		if err != nil {
+			return swept, err
+		}
+
+		tokens := []string{}
+		for id, amount := range b.Tokens {
+			n, ok := new(big.Int).SetString(amount, 10)
+			if ok && n.Sign() > 0 && (tokenID == "" || id == tokenID) {
+				tokens = append(tokens, id)
+			}
+		}
+		sort.Strings(tokens)
+
+		for _, id := range tokens {
+			txID, err := f.TransferContext(ctx, id, w.Address, w.PrivKey, to, b.Tokens[id])
+			if err != nil {
+				return swept, err
+			}
+			swept = append(swept, Swept{Address: w.Address, TokenID: id, Amount: b.Tokens[id], TxID: txID})
+		}
+	}
+
+	logger.Info("sweep", len(swept), "balances to", to)
+
+	for _, s := range swept {
+		_, err := f.WaitForTx(ctx, s.TxID)
+		if err != nil {
+			return swept, err
+		}
+	}
+
+	return swept, nil
+}
@@ -1,20 +1,40 @@
 package main
 
 import (
+	"errors"
 	"fabricclient/fabric"
+	"fabricclient/hdwallet"
 	"fabricclient/logger"
+	"fabricclient/selftest"
+	"fabricclient/util"
+	"flag"
+	"fmt"
 	"gopkg.in/ini.v1"
 	"log"
-	//"os"
-	"sync"
+	"os"
+	"strconv"
 )
 
 const (
 	logDirPath         = "log"
 	logFilePath        = "log/fabric.log"
 	FabricConfFilePath = "conf/my.ini"
+	DefaultKeystoreDir = "conf/keystore"
 )
 
+var (
+	configFile = flag.String("config", FabricConfFilePath, "client configuration")
+	endpoint   = flag.String("endpoint", "", "gateway ip:port, comma separated, FabricServerIpPort of -config if empty")
+	verbose    = flag.Bool("v", false, "log requests of the commands")
+
+	keystoreDir = flag.String("keystore", DefaultKeystoreDir, "directory of the encrypted wallets")
+)
+
+func init() {
+	flag.StringVar(&outputFormat, "o", formatTable, "output of the commands, table or json")
+	flag.Usage = usage
+}
+
 func initLogger() error {
 	/*	st, err := os.Stat(logDirPath)
 		if err == nil {
@@ -38,34 +58,158 @@ func initLogger() error {
 	logger.SetHandlers(logger.Console)
 	//defer logger.Close()
 	logger.SetFlags(log.Ldate | log.Ltime | log.Lshortfile)
-	logger.SetLevel(logger.INFO)
+	// commands print their result, only errors are logged unless -v
+	if flag.NArg() > 0 && !*verbose {
+		logger.SetLevel(logger.ERROR)
+	} else {
+		logger.SetLevel(logger.INFO)
+	}
 
 	return nil
 }
 
+func clientOptions(cfg *ini.File) ([]fabric.Option, error) {
+	opts := []fabric.Option{}
+
+	if cfg.Section("").Key("FabricBalancer").String() == "leastlatency" {
+		opts = append(opts, fabric.WithBalancer(fabric.LeastLatency))
+	}
+
+	sec := cfg.Section("rest")
+	if sec.Key("URL").String() != "" {
+		opts = append(opts, fabric.WithRestURL(sec.Key("URL").String()))
+	}
+	if peers := sec.Key("Peers").Strings(","); len(peers) > 0 {
+		opts = append(opts, fabric.WithPeers(peers...))
+	}
+	if sec.Key("Username").String() != "" {
+		opts = append(opts, fabric.WithCredentials(fabric.Credentials{
+			Username: sec.Key("Username").String(),
+			OrgName:  sec.Key("OrgName").String(),
+		}))
+	}
+
+	sec = cfg.Section("tls")
+	if sec.Key("Enable").MustBool(false) {
+		c := &fabric.TLSConfig{
+			CAFile:     sec.Key("CAFile").String(),
+			CertFile:   sec.Key("CertFile").String(),
+			KeyFile:    sec.Key("KeyFile").String(),
+			ServerName: sec.Key("ServerName").String(),
+			MinVersion: sec.Key("MinVersion").String(),
+		}
+
+		tlsConfig, err := c.Build()
+		if err != nil {
+			return nil, err
+		}
+
+		opts = append(opts, fabric.WithTLS(tlsConfig))
+	}
+
+	return opts, nil
+}
+
+// networkOf returns the network of the [network] section, mainnet with
+// p2pkh addresses by default.
+func networkOf(cfg *ini.File) (*util.Network, error) {
+	sec := cfg.Section("network")
+	name := sec.Key("Name").String()
+	t := util.AddressType(sec.Key("AddressType").String())
+
+	if name != "custom" {
+		return util.NetworkByName(name, t)
+	}
+
+	ids := []byte{}
+	for _, k := range []string{"PubKeyHashAddrID", "PrivateKeyID"} {
+		id, err := strconv.ParseUint(sec.Key(k).String(), 0, 8)
+		if err != nil {
+			return nil, fmt.Errorf("network %s: %v", k, err)
+		}
+		ids = append(ids, byte(id))
+	}
+
+	return util.CustomNetwork(ids[0], ids[1], t)
+}
+
+// runSelftest runs the self test, keeping its wallets in the keystore.
+func runSelftest(f *fabric.FabricClient) error {
+	ks, err := openKeystore(f.Network())
+	if err != nil {
+		return err
+	}
+
+	passphrase, err := readPassphrase("keystore passphrase: ")
+	if err != nil {
+		return err
+	}
+
+	r := selftest.NewRunner(f)
+	r.Keystore, r.Passphrase = ks, passphrase
+
+	// a mnemonic makes the ring of the concurrent test recoverable
+	if mnemonic, ok := os.LookupEnv(mnemonicEnv); ok {
+		r.HD, err = hdwallet.New(mnemonic, os.Getenv(mnemonicPasswordEnv), f.Network())
+		if err != nil {
+			return err
+		}
+	}
+
+	return r.Run()
+}
+
 func main() {
+	flag.Parse()
+
 	err := initLogger()
 	if err != nil {
 		log.Fatalln(err)
 	}
 
-	cfg, err := ini.Load(FabricConfFilePath)
+	if outputFormat != formatTable && outputFormat != formatJson {
+		logger.Error(errors.New("-o must be table or json"))
+		os.Exit(2)
+	}
+
+	cfg, err := ini.Load(*configFile)
 	if err != nil {
 		logger.Error(err)
-		return
+		os.Exit(1)
 	}
 
 	ipport := cfg.Section("").Key("FabricServerIpPort").String()
+	if *endpoint != "" {
+		ipport = *endpoint
+	}
+
+	network, err := networkOf(cfg)
+	if err != nil {
+		logger.Error(err)
+		os.Exit(1)
+	}
 
-	var wg sync.WaitGroup
-	wg.Add(1)
+	opts, err := clientOptions(cfg)
+	if err != nil {
+		logger.Error(err)
+		os.Exit(1)
+	}
+	opts = append(opts, fabric.WithNetwork(network))
 
-	_, err = fabric.NewFabricClient(ipport, &wg)
+	f, err := fabric.NewFabricClient(ipport, opts...)
 	if err != nil {
 		logger.Error(err)
-		return
+		os.Exit(1)
 	}
 
-	wg.Wait()
+	if flag.NArg() > 0 {
+		err = runCommand(f, flag.Args())
+	} else {
+		err = runSelftest(f)
+	}
+	if err != nil {
+		logger.Error(err)
+		os.Exit(1)
+	}
 	logger.Debug("fabric client exit")
 }
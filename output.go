@@ -0,0 +1,63 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+)
+
+// output formats of the -o flag
+const (
+	formatTable = "table"
+	formatJson  = "json"
+)
+
+var (
+	// outputFormat is set by the -o flag.
+	outputFormat = formatTable
+	// stdout receives the output of the commands.
+	stdout io.Writer = os.Stdout
+)
+
+// table is the output of a command in the table format.
+type table struct {
+	header []string
+	rows   [][]string
+}
+
+func (t *table) add(row ...string) {
+	t.rows = append(t.rows, row)
+}
+
+// fields is a table of name and value rows.
+func fields(kv ...string) *table {
+	t := &table{}
+	for i := 0; i+1 < len(kv); i += 2 {
+		t.add(kv[i], kv[i+1])
+	}
+	return t
+}
+
+// show prints v as indented json with -o json, else t aligned in columns.
+func show(v interface{}, t *table) error {
+	if outputFormat == formatJson {
+		data, err := json.MarshalIndent(v, "", "  ")
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintf(stdout, "%s\n", data)
+		return err
+	}
+
+	w := tabwriter.NewWriter(stdout, 0, 4, 2, ' ', 0)
+	if len(t.header) > 0 {
+		fmt.Fprintln(w, strings.Join(t.header, "\t"))
+	}
+	for _, row := range t.rows {
+		fmt.Fprintln(w, strings.Join(row, "\t"))
+	}
+	return w.Flush()
+}
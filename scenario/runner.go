@@ -0,0 +1,341 @@
+package scenario
+
+import (
+	"context"
+	"errors"
+	"fabricclient/fabric"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+const DefaultTimeout = 30 * time.Second
+
+type Status string
+
+const (
+	Pass Status = "PASS"
+	Fail Status = "FAIL"
+	// Skip marks the steps after a failed one.
+	Skip Status = "SKIP"
+)
+
+// StepResult is the outcome of a step.
+type StepResult struct {
+	Index   int
+	Name    string
+	Status  Status
+	Elapsed time.Duration
+	// Err is why the step failed.
+	Err error
+}
+
+// Report is the outcome of a scenario.
+type Report struct {
+	Scenario string
+	Steps    []StepResult
+}
+
+// Passed reports whether every step passed.
+func (r *Report) Passed() bool {
+	for _, s := range r.Steps {
+		if s.Status != Pass {
+			return false
+		}
+	}
+	return true
+}
+
+func (r *Report) String() string {
+	b := &strings.Builder{}
+
+	for _, s := range r.Steps {
+		fmt.Fprintf(b, "%s %3d %s", s.Status, s.Index, s.Name)
+		if s.Status != Skip {
+			fmt.Fprintf(b, " (%v)", s.Elapsed.Round(time.Millisecond))
+		}
+		if s.Err != nil {
+			fmt.Fprintf(b, ": %v", s.Err)
+		}
+		b.WriteString("\n")
+	}
+
+	result := Pass
+	if !r.Passed() {
+		result = Fail
+	}
+	fmt.Fprintf(b, "%s %s\n", result, r.Scenario)
+
+	return b.String()
+}
+
+// Runner runs scenarios against a FabricClient.
+type Runner struct {
+	f *fabric.FabricClient
+}
+
+func NewRunner(f *fabric.FabricClient) *Runner {
+	return &Runner{f: f}
+}
+
+// run is the state of one scenario run.
+type run struct {
+	f       *fabric.FabricClient
+	wallets map[string]*fabric.Wallet
+	vars    map[string]string
+}
+
+// Run runs the steps of sc in order. The steps after a failed one are
+// skipped.
+func (r *Runner) Run(ctx context.Context, sc *Scenario) *Report {
+	st := &run{
+		f:       r.f,
+		wallets: map[string]*fabric.Wallet{},
+		vars:    map[string]string{},
+	}
+
+	report := &Report{Scenario: sc.Name}
+	failed := false
+
+	for i := range sc.Steps {
+		step := &sc.Steps[i]
+
+		res := StepResult{Index: i + 1, Name: step.describe()}
+		if failed {
+			res.Status = Skip
+			report.Steps = append(report.Steps, res)
+			continue
+		}
+
+		start := time.Now()
+		err := st.step(ctx, step)
+		res.Elapsed = time.Since(start)
+
+		if err != nil {
+			res.Status = Fail
+			res.Err = err
+			failed = true
+		} else {
+			res.Status = Pass
+		}
+
+		report.Steps = append(report.Steps, res)
+	}
+
+	return report
+}
+
+// describe names a step for the report.
+func (s *Step) describe() string {
+	if s.Name != "" {
+		return s.Name
+	}
+
+	action, _ := s.action()
+	switch action {
+	case "wallets":
+		return "wallets " + strings.Join(s.Wallets, ", ")
+	case "issue":
+		return fmt.Sprintf("issue %s %s to %s", s.Issue.Total, s.Issue.Name, s.Issue.Wallet)
+	case "transfer":
+		return fmt.Sprintf("transfer %s %s from %s to %s", s.Transfer.Amount, s.Transfer.Token, s.Transfer.From, s.Transfer.To)
+	case "wait":
+		return "wait " + s.Wait
+	case "balance":
+		d := fmt.Sprintf("balance %s of %s", s.Balance.Token, s.Balance.Wallet)
+		if s.Balance.Equals != "" {
+			d += " equals " + s.Balance.Equals
+		}
+		return d
+	}
+
+	return action
+}
+
+// expand replaces the ${name} variables of s.
+func (st *run) expand(s string) (string, error) {
+	var missing []string
+
+	out := os.Expand(s, func(name string) string {
+		v, ok := st.vars[name]
+		if !ok {
+			missing = append(missing, name)
+		}
+		return v
+	})
+
+	if len(missing) > 0 {
+		return "", fmt.Errorf("undefined variable %s", strings.Join(missing, ", "))
+	}
+
+	return out, nil
+}
+
+// expandAll expands every string pointed by ss, stopping at the first
+// error.
+func (st *run) expandAll(ss ...*string) error {
+	for _, s := range ss {
+		v, err := st.expand(*s)
+		if err != nil {
+			return err
+		}
+		*s = v
+	}
+	return nil
+}
+
+func (st *run) wallet(name string) (*fabric.Wallet, error) {
+	w, ok := st.wallets[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown wallet %s", name)
+	}
+	return w, nil
+}
+
+// address returns the address of the wallet name, or name itself.
+func (st *run) address(name string) string {
+	if w, ok := st.wallets[name]; ok {
+		return w.Address
+	}
+	return name
+}
+
+// step runs s and checks its error against ExpectError.
+func (st *run) step(ctx context.Context, s *Step) error {
+	timeout := s.Timeout
+	if timeout <= 0 {
+		timeout = DefaultTimeout
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	result, err := st.do(ctx, s)
+
+	if s.ExpectError != "" {
+		if err == nil {
+			return fmt.Errorf("succeeded, want error %q", s.ExpectError)
+		}
+		if !matchError(err, s.ExpectError) {
+			return fmt.Errorf("failed with %v, want error %q", err, s.ExpectError)
+		}
+		return nil
+	}
+
+	if err != nil {
+		return err
+	}
+
+	if s.As != "" {
+		st.vars[s.As] = result
+	}
+
+	return nil
+}
+
+// do runs the action of s and returns its result.
+func (st *run) do(ctx context.Context, s *Step) (string, error) {
+	action, err := s.action()
+	if err != nil {
+		return "", err
+	}
+
+	switch action {
+	case "wallets":
+		for _, name := range s.Wallets {
+			w, err := st.f.NewWallet()
+			if err != nil {
+				return "", err
+			}
+			st.wallets[name] = w
+			st.vars[name] = w.Address
+		}
+		return "", nil
+
+	case "set":
+		for k, v := range s.Set {
+			v, err := st.expand(v)
+			if err != nil {
+				return "", err
+			}
+			st.vars[k] = v
+		}
+		return "", nil
+
+	case "issue":
+		is := *s.Issue
+		if err := st.expandAll(&is.Wallet, &is.Name, &is.Total); err != nil {
+			return "", err
+		}
+
+		w, err := st.wallet(is.Wallet)
+		if err != nil {
+			return "", err
+		}
+
+		return st.f.IssueTokenContext(ctx, w.Address, w.PrivKey, is.Name, is.Total)
+
+	case "transfer":
+		tr := *s.Transfer
+		if err := st.expandAll(&tr.Token, &tr.From, &tr.To, &tr.Amount); err != nil {
+			return "", err
+		}
+
+		from, err := st.wallet(tr.From)
+		if err != nil {
+			return "", err
+		}
+
+		return st.f.TransferContext(ctx, tr.Token, from.Address, from.PrivKey, st.address(tr.To), tr.Amount)
+
+	case "wait":
+		txID, err := st.expand(s.Wait)
+		if err != nil {
+			return "", err
+		}
+
+		_, err = st.f.WaitForTx(ctx, txID)
+		return txID, err
+
+	case "balance":
+		bs := *s.Balance
+		if err := st.expandAll(&bs.Wallet, &bs.Token, &bs.Equals); err != nil {
+			return "", err
+		}
+
+		b, err := st.f.QueryBalanceContext(ctx, st.address(bs.Wallet))
+		if err != nil {
+			return "", err
+		}
+
+		got := b.Get(bs.Token)
+		if bs.Equals != "" && got != bs.Equals {
+			return "", fmt.Errorf("balance is %s, want %s", got, bs.Equals)
+		}
+		return got, nil
+	}
+
+	return "", errors.New("unknown action " + action)
+}
+
+var expectedErrors = map[string]error{
+	"insufficient balance": fabric.ErrInsufficientBalance,
+	"not found":            fabric.ErrNotFound,
+	"duplicate":            fabric.ErrDuplicate,
+	"rejected":             fabric.ErrRejected,
+	"invalid tx":           fabric.ErrTxInvalid,
+	"timeout":              context.DeadlineExceeded,
+}
+
+func matchError(err error, expect string) bool {
+	if expect == "any" {
+		return true
+	}
+
+	if target, ok := expectedErrors[expect]; ok {
+		return errors.Is(err, target)
+	}
+
+	return strings.Contains(err.Error(), expect)
+}
@@ -0,0 +1,159 @@
+// Package scenario runs end to end test flows described in yaml or json
+// files against an ocean gateway.
+//
+// A scenario is a list of steps, each with one action:
+//
+//	name: issue and transfer
+//	steps:
+//	  - wallets: [alice, bob]
+//	  - issue: {wallet: alice, name: OCE, total: "10000"}
+//	    as: oce
+//	  - transfer: {token: "${oce}", from: alice, to: bob, amount: "100"}
+//	    as: tx
+//	  - wait: "${tx}"
+//	  - balance: {wallet: bob, token: "${oce}", equals: "100"}
+//	  - transfer: {token: "${oce}", from: bob, to: alice, amount: "1000"}
+//	    expectError: insufficient balance
+//
+// ${name} in any value is replaced by a variable: the address of a wallet
+// created by a wallets step, a value captured with as, or one set with a
+// set step.
+package scenario
+
+import (
+	"errors"
+	"fmt"
+	"gopkg.in/yaml.v2"
+	"io/ioutil"
+	"time"
+)
+
+// Scenario is a named list of steps.
+type Scenario struct {
+	Name  string `yaml:"name"`
+	Steps []Step `yaml:"steps"`
+}
+
+// Step is one action of a scenario. Exactly one of the action fields is
+// set.
+type Step struct {
+	// Name describes the step in the report, the action if empty.
+	Name string `yaml:"name"`
+
+	// Wallets creates a wallet for every name.
+	Wallets []string `yaml:"wallets"`
+	// Issue issues a token, as captures its tokenID.
+	Issue *IssueStep `yaml:"issue"`
+	// Transfer sends a transfer, as captures its txID.
+	Transfer *TransferStep `yaml:"transfer"`
+	// Wait waits for the commit of a txID.
+	Wait string `yaml:"wait"`
+	// Balance checks the balance of a wallet, as captures it.
+	Balance *BalanceStep `yaml:"balance"`
+	// Set sets variables.
+	Set map[string]string `yaml:"set"`
+
+	// As names the variable receiving the result of the step.
+	As string `yaml:"as"`
+	// ExpectError makes the step pass only if it fails with this error:
+	// insufficient balance, not found, duplicate, rejected, invalid tx,
+	// timeout, any, or else a substring of the error message.
+	ExpectError string `yaml:"expectError"`
+	// Timeout bounds the step, DefaultTimeout if 0.
+	Timeout time.Duration `yaml:"timeout"`
+}
+
+type IssueStep struct {
+	Wallet string `yaml:"wallet"`
+	Name   string `yaml:"name"`
+	Total  string `yaml:"total"`
+}
+
+type TransferStep struct {
+	Token string `yaml:"token"`
+	// From is a wallet of the scenario, To a wallet or an address.
+	From   string `yaml:"from"`
+	To     string `yaml:"to"`
+	Amount string `yaml:"amount"`
+}
+
+type BalanceStep struct {
+	// Wallet is a wallet of the scenario or an address.
+	Wallet string `yaml:"wallet"`
+	Token  string `yaml:"token"`
+	// Equals, if set, is the expected amount.
+	Equals string `yaml:"equals"`
+}
+
+// action returns the name of the action of s, an error unless exactly one
+// is set.
+func (s *Step) action() (string, error) {
+	actions := []string{}
+	if s.Wallets != nil {
+		actions = append(actions, "wallets")
+	}
+	if s.Issue != nil {
+		actions = append(actions, "issue")
+	}
+	if s.Transfer != nil {
+		actions = append(actions, "transfer")
+	}
+	if s.Wait != "" {
+		actions = append(actions, "wait")
+	}
+	if s.Balance != nil {
+		actions = append(actions, "balance")
+	}
+	if s.Set != nil {
+		actions = append(actions, "set")
+	}
+
+	switch len(actions) {
+	case 0:
+		return "", errors.New("no action")
+	case 1:
+		return actions[0], nil
+	}
+
+	return "", fmt.Errorf("several actions %v", actions)
+}
+
+// Parse decodes a yaml or json scenario.
+func Parse(data []byte) (*Scenario, error) {
+	sc := &Scenario{}
+	err := yaml.UnmarshalStrict(data, sc)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(sc.Steps) == 0 {
+		return nil, errors.New("scenario has no steps")
+	}
+
+	for i := range sc.Steps {
+		if _, err := sc.Steps[i].action(); err != nil {
+			return nil, fmt.Errorf("step %d: %v", i+1, err)
+		}
+	}
+
+	return sc, nil
+}
+
+// Load reads the scenario file at path.
+func Load(path string) (*Scenario, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	sc, err := Parse(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+
+	if sc.Name == "" {
+		sc.Name = path
+	}
+
+	return sc, nil
+}
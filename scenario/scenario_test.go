@@ -0,0 +1,179 @@
+package scenario
+
+import (
+	"context"
+	"fabricclient/fabric/fabrictest"
+	"fabricclient/fabric/fabrictest/testclient"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newRunner(t *testing.T) (*Runner, *fabrictest.Server) {
+	t.Helper()
+
+	s := fabrictest.NewServer()
+	return NewRunner(testclient.New(t, s)), s
+}
+
+func runSource(t *testing.T, src string) *Report {
+	t.Helper()
+
+	sc, err := Parse([]byte(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r, s := newRunner(t)
+	defer s.Close()
+
+	return r.Run(context.Background(), sc)
+}
+
+func TestExampleScenario(t *testing.T) {
+	sc, err := Load("../conf/scenario/transfer.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r, s := newRunner(t)
+	defer s.Close()
+
+	report := r.Run(context.Background(), sc)
+	if !report.Passed() || len(report.Steps) != len(sc.Steps) {
+		t.Errorf("report:\n%s", report)
+	}
+}
+
+func TestJsonScenario(t *testing.T) {
+	report := runSource(t, `{
+		"name": "json",
+		"steps": [
+			{"wallets": ["a", "b"]},
+			{"issue": {"wallet": "a", "name": "T", "total": "10"}, "as": "t"},
+			{"transfer": {"token": "${t}", "from": "a", "to": "${b}", "amount": "3"}},
+			{"balance": {"wallet": "b", "token": "${t}"}, "as": "got"},
+			{"set": {"want": "${got}"}},
+			{"balance": {"wallet": "a", "token": "${t}", "equals": "7"}},
+			{"balance": {"wallet": "b", "token": "${t}", "equals": "${want}"}}
+		]
+	}`)
+
+	if !report.Passed() {
+		t.Errorf("report:\n%s", report)
+	}
+}
+
+func TestFailedStep(t *testing.T) {
+	report := runSource(t, `
+name: wrong balance
+steps:
+  - wallets: [a]
+  - issue: {wallet: a, name: T, total: "10"}
+    as: t
+  - balance: {wallet: a, token: "${t}", equals: "11"}
+  - wallets: [b]
+`)
+
+	if report.Passed() {
+		t.Fatal("scenario passed")
+	}
+
+	want := []Status{Pass, Pass, Fail, Skip}
+	for i, s := range report.Steps {
+		if s.Status != want[i] {
+			t.Errorf("step %d: %s, want %s", i+1, s.Status, want[i])
+		}
+	}
+	if err := report.Steps[2].Err; err == nil || !strings.Contains(err.Error(), "balance is 10, want 11") {
+		t.Errorf("step 3 err = %v", err)
+	}
+
+	out := report.String()
+	for _, line := range []string{"FAIL   3 balance ${t} of a equals 11", "SKIP   4 wallets b", "FAIL wrong balance"} {
+		if !strings.Contains(out, line) {
+			t.Errorf("report has no %q:\n%s", line, out)
+		}
+	}
+}
+
+func TestExpectError(t *testing.T) {
+	for expect, pass := range map[string]bool{
+		"insufficient balance": true,
+		"rejected":             true,
+		"any":                  true,
+		"insufficient":         true,
+		"not found":            false,
+		"duplicate":            false,
+	} {
+		report := runSource(t, `
+steps:
+  - wallets: [a, b]
+  - issue: {wallet: a, name: T, total: "10"}
+    as: t
+  - transfer: {token: "${t}", from: a, to: b, amount: "11"}
+    expectError: `+expect+`
+`)
+		if report.Passed() != pass {
+			t.Errorf("expectError %q: passed = %v\n%s", expect, report.Passed(), report)
+		}
+	}
+
+	// an expected error that does not happen
+	report := runSource(t, `
+steps:
+  - wallets: [a]
+  - issue: {wallet: a, name: T, total: "10"}
+    expectError: any
+`)
+	if report.Passed() {
+		t.Error("step without the expected error passed")
+	}
+}
+
+func TestUndefined(t *testing.T) {
+	for _, src := range []string{
+		"steps:\n  - wallets: [a]\n  - wait: ${tx}\n",
+		"steps:\n  - wallets: [a]\n  - transfer: {token: t, from: b, to: a, amount: '1'}\n",
+	} {
+		report := runSource(t, src)
+		if report.Passed() || report.Steps[1].Status != Fail {
+			t.Errorf("%s passed:\n%s", src, report)
+		}
+	}
+}
+
+func TestParseInvalid(t *testing.T) {
+	for _, src := range []string{
+		"name: empty\n",
+		"steps:\n  - name: nothing\n",
+		"steps:\n  - wallets: [a]\n    wait: tx\n",
+		"steps:\n  - transfr: {}\n",
+		"steps: [",
+	} {
+		if _, err := Parse([]byte(src)); err == nil {
+			t.Errorf("Parse(%q) succeeded", src)
+		}
+	}
+}
+
+func TestStepTimeout(t *testing.T) {
+	r, s := newRunner(t)
+	defer s.Close()
+	s.SetLatency(200 * time.Millisecond)
+
+	sc, err := Parse([]byte(`
+steps:
+  - wallets: [a]
+  - balance: {wallet: a, token: t}
+    timeout: 20ms
+    expectError: timeout
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if report := r.Run(context.Background(), sc); !report.Passed() {
+		t.Errorf("report:\n%s", report)
+	}
+}
@@ -0,0 +1,335 @@
+package selftest
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fabricclient/audit"
+	"fabricclient/fabric"
+	"fabricclient/hdwallet"
+	"fabricclient/keystore"
+	"fabricclient/load"
+	"fabricclient/logger"
+	"fabricclient/util"
+	"io/ioutil"
+	"os"
+	"time"
+)
+
+const (
+	DefaultParamFile = "conf/TestParam.json"
+
+	waitTimeout = 30 * time.Second
+	concurrency = 50
+)
+
+// Names of the test wallets in the keystore.
+const (
+	Token1WalletName = "selftest1"
+	Token2WalletName = "selftest2"
+)
+
+type TestParam struct {
+	Token1Wallet fabric.Wallet `json:"token1Wallet"`
+	TokenID1     string        `json:"tokenID1"`
+	Token2Wallet fabric.Wallet `json:"token2Wallet"`
+	TokenID2     string        `json:"tokenID2"`
+}
+
+// Runner drives the end to end test routine against a FabricClient.
+type Runner struct {
+	f  *fabric.FabricClient
+	tp *TestParam
+
+	// ParamFile caches the issued test tokens between runs.
+	ParamFile string
+	// Keystore and Passphrase keep the keys of the test wallets, they are
+	// not cached without a keystore.
+	Keystore   *keystore.Store
+	Passphrase string
+	// HD, if set, derives the ring of the concurrent test at
+	// hdwallet.DefaultPath, so that its funds are swept back to the first
+	// test wallet after the run instead of being lost.
+	HD *hdwallet.HD
+}
+
+func NewRunner(f *fabric.FabricClient) *Runner {
+	return &Runner{
+		f:         f,
+		ParamFile: DefaultParamFile,
+	}
+}
+
+func (r *Runner) testTransfer() error {
+	tp := r.tp
+
+	for i := 0; i < 10; i++ {
+		r.f.Transfer(tp.TokenID1, tp.Token1Wallet.Address, tp.Token1Wallet.PrivKey, tp.Token2Wallet.Address, "50")
+		r.f.QueryBalance(tp.Token1Wallet.Address)
+		r.f.QueryBalance(tp.Token2Wallet.Address)
+	}
+
+	return nil
+}
+
+func (r *Runner) testApiInit() error {
+	var err error
+
+	if r.tp != nil {
+		_, err = r.f.QueryToken(r.tp.TokenID1)
+		if err == nil {
+			return nil
+		}
+	}
+
+	tp := &TestParam{}
+
+	w1, err := r.f.NewWallet()
+	if err != nil {
+		logger.Error(err)
+		return err
+	}
+	tp.Token1Wallet = *w1
+	tp.TokenID1, err = r.f.IssueToken(tp.Token1Wallet.Address, tp.Token1Wallet.PrivKey, "OCE", "10000")
+	if err != nil {
+		logger.Error(err)
+		return err
+	}
+
+	logger.Info("tp.TokenID1 =", tp.TokenID1)
+
+	_, err = r.f.QueryToken(tp.TokenID1)
+	if err != nil {
+		logger.Error(err)
+		return err
+	}
+
+	tp.TokenID2, _ = r.f.IssueToken(tp.Token1Wallet.Address, tp.Token1Wallet.PrivKey, "OCE2", "20000")
+	_, err = r.f.QueryBalance(tp.Token1Wallet.Address)
+	if err != nil {
+		logger.Error(err)
+		return err
+	}
+
+	w2, err := r.f.NewWallet()
+	if err != nil {
+		logger.Error(err)
+		return err
+	}
+	tp.Token2Wallet = *w2
+	txID, err := r.f.Transfer(tp.TokenID1, tp.Token1Wallet.Address, tp.Token1Wallet.PrivKey, tp.Token2Wallet.Address, "100")
+	if err != nil {
+		logger.Error(err)
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), waitTimeout)
+	_, err = r.f.WaitForTx(ctx, txID)
+	cancel()
+	if err != nil {
+		logger.Error(err)
+		return err
+	}
+
+	r.f.QueryBalance(tp.Token1Wallet.Address)
+	r.f.QueryBalance(tp.Token2Wallet.Address)
+
+	r.tp = tp
+
+	if r.Keystore == nil {
+		return nil
+	}
+
+	err = SaveParam(r.ParamFile, tp, r.Keystore, r.Passphrase)
+	if err != nil {
+		logger.Error(err)
+		return err
+	}
+
+	return nil
+}
+
+// SaveParam writes the test tokens to file and the keys of the wallets to
+// ks, replacing the previous test wallets.
+func SaveParam(file string, tp *TestParam, ks *keystore.Store, passphrase string) error {
+	for name, w := range map[string]fabric.Wallet{Token1WalletName: tp.Token1Wallet, Token2WalletName: tp.Token2Wallet} {
+		err := ks.Delete(name)
+		if err != nil && err != keystore.ErrNotFound {
+			return err
+		}
+
+		err = ks.Import(name, w, passphrase)
+		if err != nil {
+			return err
+		}
+	}
+
+	// the file keeps the addresses only
+	pf := *tp
+	pf.Token1Wallet.PrivKey, pf.Token2Wallet.PrivKey = "", ""
+
+	data, err := json.Marshal(&pf)
+	if err != nil {
+		return err
+	}
+
+	return writeFile(file, data)
+}
+
+// writeFile replaces file with data, readable by the owner only.
+func writeFile(file string, data []byte) error {
+	tmp := file + ".tmp"
+
+	err := ioutil.WriteFile(tmp, data, 0600)
+	if err != nil {
+		return err
+	}
+
+	return os.Rename(tmp, file)
+}
+
+// LoadParam reads the test tokens cached in file and decrypts the keys
+// of the wallets from ks. A file still holding the keys is migrated to
+// ks.
+func LoadParam(file string, ks *keystore.Store, passphrase string) (*TestParam, error) {
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+
+	tp := &TestParam{}
+	err = json.Unmarshal(data, tp)
+	if err != nil {
+		return nil, err
+	}
+
+	// older files hold the keys
+	if tp.Token1Wallet.PrivKey != "" {
+		logger.Info("moving the keys of", file, "to the keystore")
+		err = SaveParam(file, tp, ks, passphrase)
+		if err != nil {
+			return nil, err
+		}
+		return tp, nil
+	}
+
+	for name, w := range map[string]*fabric.Wallet{Token1WalletName: &tp.Token1Wallet, Token2WalletName: &tp.Token2Wallet} {
+		stored, err := ks.Export(name, passphrase)
+		if err != nil {
+			return nil, err
+		}
+		if stored.Address != w.Address {
+			return nil, errors.New("selftest: keystore wallet " + name + " is not the one of " + file)
+		}
+		w.PrivKey = stored.PrivKey
+	}
+
+	return tp, nil
+}
+
+// highConcurrent sends concurrent transfers around a ring of wallets
+// funded with the first test token, then audits the balances of the ring.
+func (r *Runner) highConcurrent() error {
+	checker := audit.NewChecker()
+
+	g, err := load.New(r.f, load.Config{
+		Workload:    load.Transfer,
+		Concurrency: concurrency,
+		Count:       concurrency,
+		TokenID:     r.tp.TokenID1,
+		Funder:      r.tp.Token1Wallet,
+		Audit:       checker,
+		HD:          r.HD,
+	})
+	if err != nil {
+		logger.Error(err)
+		return err
+	}
+
+	report, err := g.Run(context.Background())
+	if err != nil {
+		logger.Error(err)
+		return err
+	}
+
+	logger.Info("\n" + report.String())
+
+	ar, err := checker.Verify(context.Background(), r.f, audit.DefaultParallel)
+	if err != nil {
+		logger.Error(err)
+		return err
+	}
+
+	logger.Info("\n" + ar.String())
+	if !ar.OK() {
+		return errors.New("audit failed")
+	}
+
+	if r.HD != nil {
+		err = r.sweep()
+		if err != nil {
+			logger.Error(err)
+			return err
+		}
+	}
+
+	return nil
+}
+
+// sweep moves the balances of the ring back to the first test wallet.
+func (r *Runner) sweep() error {
+	wallets, err := r.HD.Wallets(hdwallet.MustParsePath(hdwallet.DefaultPath), 0, concurrency)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), waitTimeout)
+	defer cancel()
+
+	_, err = load.Sweep(ctx, r.f, wallets, r.tp.TokenID1, r.tp.Token1Wallet.Address)
+	return err
+}
+
+func (r *Runner) testApi() error {
+	if r.Keystore != nil && util.IsFileExist(r.ParamFile) {
+		tp, err := LoadParam(r.ParamFile, r.Keystore, r.Passphrase)
+		if err != nil {
+			logger.Error(err)
+			return err
+		}
+
+		r.tp = tp
+	}
+
+	err := r.testApiInit()
+	if err != nil {
+		logger.Error(err)
+		return err
+	}
+
+	/*	err = r.testTransfer()
+		if err != nil {
+			logger.Error(err)
+			return err
+		}*/
+
+	return nil
+}
+
+// Run issues the test tokens if needed and then runs the concurrent
+// transfer test.
+func (r *Runner) Run() error {
+	err := r.testApi()
+	if err != nil {
+		logger.Error(err)
+		return err
+	}
+
+	err = r.highConcurrent()
+	if err != nil {
+		logger.Error(err)
+		return err
+	}
+
+	return nil
+}
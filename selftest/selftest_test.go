@@ -0,0 +1,117 @@
+package selftest
+
+import (
+	"encoding/json"
+	"fabricclient/fabric/fabrictest"
+	"fabricclient/fabric/fabrictest/testclient"
+	"fabricclient/hdwallet"
+	"fabricclient/keystore"
+	"fabricclient/util"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func newParam() *TestParam {
+	tp := &TestParam{TokenID1: "t1", TokenID2: "t2"}
+	tp.Token1Wallet, tp.Token2Wallet = testclient.NewWallet(), testclient.NewWallet()
+	return tp
+}
+
+func newKeystore(t *testing.T) (*keystore.Store, string) {
+	t.Helper()
+
+	dir := t.TempDir()
+	ks, err := keystore.Open(filepath.Join(dir, "keystore"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ks.Scrypt = keystore.LightScrypt
+
+	return ks, filepath.Join(dir, "TestParam.json")
+}
+
+func TestSaveLoadParam(t *testing.T) {
+	ks, file := newKeystore(t)
+	tp := newParam()
+
+	if err := SaveParam(file, tp, ks, "pass"); err != nil {
+		t.Fatal(err)
+	}
+
+	fi, _ := os.Stat(file)
+	data, _ := ioutil.ReadFile(file)
+	if fi.Mode().Perm() != 0600 || strings.Contains(string(data), tp.Token1Wallet.PrivKey) {
+		t.Errorf("param file %v:\n%s", fi.Mode(), data)
+	}
+
+	got, err := LoadParam(file, ks, "pass")
+	if err != nil || *got != *tp {
+		t.Errorf("LoadParam = %+v, %v", got, err)
+	}
+	if _, err := LoadParam(file, ks, "guess"); err != keystore.ErrPassphrase {
+		t.Errorf("wrong passphrase: %v", err)
+	}
+
+	// new test wallets replace the previous ones
+	tp = newParam()
+	if err := SaveParam(file, tp, ks, "pass"); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := LoadParam(file, ks, "pass"); *got != *tp {
+		t.Errorf("LoadParam = %+v", got)
+	}
+}
+
+func TestLoadParamMigrates(t *testing.T) {
+	ks, file := newKeystore(t)
+	tp := newParam()
+
+	// the file of older versions, with the keys
+	data, _ := json.Marshal(tp)
+	ioutil.WriteFile(file, data, os.ModePerm)
+
+	got, err := LoadParam(file, ks, "pass")
+	if err != nil || *got != *tp {
+		t.Fatalf("LoadParam = %+v, %v", got, err)
+	}
+
+	data, _ = ioutil.ReadFile(file)
+	if strings.Contains(string(data), tp.Token1Wallet.PrivKey) {
+		t.Errorf("keys left in the file:\n%s", data)
+	}
+	if w, err := ks.Export(Token2WalletName, "pass"); err != nil || w != tp.Token2Wallet {
+		t.Errorf("keystore wallet = %+v, %v", w, err)
+	}
+
+	// a keystore of other wallets
+	other := newParam()
+	SaveParam(filepath.Join(t.TempDir(), "other.json"), other, ks, "pass")
+	if _, err := LoadParam(file, ks, "pass"); err == nil {
+		t.Error("LoadParam with the wallets of another file succeeded")
+	}
+}
+
+func TestRunSweepsHD(t *testing.T) {
+	s := fabrictest.NewServer()
+	defer s.Close()
+
+	r := NewRunner(testclient.New(t, s))
+
+	var err error
+	r.HD, err = hdwallet.New("abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon about", "", util.MainNet)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := r.Run(); err != nil {
+		t.Fatal(err)
+	}
+
+	// all but the 100 units sent to the second test wallet are back
+	if got := s.Balance(r.tp.Token1Wallet.Address, r.tp.TokenID1); got != "9900" {
+		t.Errorf("first test wallet has %s", got)
+	}
+}
@@ -0,0 +1,141 @@
+package shell
+
+import (
+	"context"
+	"fmt"
+	"github.com/peterh/liner"
+	"io"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// HistoryFile is the history of the shell, in the home directory.
+const HistoryFile = ".fabricclient_history"
+
+// complete returns the candidates for the word being typed, the word
+// before it deciding between commands, wallets, tokens and variables.
+func (sh *Shell) complete(line string, pos int) (head string, completions []string, tail string) {
+	head, tail = line[:pos], line[pos:]
+
+	start := strings.LastIndexAny(head, " \t") + 1
+	word := head[start:]
+	head = head[:start]
+	prev := strings.Fields(head)
+
+	candidates := []string{}
+	switch {
+	case strings.HasPrefix(word, "$"):
+		for n := range sh.vars {
+			candidates = append(candidates, "$"+n)
+		}
+	case len(prev) == 0:
+		for n := range commands {
+			candidates = append(candidates, n)
+		}
+	case len(prev) == 1 && (prev[0] == "use" || prev[0] == "watch"):
+		candidates = append(candidates, map[string]string{"use": "wallet", "watch": "tx"}[prev[0]])
+	case len(prev) == 1 && prev[0] == "wallet":
+		candidates = append(candidates, "new", "load", "save")
+	case len(prev) == 1 && (prev[0] == "unlock" || prev[0] == "lock"):
+		for name := range sh.stored {
+			candidates = append(candidates, name)
+		}
+	case prev[0] == "use" || prev[0] == "balance" || prev[0] == "transfer" && len(prev) == 2:
+		candidates = sh.walletNames()
+	case prev[0] == "token" || prev[0] == "transfer" && len(prev) == 1:
+		for name, id := range sh.tokens {
+			candidates = append(candidates, name, id)
+		}
+	}
+
+	for _, c := range candidates {
+		if strings.HasPrefix(c, word) {
+			completions = append(completions, c)
+		}
+	}
+	sort.Strings(completions)
+
+	return head, completions, tail
+}
+
+// secret reports whether line holds a private key, kept out of the
+// history.
+func secret(line string) bool {
+	args := strings.Fields(line)
+	return len(args) > 3 && args[0] == "wallet" && args[1] == "load"
+}
+
+// Run reads and runs lines until exit or the end of in. Ctrl-C cancels
+// the running command, not the shell.
+func (sh *Shell) Run(ctx context.Context) error {
+	line := liner.NewLiner()
+	defer line.Close()
+
+	line.SetCtrlCAborts(true)
+	line.SetWordCompleter(sh.complete)
+
+	if sh.passphrase == nil {
+		sh.passphrase = line.PasswordPrompt
+	}
+	if sh.readKey == nil {
+		sh.readKey = line.PasswordPrompt
+	}
+
+	history := ""
+	if home, err := os.UserHomeDir(); err == nil {
+		history = filepath.Join(home, HistoryFile)
+		if f, err := os.Open(history); err == nil {
+			line.ReadHistory(f)
+			f.Close()
+		}
+	}
+
+	defer func() {
+		if history == "" {
+			return
+		}
+		if f, err := os.OpenFile(history, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600); err == nil {
+			line.WriteHistory(f)
+			f.Close()
+		}
+	}()
+
+	for {
+		s, err := line.Prompt(sh.prompt())
+		if err == liner.ErrPromptAborted {
+			continue
+		}
+		if err == io.EOF {
+			fmt.Fprintln(sh.out)
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		if strings.TrimSpace(s) == "" {
+			continue
+		}
+		if !secret(s) {
+			line.AppendHistory(s)
+		}
+
+		cctx, stop := signal.NotifyContext(ctx, os.Interrupt)
+		err = sh.Exec(cctx, s)
+		stop()
+
+		if err == errExit {
+			return nil
+		}
+		if err != nil {
+			fmt.Fprintln(sh.out, "error:", err)
+		}
+
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+	}
+}
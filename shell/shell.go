@@ -0,0 +1,573 @@
+// Package shell is an interactive shell over a FabricClient. It keeps the
+// wallets and tokens of the session in memory:
+//
+//	> wallet new alice
+//	> wallet new bob
+//	> use wallet alice
+//	alice> issue OCE 10000
+//	alice> transfer OCE bob 50
+//	alice> watch tx $lastTx
+//	alice> balance bob
+//
+// $name in a line is replaced by a variable of the session, such as
+// $lastTx, $lastToken or the address of a wallet.
+package shell
+
+import (
+	"context"
+	"errors"
+	"fabricclient/fabric"
+	"fabricclient/keystore"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+const (
+	// DefaultTimeout bounds a command, except watch which runs until its
+	// own timeout or an interrupt.
+	DefaultTimeout = time.Minute
+	// DefaultUnlock is how long unlock keeps a wallet of the keystore
+	// unlocked.
+	DefaultUnlock = 15 * time.Minute
+)
+
+// errExit is returned by Exec for exit and quit.
+var errExit = errors.New("exit")
+
+type command struct {
+	usage string
+	run   func(sh *Shell, ctx context.Context, args []string) error
+	// untimed commands are not bounded by DefaultTimeout.
+	untimed bool
+}
+
+var commands map[string]command
+
+func init() {
+	commands = map[string]command{
+		"help":     {"help", (*Shell).help, false},
+		"wallet":   {"wallet new <name> | wallet load <name> [wif] | wallet save <name>", (*Shell).wallet, false},
+		"unlock":   {"unlock <name> [duration]", (*Shell).unlock, false},
+		"lock":     {"lock [name]", (*Shell).lock, false},
+		"wallets":  {"wallets", (*Shell).listWallets, false},
+		"use":      {"use wallet <name>", (*Shell).use, false},
+		"issue":    {"issue <name> <total>", (*Shell).issue, false},
+		"token":    {"token <name|tokenID>", (*Shell).token, false},
+		"transfer": {"transfer <token> <wallet|address> <amount>", (*Shell).transfer, false},
+		"balance":  {"balance [wallet|address]", (*Shell).balance, false},
+		"tx":       {"tx <txID>", (*Shell).tx, false},
+		"watch":    {"watch tx <txID> [timeout]", (*Shell).watch, true},
+		"set":      {"set <name> <value>", (*Shell).set, false},
+		"vars":     {"vars", (*Shell).listVars, false},
+		"exit":     {"exit", exit, false},
+		"quit":     {"quit", exit, false},
+	}
+}
+
+// Shell is the state of a session.
+type Shell struct {
+	f   *fabric.FabricClient
+	out io.Writer
+
+	wallets map[string]*fabric.Wallet
+	// current is the wallet used by issue and transfer.
+	current string
+	// stored are the wallets of Keystore, their key is in the keystore
+	// while unlocked.
+	stored map[string]bool
+	// tokens maps the name of the tokens seen to their tokenID.
+	tokens map[string]string
+	vars   map[string]string
+
+	keystore   *keystore.Store
+	passphrase func(prompt string) (string, error)
+	// readKey asks for the private key of wallet load, without echo.
+	readKey func(prompt string) (string, error)
+}
+
+// New returns a shell over f printing to out.
+func New(f *fabric.FabricClient, out io.Writer) *Shell {
+	return &Shell{
+		f:       f,
+		out:     out,
+		wallets: map[string]*fabric.Wallet{},
+		stored:  map[string]bool{},
+		tokens:  map[string]string{},
+		vars:    map[string]string{},
+	}
+}
+
+// SetKeystore loads the wallets of ks, locked until unlock. passphrase
+// asks for the passphrase of unlock and wallet save.
+func (sh *Shell) SetKeystore(ks *keystore.Store, passphrase func(prompt string) (string, error)) error {
+	entries, err := ks.List()
+	if err != nil {
+		return err
+	}
+
+	sh.keystore, sh.passphrase = ks, passphrase
+	for _, e := range entries {
+		sh.AddWallet(e.Name, &fabric.Wallet{Address: e.Address})
+		sh.stored[e.Name] = true
+	}
+
+	return nil
+}
+
+// AddWallet loads a wallet under name.
+func (sh *Shell) AddWallet(name string, w *fabric.Wallet) {
+	sh.wallets[name] = w
+	sh.vars[name] = w.Address
+	delete(sh.stored, name)
+}
+
+// prompt shows the current wallet.
+func (sh *Shell) prompt() string {
+	return sh.current + "> "
+}
+
+// expand replaces the $name variables of line.
+func (sh *Shell) expand(line string) (string, error) {
+	var missing []string
+
+	out := os.Expand(line, func(name string) string {
+		v, ok := sh.vars[name]
+		if !ok {
+			missing = append(missing, name)
+		}
+		return v
+	})
+
+	if len(missing) > 0 {
+		return "", fmt.Errorf("undefined variable %s", strings.Join(missing, ", "))
+	}
+
+	return out, nil
+}
+
+// Exec runs one line.
+func (sh *Shell) Exec(ctx context.Context, line string) error {
+	line, err := sh.expand(line)
+	if err != nil {
+		return err
+	}
+
+	args := strings.Fields(line)
+	if len(args) == 0 {
+		return nil
+	}
+
+	cmd, ok := commands[args[0]]
+	if !ok {
+		return fmt.Errorf("unknown command %s, try help", args[0])
+	}
+
+	if !cmd.untimed {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, DefaultTimeout)
+		defer cancel()
+	}
+
+	return cmd.run(sh, ctx, args[1:])
+}
+
+func exit(*Shell, context.Context, []string) error {
+	return errExit
+}
+
+func (sh *Shell) printf(format string, a ...interface{}) {
+	fmt.Fprintf(sh.out, format, a...)
+}
+
+func usageError(name string) error {
+	return errors.New("usage: " + commands[name].usage)
+}
+
+func (sh *Shell) help(ctx context.Context, args []string) error {
+	names := []string{}
+	for n := range commands {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+
+	for _, n := range names {
+		sh.printf("  %s\n", commands[n].usage)
+	}
+	return nil
+}
+
+func (sh *Shell) wallet(ctx context.Context, args []string) error {
+	var w *fabric.Wallet
+	var err error
+
+	switch {
+	case len(args) == 2 && args[0] == "new":
+		w, err = sh.f.NewWallet()
+	case (len(args) == 2 || len(args) == 3) && args[0] == "load":
+		w, err = sh.load(args)
+	case len(args) == 2 && args[0] == "save":
+		return sh.save(args[1])
+	default:
+		return usageError("wallet")
+	}
+	if err != nil {
+		return err
+	}
+
+	name := args[1]
+	sh.AddWallet(name, w)
+	if sh.current == "" {
+		sh.current = name
+	}
+
+	sh.printf("%s %s\n", name, w.Address)
+	return nil
+}
+
+// load returns the wallet of wallet load <name> [wif], its key asked
+// without echo unless given.
+func (sh *Shell) load(args []string) (*fabric.Wallet, error) {
+	if len(args) == 3 {
+		return sh.f.WalletOf(args[2])
+	}
+	if sh.readKey == nil {
+		return nil, errors.New("no terminal to read the key, use wallet load <name> <wif>")
+	}
+
+	wif, err := sh.readKey("private key of " + args[1] + ": ")
+	if err != nil {
+		return nil, err
+	}
+
+	return sh.f.WalletOf(wif)
+}
+
+func (sh *Shell) listWallets(ctx context.Context, args []string) error {
+	w := tabwriter.NewWriter(sh.out, 0, 4, 2, ' ', 0)
+	for _, name := range sh.walletNames() {
+		mark := " "
+		if name == sh.current {
+			mark = "*"
+		}
+		where := "memory"
+		if sh.stored[name] {
+			where = "keystore"
+		}
+		fmt.Fprintf(w, "%s %s\t%s\t%s\n", mark, name, sh.wallets[name].Address, where)
+	}
+	return w.Flush()
+}
+
+func (sh *Shell) walletNames() []string {
+	names := []string{}
+	for n := range sh.wallets {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func (sh *Shell) use(ctx context.Context, args []string) error {
+	if len(args) != 2 || args[0] != "wallet" {
+		return usageError("use")
+	}
+	if _, ok := sh.wallets[args[1]]; !ok {
+		return fmt.Errorf("unknown wallet %s", args[1])
+	}
+
+	sh.current = args[1]
+	return nil
+}
+
+// save stores the wallet name in the keystore.
+func (sh *Shell) save(name string) error {
+	if sh.keystore == nil {
+		return errors.New("no keystore")
+	}
+
+	w, ok := sh.wallets[name]
+	if !ok || sh.stored[name] {
+		return fmt.Errorf("no wallet %s to save", name)
+	}
+
+	passphrase, err := sh.passphrase("new passphrase of " + name + ": ")
+	if err != nil {
+		return err
+	}
+	if passphrase == "" {
+		return errors.New("empty passphrase")
+	}
+
+	err = sh.keystore.Import(name, *w, passphrase)
+	if err != nil {
+		return err
+	}
+
+	sh.printf("%s saved to %s\n", name, sh.keystore.Dir())
+	return nil
+}
+
+func (sh *Shell) unlock(ctx context.Context, args []string) error {
+	if len(args) < 1 || len(args) > 2 {
+		return usageError("unlock")
+	}
+
+	name, d := args[0], DefaultUnlock
+	if !sh.stored[name] {
+		return fmt.Errorf("%s is not a wallet of the keystore", name)
+	}
+	if len(args) == 2 {
+		var err error
+		d, err = time.ParseDuration(args[1])
+		if err != nil {
+			return err
+		}
+	}
+
+	passphrase, err := sh.passphrase("passphrase of " + name + ": ")
+	if err != nil {
+		return err
+	}
+
+	_, err = sh.keystore.Unlock(name, passphrase, d)
+	if err != nil {
+		return err
+	}
+
+	sh.printf("%s unlocked for %v\n", name, d)
+	return nil
+}
+
+func (sh *Shell) lock(ctx context.Context, args []string) error {
+	switch len(args) {
+	case 0:
+		for name := range sh.stored {
+			sh.keystore.Lock(name)
+		}
+	case 1:
+		if !sh.stored[args[0]] {
+			return fmt.Errorf("%s is not a wallet of the keystore", args[0])
+		}
+		sh.keystore.Lock(args[0])
+	default:
+		return usageError("lock")
+	}
+	return nil
+}
+
+// currentWallet returns the wallet selected by use, its key may be locked
+// in the keystore.
+func (sh *Shell) currentWallet() (*fabric.Wallet, error) {
+	w, ok := sh.wallets[sh.current]
+	if !ok {
+		return nil, errors.New("no wallet, use wallet <name> first")
+	}
+	return w, nil
+}
+
+// signer returns the wallet selected by use with its key.
+func (sh *Shell) signer() (*fabric.Wallet, error) {
+	w, err := sh.currentWallet()
+	if err != nil || !sh.stored[sh.current] {
+		return w, err
+	}
+
+	unlocked, err := sh.keystore.Wallet(sh.current)
+	if err == keystore.ErrLocked {
+		return nil, fmt.Errorf("%s is locked, unlock %s first", sh.current, sh.current)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &unlocked, nil
+}
+
+// address resolves a wallet name to its address, anything else is taken
+// as an address.
+func (sh *Shell) address(s string) string {
+	if w, ok := sh.wallets[s]; ok {
+		return w.Address
+	}
+	return s
+}
+
+// tokenID resolves a token name seen in the session to its tokenID,
+// anything else is taken as a tokenID.
+func (sh *Shell) tokenID(s string) string {
+	if id, ok := sh.tokens[s]; ok {
+		return id
+	}
+	return s
+}
+
+func (sh *Shell) issue(ctx context.Context, args []string) error {
+	if len(args) != 2 {
+		return usageError("issue")
+	}
+
+	w, err := sh.signer()
+	if err != nil {
+		return err
+	}
+
+	tokenID, err := sh.f.IssueTokenContext(ctx, w.Address, w.PrivKey, args[0], args[1])
+	if err != nil {
+		return err
+	}
+
+	sh.tokens[args[0]] = tokenID
+	sh.vars["lastToken"] = tokenID
+	sh.printf("%s %s\n", args[0], tokenID)
+	return nil
+}
+
+func (sh *Shell) token(ctx context.Context, args []string) error {
+	if len(args) != 1 {
+		return usageError("token")
+	}
+
+	info, err := sh.f.QueryTokenContext(ctx, sh.tokenID(args[0]))
+	if err != nil {
+		return err
+	}
+
+	sh.tokens[info.TokenName] = info.TokenID
+	sh.vars["lastToken"] = info.TokenID
+	sh.printf("%s %s total %s issued to %s\n", info.TokenName, info.TokenID, info.TotalNumber, info.Address)
+	return nil
+}
+
+func (sh *Shell) transfer(ctx context.Context, args []string) error {
+	if len(args) != 3 {
+		return usageError("transfer")
+	}
+
+	w, err := sh.signer()
+	if err != nil {
+		return err
+	}
+
+	txID, err := sh.f.TransferContext(ctx, sh.tokenID(args[0]), w.Address, w.PrivKey, sh.address(args[1]), args[2])
+	if err != nil {
+		return err
+	}
+
+	sh.vars["lastTx"] = txID
+	sh.printf("%s\n", txID)
+	return nil
+}
+
+func (sh *Shell) balance(ctx context.Context, args []string) error {
+	var addr string
+	switch len(args) {
+	case 0:
+		w, err := sh.currentWallet()
+		if err != nil {
+			return err
+		}
+		addr = w.Address
+	case 1:
+		addr = sh.address(args[0])
+	default:
+		return usageError("balance")
+	}
+
+	b, err := sh.f.QueryBalanceContext(ctx, addr)
+	if err != nil {
+		return err
+	}
+
+	// token names are shown when known
+	names := map[string]string{}
+	for name, id := range sh.tokens {
+		names[id] = name
+	}
+
+	ids := []string{}
+	for id := range b.Tokens {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	tw := tabwriter.NewWriter(sh.out, 0, 4, 2, ' ', 0)
+	for _, id := range ids {
+		fmt.Fprintf(tw, "%s\t%s\t%s\n", id, names[id], b.Tokens[id])
+	}
+	if len(ids) == 0 {
+		fmt.Fprintln(tw, "no tokens")
+	}
+	return tw.Flush()
+}
+
+func (sh *Shell) tx(ctx context.Context, args []string) error {
+	if len(args) != 1 {
+		return usageError("tx")
+	}
+
+	rec, err := sh.f.QueryTxContext(ctx, args[0])
+	if err != nil {
+		return err
+	}
+
+	sh.printf("%s %s\n", rec.TxID, rec.Status())
+	if is := rec.Issue; is != nil {
+		sh.printf("issue %s %s to %s\n", is.TotalNumber, is.TokenName, is.Address)
+	}
+	if tr := rec.Transfer; tr != nil {
+		sh.printf("transfer %s %s from %s to %s\n", tr.Number, tr.TokenID, tr.FromAddress, tr.ToAddress)
+	}
+	return nil
+}
+
+// watch waits for the commit of a transaction, printing its status.
+func (sh *Shell) watch(ctx context.Context, args []string) error {
+	if len(args) < 2 || len(args) > 3 || args[0] != "tx" {
+		return usageError("watch")
+	}
+
+	if len(args) == 3 {
+		d, err := time.ParseDuration(args[2])
+		if err != nil {
+			return err
+		}
+
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, d)
+		defer cancel()
+	}
+
+	start := time.Now()
+	sh.printf("waiting for %s\n", args[1])
+
+	res, err := sh.f.WaitForTx(ctx, args[1])
+	sh.printf("%s %s after %v\n", res.TxID, res.Status, time.Since(start).Round(time.Millisecond))
+	return err
+}
+
+func (sh *Shell) set(ctx context.Context, args []string) error {
+	if len(args) != 2 {
+		return usageError("set")
+	}
+
+	sh.vars[args[0]] = args[1]
+	return nil
+}
+
+func (sh *Shell) listVars(ctx context.Context, args []string) error {
+	names := []string{}
+	for n := range sh.vars {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+
+	tw := tabwriter.NewWriter(sh.out, 0, 4, 2, ' ', 0)
+	for _, n := range names {
+		fmt.Fprintf(tw, "$%s\t%s\n", n, sh.vars[n])
+	}
+	return tw.Flush()
+}
@@ -0,0 +1,204 @@
+package shell
+
+import (
+	"bytes"
+	"context"
+	"fabricclient/fabric/fabrictest"
+	"fabricclient/fabric/fabrictest/testclient"
+	"fabricclient/keystore"
+	"fabricclient/util"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func newShell(t *testing.T) (*Shell, *bytes.Buffer, *fabrictest.Server) {
+	t.Helper()
+
+	s := fabrictest.NewServer()
+	out := &bytes.Buffer{}
+	return New(testclient.New(t, s), out), out, s
+}
+
+// exec runs lines, failing the test on the first error.
+func exec(t *testing.T, sh *Shell, lines ...string) {
+	t.Helper()
+
+	for _, l := range lines {
+		if err := sh.Exec(context.Background(), l); err != nil {
+			t.Fatalf("%s: %v", l, err)
+		}
+	}
+}
+
+func TestSession(t *testing.T) {
+	sh, out, s := newShell(t)
+	defer s.Close()
+
+	exec(t, sh,
+		"wallet new alice",
+		"wallet new bob",
+		"use wallet alice",
+		"issue OCE 100",
+		"transfer OCE bob 30",
+		"watch tx $lastTx 1s",
+	)
+
+	if sh.prompt() != "alice> " {
+		t.Errorf("prompt %q", sh.prompt())
+	}
+
+	tokenID := sh.vars["lastToken"]
+	bob := sh.wallets["bob"].Address
+	if got := s.Balance(bob, tokenID); got != "30" {
+		t.Errorf("bob has %s", got)
+	}
+	if !strings.Contains(out.String(), sh.vars["lastTx"]+" committed") {
+		t.Errorf("output:\n%s", out)
+	}
+
+	out.Reset()
+	exec(t, sh, "balance bob")
+	if f := strings.Fields(out.String()); !reflect.DeepEqual(f, []string{tokenID, "OCE", "30"}) {
+		t.Errorf("balance bob: %q", out)
+	}
+
+	// a tokenID and an address are used as is
+	exec(t, sh, "use wallet bob", "transfer "+tokenID+" "+sh.wallets["alice"].Address+" 5", "tx $lastTx")
+	if got := s.Balance(bob, tokenID); got != "25" {
+		t.Errorf("bob has %s", got)
+	}
+}
+
+func TestExecErrors(t *testing.T) {
+	sh, _, s := newShell(t)
+	defer s.Close()
+
+	for _, line := range []string{
+		"mint OCE",
+		"issue OCE 10",
+		"balance",
+		"use wallet carol",
+		"watch tx $lastTx",
+		"wallet load a notakey",
+		"transfer OCE",
+	} {
+		if err := sh.Exec(context.Background(), line); err == nil {
+			t.Errorf("%s succeeded", line)
+		}
+	}
+
+	exec(t, sh, "wallet new a")
+	if err := sh.Exec(context.Background(), "transfer OCE b 1000"); err == nil {
+		t.Error("transfer of an unknown token succeeded")
+	}
+
+	if err := sh.Exec(context.Background(), "exit"); err != errExit {
+		t.Errorf("exit: %v", err)
+	}
+}
+
+func TestWalletLoad(t *testing.T) {
+	sh, _, s := newShell(t)
+	defer s.Close()
+
+	if err := sh.Exec(context.Background(), "wallet load alice"); err == nil {
+		t.Error("wallet load without a terminal succeeded")
+	}
+
+	wif, _, address := util.GetNewAddress()
+	prompted := ""
+	sh.readKey = func(prompt string) (string, error) {
+		prompted = prompt
+		return wif, nil
+	}
+
+	exec(t, sh, "wallet load alice", "wallet load bob "+wif)
+	if prompted != "private key of alice: " || sh.wallets["alice"].Address != address || sh.wallets["bob"].Address != address {
+		t.Errorf("prompt %q, wallets %+v %+v", prompted, sh.wallets["alice"], sh.wallets["bob"])
+	}
+
+	for line, want := range map[string]bool{
+		"wallet load bob " + wif: true,
+		"wallet load bob":        false,
+		"wallet new bob":         false,
+		"transfer OCE bob 1":     false,
+	} {
+		if secret(line) != want {
+			t.Errorf("secret(%q) = %v", line, !want)
+		}
+	}
+}
+
+func TestComplete(t *testing.T) {
+	sh, _, s := newShell(t)
+	defer s.Close()
+
+	exec(t, sh, "wallet new alice", "wallet new albert", "wallet new bob", "set lastTx abc", "set lastToken def")
+	sh.tokens["OCE"] = "0ce"
+
+	for line, want := range map[string][]string{
+		"tr":                  {"transfer"},
+		"w":                   {"wallet", "wallets", "watch"},
+		"use ":                {"wallet"},
+		"use wallet al":       {"albert", "alice"},
+		"transfer O":          {"OCE"},
+		"transfer OCE b":      {"bob"},
+		"watch tx $last":      {"$lastToken", "$lastTx"},
+		"balance ":            {"albert", "alice", "bob"},
+		"transfer OCE bob 1 ": nil,
+	} {
+		head, got, tail := sh.complete(line, len(line))
+		if !reflect.DeepEqual(got, want) || head+tail != line[:strings.LastIndex(line, " ")+1] {
+			t.Errorf("complete(%q) = %q %q %q, want %q", line, head, got, tail, want)
+		}
+	}
+}
+
+func TestKeystore(t *testing.T) {
+	sh, out, s := newShell(t)
+	defer s.Close()
+
+	ks, err := keystore.Open(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	ks.Scrypt = keystore.LightScrypt
+
+	passphrase := "secret"
+	prompt := func(string) (string, error) { return passphrase, nil }
+
+	w := testclient.NewWallet()
+	if err := ks.Import("alice", w, "secret"); err != nil {
+		t.Fatal(err)
+	}
+	if err := sh.SetKeystore(ks, prompt); err != nil {
+		t.Fatal(err)
+	}
+
+	exec(t, sh, "use wallet alice")
+	if err := sh.Exec(context.Background(), "issue OCE 10"); err == nil || !strings.Contains(err.Error(), "locked") {
+		t.Errorf("issue from a locked wallet: %v", err)
+	}
+
+	passphrase = "guess"
+	if err := sh.Exec(context.Background(), "unlock alice"); err != keystore.ErrPassphrase {
+		t.Errorf("unlock with a wrong passphrase: %v", err)
+	}
+
+	passphrase = "secret"
+	exec(t, sh, "unlock alice 1m", "issue OCE 10", "lock", "wallet new bob", "wallet save bob")
+	if err := sh.Exec(context.Background(), "transfer OCE bob 1"); err == nil {
+		t.Error("transfer after lock succeeded")
+	}
+
+	if list, _ := ks.List(); len(list) != 2 || list[1].Name != "bob" {
+		t.Errorf("keystore holds %+v", list)
+	}
+
+	out.Reset()
+	exec(t, sh, "wallets")
+	if !strings.Contains(out.String(), "* alice") || !strings.Contains(out.String(), "keystore") {
+		t.Errorf("wallets:\n%s", out)
+	}
+}
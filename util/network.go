@@ -0,0 +1,173 @@
+package util
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"github.com/btcsuite/btcd/btcec"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcutil"
+)
+
+// AddressType selects how the address of a public key is encoded.
+type AddressType string
+
+const (
+	// P2PKH is the base58check address of the hash160 of the
+	// compressed public key, the address of the existing wallets.
+	P2PKH AddressType = "p2pkh"
+	// PubKeyAddress is the hex compressed public key itself.
+	PubKeyAddress AddressType = "pubkey"
+)
+
+// Network holds the version bytes of the keys and addresses of a
+// deployment and the type of its addresses.
+type Network struct {
+	Params      *chaincfg.Params
+	AddressType AddressType
+}
+
+var (
+	MainNet = &Network{Params: &chaincfg.MainNetParams, AddressType: P2PKH}
+	TestNet = &Network{Params: &chaincfg.TestNet3Params, AddressType: P2PKH}
+	RegTest = &Network{Params: &chaincfg.RegressionNetParams, AddressType: P2PKH}
+)
+
+// NetworkByName returns mainnet, testnet or regtest with the address type
+// t, p2pkh if empty.
+func NetworkByName(name string, t AddressType) (*Network, error) {
+	var params *chaincfg.Params
+	switch name {
+	case "mainnet", "":
+		params = &chaincfg.MainNetParams
+	case "testnet":
+		params = &chaincfg.TestNet3Params
+	case "regtest":
+		params = &chaincfg.RegressionNetParams
+	default:
+		return nil, fmt.Errorf("util: unknown network %q", name)
+	}
+
+	return newNetwork(params, t)
+}
+
+// CustomNetwork returns the network of mainnet with the version bytes of
+// its addresses and wif keys replaced.
+func CustomNetwork(pubKeyHashAddrID, privateKeyID byte, t AddressType) (*Network, error) {
+	params := chaincfg.MainNetParams
+	params.Name = "custom"
+	params.PubKeyHashAddrID = pubKeyHashAddrID
+	params.PrivateKeyID = privateKeyID
+
+	return newNetwork(&params, t)
+}
+
+func newNetwork(params *chaincfg.Params, t AddressType) (*Network, error) {
+	switch t {
+	case "":
+		t = P2PKH
+	case P2PKH, PubKeyAddress:
+	default:
+		return nil, fmt.Errorf("util: unknown address type %q", t)
+	}
+
+	return &Network{Params: params, AddressType: t}, nil
+}
+
+func (n *Network) String() string {
+	return n.Params.Name + "/" + string(n.AddressType)
+}
+
+// NewKey returns a new wif private key with its hex public key and
+// address.
+func (n *Network) NewKey() (string, string, string, error) {
+	priv, err := btcec.NewPrivateKey(btcec.S256())
+	if err != nil {
+		return "", "", "", err
+	}
+	return n.Encode(priv)
+}
+
+// Encode returns the wif, hex public key and address of priv.
+func (n *Network) Encode(priv *btcec.PrivateKey) (string, string, string, error) {
+	wif, err := btcutil.NewWIF(priv, n.Params, true)
+	if err != nil {
+		return "", "", "", err
+	}
+
+	pubKey := hex.EncodeToString(wif.SerializePubKey())
+
+	address, err := n.Address(pubKey)
+	if err != nil {
+		return "", "", "", err
+	}
+
+	return wif.String(), pubKey, address, nil
+}
+
+// Address returns the address of the hex public key.
+func (n *Network) Address(pubKeyHexStr string) (string, error) {
+	pubKeyBytes, err := hex.DecodeString(pubKeyHexStr)
+	if err != nil {
+		return "", err
+	}
+
+	address, err := btcutil.NewAddressPubKey(pubKeyBytes, n.Params)
+	if err != nil {
+		return "", err
+	}
+
+	if n.AddressType == PubKeyAddress {
+		return address.String(), nil
+	}
+
+	hash, err := btcutil.NewAddressPubKeyHash(btcutil.Hash160(address.ScriptAddress()), n.Params)
+	if err != nil {
+		return "", err
+	}
+
+	return hash.EncodeAddress(), nil
+}
+
+// decodeWIF decodes a wif key of the network.
+func (n *Network) decodeWIF(privKeyWif string) (*btcutil.WIF, error) {
+	wif, err := btcutil.DecodeWIF(privKeyWif)
+	if err != nil {
+		return nil, err
+	}
+
+	if !wif.IsForNet(n.Params) {
+		return nil, errors.New("util: the key is not a " + n.Params.Name + " key")
+	}
+
+	return wif, nil
+}
+
+// PubKey returns the hex public key of the wif key.
+func (n *Network) PubKey(privKeyWif string) (string, error) {
+	wif, err := n.decodeWIF(privKeyWif)
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(wif.SerializePubKey()), nil
+}
+
+// Sign returns the hex signature of the hash of originData.
+func (n *Network) Sign(privKeyWif string, originData []byte) (string, error) {
+	wif, err := n.decodeWIF(privKeyWif)
+	if err != nil {
+		return "", err
+	}
+
+	return signWIF(wif, originData)
+}
+
+func signWIF(wif *btcutil.WIF, originData []byte) (string, error) {
+	signature, err := wif.PrivKey.Sign(chainhash.HashB(originData))
+	if err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(signature.Serialize()), nil
+}
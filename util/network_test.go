@@ -0,0 +1,112 @@
+package util
+
+import (
+	"github.com/btcsuite/btcd/btcec"
+	"github.com/btcsuite/btcutil/base58"
+	"math/big"
+	"strings"
+	"testing"
+)
+
+// key1 is the private key 1.
+func key1() *btcec.PrivateKey {
+	priv, _ := btcec.PrivKeyFromBytes(btcec.S256(), big.NewInt(1).FillBytes(make([]byte, 32)))
+	return priv
+}
+
+func TestNetworkVectors(t *testing.T) {
+	pubKey1 := "0279be667ef9dcbbac55a06295ce870b07029bfcdb2dce28d959f2815b16f81798"
+	pubKeyNet, _ := NetworkByName("mainnet", PubKeyAddress)
+
+	for _, tt := range []struct {
+		net          *Network
+		wif, address string
+	}{
+		{MainNet, "KwDiBf89QgGbjEhKnhXJuH7LrciVrZi3qYjgd9M7rFU73sVHnoWn", "1BgGZ9tcN4rm9KBzDn7KprQz87SZ26SAMH"},
+		{TestNet, "cMahea7zqjxrtgAbB7LSGbcQUr1uX1ojuat9jZodMN87JcbXMTcA", "mrCDrCybB6J1vRfbwM5hemdJz73FwDBC8r"},
+		{RegTest, "cMahea7zqjxrtgAbB7LSGbcQUr1uX1ojuat9jZodMN87JcbXMTcA", "mrCDrCybB6J1vRfbwM5hemdJz73FwDBC8r"},
+		{pubKeyNet, "KwDiBf89QgGbjEhKnhXJuH7LrciVrZi3qYjgd9M7rFU73sVHnoWn", pubKey1},
+	} {
+		wif, pubKey, address, err := tt.net.Encode(key1())
+		if err != nil || wif != tt.wif || pubKey != pubKey1 || address != tt.address {
+			t.Errorf("%s: Encode = %s %s %s, %v", tt.net, wif, pubKey, address, err)
+		}
+
+		if got, err := tt.net.PubKey(wif); err != nil || got != pubKey1 {
+			t.Errorf("%s: PubKey = %s, %v", tt.net, got, err)
+		}
+	}
+}
+
+func TestCustomNetwork(t *testing.T) {
+	n, err := CustomNetwork(0x41, 0xc1, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	wif, pubKey, address, err := n.NewKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, version, err := base58.CheckDecode(address); err != nil || version != 0x41 {
+		t.Errorf("address %s has version %x, %v", address, version, err)
+	}
+	if _, version, err := base58.CheckDecode(wif); err != nil || version != 0xc1 {
+		t.Errorf("wif %s has version %x, %v", wif, version, err)
+	}
+	if got, _ := n.Address(pubKey); got != address {
+		t.Errorf("Address = %s, want %s", got, address)
+	}
+
+	// mainnet is untouched
+	if _, _, address, _ := MainNet.Encode(key1()); address != "1BgGZ9tcN4rm9KBzDn7KprQz87SZ26SAMH" {
+		t.Errorf("mainnet address %s", address)
+	}
+}
+
+func TestNetworkMismatch(t *testing.T) {
+	wif, _, _, _ := TestNet.Encode(key1())
+
+	if _, err := MainNet.PubKey(wif); err == nil {
+		t.Error("PubKey of a testnet key on mainnet succeeded")
+	}
+	if _, err := MainNet.Sign(wif, []byte("00")); err == nil {
+		t.Error("Sign with a testnet key on mainnet succeeded")
+	}
+
+	if _, err := NetworkByName("simnet", ""); err == nil {
+		t.Error("unknown network succeeded")
+	}
+	if _, err := NetworkByName("mainnet", "p2sh"); err == nil {
+		t.Error("unknown address type succeeded")
+	}
+}
+
+func TestMainNetDefaults(t *testing.T) {
+	wif, pubKey, addr := GetNewAddress()
+	if !strings.HasPrefix(addr, "1") || GetAddress(pubKey) != addr {
+		t.Errorf("mainnet address %s", addr)
+	}
+
+	sig, err := Sign(wif, []byte("00"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ok, err := Verify(pubKey, "00", sig); !ok || err != nil {
+		t.Errorf("Verify = %v, %v", ok, err)
+	}
+
+	// the package functions accept the keys of every network
+	testnetWif, testnetPubKey, _, _ := TestNet.Encode(key1())
+	if pubKey, err := GetPubKeyByPrivKey(testnetWif); err != nil || pubKey != testnetPubKey {
+		t.Errorf("GetPubKeyByPrivKey of a testnet key = %s, %v", pubKey, err)
+	}
+	sig, err = Sign(testnetWif, []byte("00"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ok, err := Verify(testnetPubKey, "00", sig); !ok || err != nil {
+		t.Errorf("Verify of a testnet signature = %v, %v", ok, err)
+	}
+}
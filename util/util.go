@@ -4,7 +4,6 @@ import (
 	"encoding/hex"
 	"fabricclient/logger"
 	"github.com/btcsuite/btcd/btcec"
-	"github.com/btcsuite/btcd/chaincfg"
 	"github.com/btcsuite/btcd/chaincfg/chainhash"
 	"github.com/btcsuite/btcutil"
 	"github.com/satori/go.uuid"
@@ -35,25 +34,15 @@ func IsFileExist(filename string) bool {
 	return false
 }
 
+// GetNewAddress returns a new wif key, its hex public key and its address
+// on mainnet, see Network for the others.
 func GetNewAddress() (string, string, string) {
-	priv, err := btcec.NewPrivateKey(btcec.S256())
+	wif, pubKey, address, err := MainNet.NewKey()
 	if err != nil {
 		return "", "", ""
 	}
 
-	wif, err := btcutil.NewWIF(priv, &chaincfg.MainNetParams, true)
-	if err != nil {
-		return "", "", ""
-	}
-
-	pubKeyBytes := wif.SerializePubKey()
-
-	address, err := btcutil.NewAddressPubKey(pubKeyBytes, &chaincfg.MainNetParams)
-	if err != nil {
-		return "", "", ""
-	}
-
-	return wif.String(), hex.EncodeToString(pubKeyBytes), address.EncodeAddress()
+	return wif, pubKey, address
 }
 
 func Verify(pubKeyHexStr, originStr, signHexStr string) (bool, error) {
@@ -89,21 +78,15 @@ func Verify(pubKeyHexStr, originStr, signHexStr string) (bool, error) {
 	return signature.Verify(originHash, pubKey), nil
 }
 
+// Sign returns the hex signature of the hash of originData with a wif key
+// of any network, see Network.Sign to check it.
 func Sign(privKeyWif string, originData []byte) (string, error) {
 	wif, err := btcutil.DecodeWIF(privKeyWif)
 	if err != nil {
 		return "", err
 	}
 
-	// Sign a message using the private key.
-	originHash := chainhash.HashB(originData)
-
-	signature, err := wif.PrivKey.Sign(originHash)
-	if err != nil {
-		return "", err
-	}
-
-	return hex.EncodeToString(signature.Serialize()), nil
+	return signWIF(wif, originData)
 }
 
 //get address by public key
@@ -112,27 +95,21 @@ func GetAddress(pubKeyHexStr string) string {
 		return ""
 	}
 
-	// Decode hex-encoded serialized public key.
-	pubKeyBytes, err := hex.DecodeString(pubKeyHexStr)
-	if err != nil {
-		return ""
-	}
-
-	address, err := btcutil.NewAddressPubKey(pubKeyBytes, &chaincfg.MainNetParams)
+	address, err := MainNet.Address(pubKeyHexStr)
 	if err != nil {
 		return ""
 	}
 
-	return address.EncodeAddress()
+	return address
 }
 
+// GetPubKeyByPrivKey returns the hex public key of a wif key of any
+// network, see Network.PubKey to check it.
 func GetPubKeyByPrivKey(privKeyWif string) (string, error) {
 	wif, err := btcutil.DecodeWIF(privKeyWif)
 	if err != nil {
 		return "", err
 	}
 
-	pubKeyBytes := wif.SerializePubKey()
-
-	return hex.EncodeToString(pubKeyBytes), nil
+	return hex.EncodeToString(wif.SerializePubKey()), nil
 }
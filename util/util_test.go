@@ -0,0 +1,107 @@
+package util
+
+import (
+	"testing"
+)
+
+func TestGetNewAddress(t *testing.T) {
+	wif, pubKey, addr := GetNewAddress()
+	if wif == "" || pubKey == "" || addr == "" {
+		t.Fatalf("GetNewAddress() = %q, %q, %q", wif, pubKey, addr)
+	}
+
+	got, err := GetPubKeyByPrivKey(wif)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != pubKey {
+		t.Errorf("GetPubKeyByPrivKey() = %s, want %s", got, pubKey)
+	}
+
+	if got := GetAddress(pubKey); got != addr {
+		t.Errorf("GetAddress() = %s, want %s", got, addr)
+	}
+
+	wif2, _, addr2 := GetNewAddress()
+	if wif2 == wif || addr2 == addr {
+		t.Error("GetNewAddress() returned the same key twice")
+	}
+}
+
+func TestSignVerify(t *testing.T) {
+	wif, pubKey, _ := GetNewAddress()
+	origin := "7b2261646472657373223a2231227d"
+
+	sig, err := Sign(wif, []byte(origin))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ok, err := Verify(pubKey, origin, sig)
+	if err != nil || !ok {
+		t.Fatalf("Verify() = %v, %v, want true", ok, err)
+	}
+
+	ok, err = Verify(pubKey, origin+"00", sig)
+	if err != nil || ok {
+		t.Errorf("Verify(tampered origin) = %v, %v, want false", ok, err)
+	}
+
+	_, otherPubKey, _ := GetNewAddress()
+	ok, err = Verify(otherPubKey, origin, sig)
+	if err != nil || ok {
+		t.Errorf("Verify(other key) = %v, %v, want false", ok, err)
+	}
+}
+
+func TestVerifyMalformed(t *testing.T) {
+	_, pubKey, _ := GetNewAddress()
+
+	tests := []struct {
+		name                    string
+		pubKey, origin, signHex string
+		wantErr                 bool
+	}{
+		{"empty", "", "", "", false},
+		{"bad pubkey hex", "zz", "00", "00", true},
+		{"bad pubkey", "0102", "00", "00", true},
+		{"bad signature hex", pubKey, "00", "zz", true},
+		{"bad signature", pubKey, "00", "3001", true},
+	}
+
+	for _, tt := range tests {
+		ok, err := Verify(tt.pubKey, tt.origin, tt.signHex)
+		if ok {
+			t.Errorf("%s: Verify() = true", tt.name)
+		}
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s: Verify() err = %v, wantErr %v", tt.name, err, tt.wantErr)
+		}
+	}
+}
+
+func TestMalformedWIF(t *testing.T) {
+	for _, wif := range []string{"", "not-a-wif", "5HueCGU8rMjxEXxiPuD5BDku4MkFqeZyd4dZ1jvhTVqvbTLvyTJ"[:20]} {
+		if _, err := Sign(wif, []byte("00")); err == nil {
+			t.Errorf("Sign(%q) succeeded", wif)
+		}
+		if _, err := GetPubKeyByPrivKey(wif); err == nil {
+			t.Errorf("GetPubKeyByPrivKey(%q) succeeded", wif)
+		}
+	}
+}
+
+func TestGetAddressMalformed(t *testing.T) {
+	for _, pubKey := range []string{"", "zz", "0102"} {
+		if got := GetAddress(pubKey); got != "" {
+			t.Errorf("GetAddress(%q) = %q, want empty", pubKey, got)
+		}
+	}
+}
+
+func TestGetUUID(t *testing.T) {
+	a, b := GetUUID(), GetUUID()
+	if len(a) != 32 || a == b {
+		t.Errorf("GetUUID() = %q, %q", a, b)
+	}
+}